@@ -81,6 +81,7 @@ module "tiller" {
   tiller_service_account_token_secret_name = module.tiller_service_account.token_secret_name
   namespace                                = module.tiller_namespace.name
   tiller_image_version                     = var.tiller_version
+  tiller_history_max                       = var.tiller_history_max
 
   tiller_tls_gen_method   = "provider"
   tiller_tls_subject      = var.tls_subject
@@ -399,6 +399,39 @@ resource "null_resource" "tls_secret_generated" {
   }
 }
 
+# ---------------------------------------------------------------------------------------------------------------------
+# VALIDATE THE INPUT VARIABLES
+# Terraform 0.12 does not support custom validation rules on variables, so we validate the inputs here. When an input is
+# invalid, we call the file function with the error message as the path, which fails the plan with the message in the
+# error. Only the errors of the selected result of a conditional are reported, so valid inputs do not fail.
+# ---------------------------------------------------------------------------------------------------------------------
+
+locals {
+  validate_tiller_tls_gen_method = (
+    contains(["provider", "kubergrunt", "none"], var.tiller_tls_gen_method)
+    ? null
+    : file("ERROR: var.tiller_tls_gen_method must be one of provider, kubergrunt, or none, but got ${var.tiller_tls_gen_method}")
+  )
+
+  validate_private_key_algorithm = (
+    contains(["RSA", "ECDSA"], var.private_key_algorithm)
+    ? null
+    : file("ERROR: var.private_key_algorithm must be one of RSA or ECDSA, but got ${var.private_key_algorithm}")
+  )
+
+  validate_kubectl_server_endpoint = (
+    var.kubectl_server_endpoint == "" || (var.kubectl_ca_b64_data != "" && var.kubectl_token != "")
+    ? null
+    : file("ERROR: var.kubectl_ca_b64_data and var.kubectl_token must be set when var.kubectl_server_endpoint is set")
+  )
+
+  validate_tiller_tls_secret_name = (
+    var.tiller_tls_gen_method != "none" || (var.tiller_tls_secret_name != null && var.tiller_tls_secret_name != "")
+    ? null
+    : file("ERROR: var.tiller_tls_secret_name must be set when var.tiller_tls_gen_method is none")
+  )
+}
+
 # ---------------------------------------------------------------------------------------------------------------------
 # GLOBAL CONSTANTS
 # Avoids the usage of magic strings.
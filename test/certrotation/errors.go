@@ -0,0 +1,62 @@
+package certrotation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+)
+
+// CertificatesExpiringError is returned when certificates in the Tiller credentials Secrets expire within the checked
+// period.
+type CertificatesExpiringError struct {
+	Within   time.Duration
+	Expiring []CertificateExpiry
+}
+
+func (err CertificatesExpiringError) Error() string {
+	descriptions := []string{}
+	for _, expiry := range err.Expiring {
+		descriptions = append(
+			descriptions,
+			fmt.Sprintf("%s expires in %d days (%s)", expiry, expiry.DaysToExpiry, expiry.NotAfter.Format(time.RFC3339)),
+		)
+	}
+	return fmt.Sprintf(
+		"Found %d Tiller certificates expiring within %s:\n\t%s",
+		len(err.Expiring),
+		err.Within,
+		strings.Join(descriptions, "\n\t"),
+	)
+}
+
+// NotServerCredentialsSecretError is returned when the Secret to rotate is not labeled as Tiller server credentials.
+type NotServerCredentialsSecretError struct {
+	Namespace  string
+	SecretName string
+	Actual     string
+}
+
+func (err NotServerCredentialsSecretError) Error() string {
+	return fmt.Sprintf(
+		"Secret %s in namespace %s is not a Tiller server credentials Secret: expected label %s=%s, got %q",
+		err.SecretName,
+		err.Namespace,
+		helmhome.TillerCredentialsTypeLabel,
+		ServerCredentialsType,
+		err.Actual,
+	)
+}
+
+// CertificateNotSignedByCAError is returned when rotating a certificate that was not signed by the given CA, in which
+// case the re-issued certificate would not be trusted by the existing clients.
+type CertificateNotSignedByCAError struct {
+	Name   string
+	CAName string
+	Reason string
+}
+
+func (err CertificateNotSignedByCAError) Error() string {
+	return fmt.Sprintf("Certificate %s is not signed by CA %s: %s", err.Name, err.CAName, err.Reason)
+}
@@ -0,0 +1,127 @@
+package certrotation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TillerCredentialsLabelSelector selects the Secrets that hold the Tiller CA, server, and client certificate key pairs
+// created by the k8s-tiller module, the root module, and kubergrunt.
+const TillerCredentialsLabelSelector = helmhome.TillerCredentialsLabel + "=true"
+
+// CertificateExpiry is the expiry of a single certificate stored in a Tiller credentials Secret. Secrets of signed
+// certificates also carry a copy of the CA certificate, which is reported as a separate entry under its own key.
+type CertificateExpiry struct {
+	Namespace  string
+	SecretName string
+
+	// The gruntwork.io/tiller-namespace and gruntwork.io/tiller-credentials-type labels of the Secret. The credentials
+	// type is one of ca, server, or client.
+	TillerNamespace string
+	CredentialsType string
+
+	// The key of the certificate in the Secret data, e.g. tls.crt.
+	Key          string
+	CommonName   string
+	NotAfter     time.Time
+	DaysToExpiry int
+}
+
+// String returns a short description of the certificate, for use in log and error messages.
+func (expiry CertificateExpiry) String() string {
+	return fmt.Sprintf("%s/%s[%s]", expiry.Namespace, expiry.SecretName, expiry.Key)
+}
+
+// GetExpiryReport returns the expiry of every certificate in the Tiller credentials Secrets across all namespaces,
+// regardless of the namespace of the provided KubectlOptions, sorted by expiry. This will fail the test if there is an
+// error.
+func GetExpiryReport(t *testing.T, options *k8s.KubectlOptions, now time.Time) []CertificateExpiry {
+	report, err := GetExpiryReportE(t, options, now)
+	require.NoError(t, err)
+	return report
+}
+
+// GetExpiryReportE returns the expiry of every certificate in the Tiller credentials Secrets across all namespaces,
+// regardless of the namespace of the provided KubectlOptions, sorted by expiry.
+func GetExpiryReportE(t *testing.T, options *k8s.KubectlOptions, now time.Time) ([]CertificateExpiry, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return GetExpiryReportFromClientE(clientset, now)
+}
+
+// GetExpiryReportFromClientE returns the expiry of every certificate in the Tiller credentials Secrets across all
+// namespaces using the given client, sorted by expiry. The days to expiry are counted in whole days from now, and are
+// negative for certificates that have already expired.
+func GetExpiryReportFromClientE(clientset kubernetes.Interface, now time.Time) ([]CertificateExpiry, error) {
+	secrets, err := clientset.CoreV1().Secrets(metav1.NamespaceAll).List(
+		metav1.ListOptions{LabelSelector: TillerCredentialsLabelSelector},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	report := []CertificateExpiry{}
+	for _, secret := range secrets.Items {
+		for key, data := range secret.Data {
+			if !strings.HasSuffix(key, ".crt") {
+				continue
+			}
+			cert, err := tlscerts.ParseCertificatePEME(fmt.Sprintf("%s/%s[%s]", secret.Namespace, secret.Name, key), data)
+			if err != nil {
+				return nil, err
+			}
+			report = append(report, CertificateExpiry{
+				Namespace:       secret.Namespace,
+				SecretName:      secret.Name,
+				TillerNamespace: secret.Labels[helmhome.TillerNamespaceLabel],
+				CredentialsType: secret.Labels[helmhome.TillerCredentialsTypeLabel],
+				Key:             key,
+				CommonName:      cert.Subject.CommonName,
+				NotAfter:        cert.NotAfter,
+				DaysToExpiry:    int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+			})
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if !report[i].NotAfter.Equal(report[j].NotAfter) {
+			return report[i].NotAfter.Before(report[j].NotAfter)
+		}
+		return report[i].String() < report[j].String()
+	})
+	return report, nil
+}
+
+// CheckNoneExpiring checks that none of the certificates in the report expire within the given period from now. This
+// will fail the test if any do.
+func CheckNoneExpiring(t *testing.T, report []CertificateExpiry, now time.Time, within time.Duration) {
+	require.NoError(t, CheckNoneExpiringE(report, now, within))
+}
+
+// CheckNoneExpiringE returns a CertificatesExpiringError listing the certificates in the report that expire within the
+// given period from now, including the ones that have already expired.
+func CheckNoneExpiringE(report []CertificateExpiry, now time.Time, within time.Duration) error {
+	deadline := now.Add(within)
+	expiring := []CertificateExpiry{}
+	for _, expiry := range report {
+		if expiry.NotAfter.Before(deadline) {
+			expiring = append(expiring, expiry)
+		}
+	}
+	if len(expiring) > 0 {
+		return CertificatesExpiringError{Within: within, Expiring: expiring}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package certrotation
+
+import (
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetExpiryReportCoversAllTillerCredentials(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	certs := newTestTillerCerts(t, now, nil)
+	expiredClientKeyPair, err := tlscerts.GenerateKeyPairE("client", tlscerts.CertificateOptions{
+		Subject:   pkix.Name{CommonName: "alice"},
+		NotBefore: now.Add(-72 * time.Hour),
+		NotAfter:  now.Add(-36 * time.Hour),
+	}, certs.caKeyPair)
+	require.NoError(t, err)
+	clientData, err := expiredClientKeyPair.SecretDataE(tlscerts.DefaultClientFilenameBase, "")
+	require.NoError(t, err)
+	clientSecret := newTestCredentialsSecret(testTillerNamespace, "tiller-client-certs", "client", clientData)
+
+	// Secrets without the credentials label are not reported, even if they hold certificates.
+	otherSecret := certs.serverSecret.DeepCopy()
+	otherSecret.Name = "unrelated-certs"
+	otherSecret.Labels = nil
+
+	clientset := fake.NewSimpleClientset(certs.caSecret, certs.serverSecret, clientSecret, otherSecret)
+	report, err := GetExpiryReportFromClientE(clientset, now)
+	require.NoError(t, err)
+
+	// The public and private keys are skipped, and the copy of the CA cert in the server Secret is reported separately.
+	descriptions := []string{}
+	for _, expiry := range report {
+		descriptions = append(descriptions, expiry.String())
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"tiller/tiller-client-certs[client.crt]",
+			"tiller/tiller-namespace-tiller-certs[tls.crt]",
+			"kube-system/tiller-namespace-tiller-ca-certs[ca.crt]",
+			"tiller/tiller-namespace-tiller-certs[ca.crt]",
+		},
+		descriptions,
+	)
+	assert.Equal(t, CertificateExpiry{
+		Namespace:       testTillerNamespace,
+		SecretName:      "tiller-client-certs",
+		TillerNamespace: testTillerNamespace,
+		CredentialsType: "client",
+		Key:             "client.crt",
+		CommonName:      "alice",
+		NotAfter:        expiredClientKeyPair.Certificate.NotAfter,
+		DaysToExpiry:    -2,
+	}, report[0])
+	assert.Equal(t, 399, report[2].DaysToExpiry)
+
+	err = CheckNoneExpiringE(report, now, 0)
+	expiringErr, isExpiringErr := err.(CertificatesExpiringError)
+	require.True(t, isExpiringErr, "Expected CertificatesExpiringError, got %T: %s", err, err)
+	assert.Equal(t, []CertificateExpiry{report[0]}, expiringErr.Expiring)
+}
+
+func TestGetExpiryReportRejectsInvalidCertificates(t *testing.T) {
+	t.Parallel()
+
+	secret := newTestCredentialsSecret(testTillerNamespace, "broken", "client", map[string][]byte{
+		"client.crt": []byte("not a certificate"),
+	})
+	_, err := GetExpiryReportFromClientE(fake.NewSimpleClientset(secret), time.Now())
+	_, isInvalidPEMErr := err.(tlscerts.InvalidPEMError)
+	assert.True(t, isInvalidPEMErr, "Expected InvalidPEMError, got %T: %s", err, err)
+}
@@ -0,0 +1,202 @@
+package certrotation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/clientcerts"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ServerCredentialsType is the gruntwork.io/tiller-credentials-type label of the Tiller server credentials Secret.
+	ServerCredentialsType = "server"
+
+	// RestartedAtAnnotation is the Pod template annotation that is bumped to restart the Tiller Pods, which is the same
+	// annotation that `kubectl rollout restart` uses.
+	RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// RotateOptions describes where to find the Tiller server cert and the CA that signed it. The zero value matches the
+// naming conventions of the k8s-tiller module.
+type RotateOptions struct {
+	// The namespace and name of the Secret that holds the Tiller CA certificate key pair. The namespace defaults to
+	// kube-system and the name to `<tiller namespace>-namespace-tiller-ca-certs`.
+	CASecretNamespace string
+	CASecretName      string
+
+	// The name of the Secret in the Tiller namespace that holds the Tiller server certificate key pair. Defaults to
+	// `<tiller namespace>-namespace-tiller-certs`.
+	ServerSecretName string
+
+	// The name of the Tiller Deployment to restart. Defaults to tiller.DefaultDeploymentName.
+	DeploymentName string
+
+	// How long the new server certificate is valid for. Defaults to the validity period of the certificate it replaces.
+	ValidityPeriod time.Duration
+}
+
+// RotateServerCert re-issues the Tiller server cert in the namespace of the provided KubectlOptions from the same CA,
+// and restarts Tiller so that it picks up the new cert. This will fail the test if there is an error.
+func RotateServerCert(t *testing.T, options *k8s.KubectlOptions, rotateOptions RotateOptions) *tlscerts.KeyPair {
+	keyPair, err := RotateServerCertE(t, options, rotateOptions)
+	require.NoError(t, err)
+	return keyPair
+}
+
+// RotateServerCertE re-issues the Tiller server cert in the namespace of the provided KubectlOptions from the same CA,
+// and restarts Tiller so that it picks up the new cert.
+func RotateServerCertE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	rotateOptions RotateOptions,
+) (*tlscerts.KeyPair, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return RotateServerCertFromClientE(clientset, options.Namespace, rotateOptions)
+}
+
+// RotateServerCertFromClientE re-issues the Tiller server cert from the same CA using the given client, and returns the
+// new key pair. The new certificate keeps the subject, allowed uses, IP addresses, DNS names, and private key algorithm
+// of the certificate it replaces. The Secret is updated in place, and the Pod template of the Tiller Deployment is
+// annotated to restart Tiller, as Tiller only reads its certificates on startup. Wait for the rollout to finish before
+// connecting to Tiller again.
+//
+// NOTE: When the k8s-tiller module generates the certs with the terraform tls provider, the Secret is managed by
+// Terraform, so the next apply restores the certificate from the Terraform state.
+func RotateServerCertFromClientE(
+	clientset kubernetes.Interface,
+	tillerNamespace string,
+	rotateOptions RotateOptions,
+) (*tlscerts.KeyPair, error) {
+	caSecretNamespace := rotateOptions.CASecretNamespace
+	if caSecretNamespace == "" {
+		caSecretNamespace = clientcerts.DefaultCASecretNamespace
+	}
+	caSecretName := rotateOptions.CASecretName
+	if caSecretName == "" {
+		caSecretName = fmt.Sprintf("%s-namespace-tiller-ca-certs", tillerNamespace)
+	}
+	serverSecretName := rotateOptions.ServerSecretName
+	if serverSecretName == "" {
+		serverSecretName = fmt.Sprintf("%s-namespace-tiller-certs", tillerNamespace)
+	}
+	deploymentName := rotateOptions.DeploymentName
+	if deploymentName == "" {
+		deploymentName = tiller.DefaultDeploymentName
+	}
+
+	caSecret, err := clientset.CoreV1().Secrets(caSecretNamespace).Get(caSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	caKeyPair, err := tlscerts.KeyPairFromSecretE(caSecret, tlscerts.DefaultCAFilenameBase, "")
+	if err != nil {
+		return nil, err
+	}
+
+	serverSecret, err := clientset.CoreV1().Secrets(tillerNamespace).Get(serverSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if actual := serverSecret.Labels[helmhome.TillerCredentialsTypeLabel]; actual != ServerCredentialsType {
+		return nil, NotServerCredentialsSecretError{
+			Namespace:  tillerNamespace,
+			SecretName: serverSecretName,
+			Actual:     actual,
+		}
+	}
+	serverKeyPair, err := tlscerts.KeyPairFromSecretE(
+		serverSecret,
+		tlscerts.DefaultServerFilenameBase,
+		tlscerts.DefaultCAFilenameBase,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Only check the signature and not the full chain, as the whole point is to replace certificates that are about to
+	// expire, or already have.
+	if err := serverKeyPair.Certificate.CheckSignatureFrom(caKeyPair.Certificate); err != nil {
+		return nil, CertificateNotSignedByCAError{
+			Name:   serverKeyPair.Name,
+			CAName: caKeyPair.Name,
+			Reason: err.Error(),
+		}
+	}
+
+	oldCert := serverKeyPair.Certificate
+	validityPeriod := rotateOptions.ValidityPeriod
+	if validityPeriod == 0 {
+		validityPeriod = oldCert.NotAfter.Sub(oldCert.NotBefore)
+	}
+	privateKey, err := tlscerts.GeneratePrivateKeyLikeE(serverKeyPair.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	newKeyPair, err := tlscerts.GenerateKeyPairE(
+		serverKeyPair.Name,
+		tlscerts.CertificateOptions{
+			Subject:     oldCert.Subject,
+			NotBefore:   now,
+			NotAfter:    now.Add(validityPeriod),
+			KeyUsage:    oldCert.KeyUsage,
+			ExtKeyUsage: oldCert.ExtKeyUsage,
+			IPAddresses: oldCert.IPAddresses,
+			DNSNames:    oldCert.DNSNames,
+			PrivateKey:  privateKey,
+		},
+		caKeyPair,
+	)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newKeyPair.SecretDataE(tlscerts.DefaultServerFilenameBase, tlscerts.DefaultCAFilenameBase)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep any other keys in the Secret, and the labels that the report and kubergrunt rely on.
+	serverSecret = serverSecret.DeepCopy()
+	for key, value := range data {
+		serverSecret.Data[key] = value
+	}
+	if _, err := clientset.CoreV1().Secrets(tillerNamespace).Update(serverSecret); err != nil {
+		return nil, err
+	}
+
+	if err := restartDeploymentE(clientset, tillerNamespace, deploymentName, now); err != nil {
+		return nil, err
+	}
+	return newKeyPair, nil
+}
+
+// restartDeploymentE triggers a rolling restart of the Pods of the Deployment, in the same way as `kubectl rollout
+// restart`.
+func restartDeploymentE(
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	restartedAt time.Time,
+) error {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	deployment = deployment.DeepCopy()
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[RestartedAtAnnotation] = restartedAt.Format(time.RFC3339)
+	_, err = clientset.AppsV1().Deployments(namespace).Update(deployment)
+	return err
+}
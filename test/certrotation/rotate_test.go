@@ -0,0 +1,215 @@
+package certrotation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testTillerNamespace  = "tiller"
+	testCASecretName     = "tiller-namespace-tiller-ca-certs"
+	testServerSecretName = "tiller-namespace-tiller-certs"
+)
+
+// testTillerCerts are the Tiller credentials Secrets in the layout of the k8s-tiller module, with a server cert that
+// expires within the hour.
+type testTillerCerts struct {
+	caKeyPair     *tlscerts.KeyPair
+	serverKeyPair *tlscerts.KeyPair
+	caSecret      *corev1.Secret
+	serverSecret  *corev1.Secret
+}
+
+func newTestTillerCerts(t *testing.T, now time.Time, serverPrivateKey crypto.Signer) testTillerCerts {
+	caKeyPair, err := tlscerts.GenerateKeyPairE("ca", tlscerts.CertificateOptions{
+		Subject:   pkix.Name{CommonName: "tiller CA"},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.Add(400 * 24 * time.Hour),
+		IsCA:      true,
+		KeyUsage:  x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}, nil)
+	require.NoError(t, err)
+	serverKeyPair, err := tlscerts.GenerateKeyPairE("server", tlscerts.CertificateOptions{
+		Subject:     pkix.Name{CommonName: "tiller", Organization: []string{"Gruntwork"}},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(time.Hour),
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		PrivateKey:  serverPrivateKey,
+	}, caKeyPair)
+	require.NoError(t, err)
+
+	caData, err := caKeyPair.SecretDataE(tlscerts.DefaultCAFilenameBase, "")
+	require.NoError(t, err)
+	serverData, err := serverKeyPair.SecretDataE(tlscerts.DefaultServerFilenameBase, tlscerts.DefaultCAFilenameBase)
+	require.NoError(t, err)
+	serverData["extra"] = []byte("kept")
+	return testTillerCerts{
+		caKeyPair:     caKeyPair,
+		serverKeyPair: serverKeyPair,
+		caSecret:      newTestCredentialsSecret("kube-system", testCASecretName, "ca", caData),
+		serverSecret:  newTestCredentialsSecret(testTillerNamespace, testServerSecretName, "server", serverData),
+	}
+}
+
+func newTestCredentialsSecret(
+	namespace string,
+	name string,
+	credentialsType string,
+	data map[string][]byte,
+) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels: map[string]string{
+				helmhome.TillerNamespaceLabel:       testTillerNamespace,
+				helmhome.TillerCredentialsLabel:     "true",
+				helmhome.TillerCredentialsTypeLabel: credentialsType,
+			},
+		},
+		Data: data,
+	}
+}
+
+func newTestTillerDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testTillerNamespace, Name: tiller.DefaultDeploymentName},
+	}
+}
+
+func TestRotateServerCertReissuesShortLivedCertFromSameCA(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	certs := newTestTillerCerts(t, now, nil)
+	clientset := fake.NewSimpleClientset(certs.caSecret, certs.serverSecret, newTestTillerDeployment())
+
+	// The short lived server cert shows up in the report before the rotation.
+	report, err := GetExpiryReportFromClientE(clientset, now)
+	require.NoError(t, err)
+	err = CheckNoneExpiringE(report, now, 30*24*time.Hour)
+	expiringErr, isExpiringErr := err.(CertificatesExpiringError)
+	require.True(t, isExpiringErr, "Expected CertificatesExpiringError, got %T: %s", err, err)
+	expiring := expiringErr.Expiring
+	require.Equal(t, 1, len(expiring))
+	assert.Equal(t, "server", expiring[0].CredentialsType)
+	assert.Equal(t, "tls.crt", expiring[0].Key)
+	assert.Equal(t, 0, expiring[0].DaysToExpiry)
+
+	newKeyPair, err := RotateServerCertFromClientE(
+		clientset,
+		testTillerNamespace,
+		RotateOptions{ValidityPeriod: 365 * 24 * time.Hour},
+	)
+	require.NoError(t, err)
+
+	secret, err := clientset.CoreV1().Secrets(testTillerNamespace).Get(testServerSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, certs.serverSecret.Labels, secret.Labels)
+	assert.Equal(t, []byte("kept"), secret.Data["extra"])
+	storedKeyPair, err := tlscerts.KeyPairFromSecretE(
+		secret,
+		tlscerts.DefaultServerFilenameBase,
+		tlscerts.DefaultCAFilenameBase,
+	)
+	require.NoError(t, err)
+	assert.True(t, storedKeyPair.Certificate.Equal(newKeyPair.Certificate))
+	assert.False(t, storedKeyPair.Certificate.Equal(certs.serverKeyPair.Certificate))
+	assert.NotEqual(t, certs.serverKeyPair.PublicKey, storedKeyPair.PublicKey)
+	ecdsaKey, isECDSAKey := storedKeyPair.PrivateKey.(*ecdsa.PrivateKey)
+	require.True(t, isECDSAKey, "Expected an ECDSA private key, got %T", storedKeyPair.PrivateKey)
+	assert.Equal(t, elliptic.P256(), ecdsaKey.Curve)
+
+	tlscerts.VerifyChain(t, certs.caKeyPair, storedKeyPair)
+	tlscerts.VerifyKeyPair(t, storedKeyPair, tlscerts.Expectations{
+		Subject:             map[string]string{"common_name": "tiller", "organization": "Gruntwork"},
+		AllowedUses:         []string{"key_encipherment", "digital_signature", "server_auth"},
+		IPAddresses:         []string{"127.0.0.1"},
+		ValidityPeriodHours: 365 * 24,
+	})
+
+	deployment, err := clientset.AppsV1().Deployments(testTillerNamespace).Get(
+		tiller.DefaultDeploymentName,
+		metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+	assert.NotEmpty(t, deployment.Spec.Template.Annotations[RestartedAtAnnotation])
+
+	report, err = GetExpiryReportFromClientE(clientset, now)
+	require.NoError(t, err)
+	CheckNoneExpiring(t, report, now, 30*24*time.Hour)
+}
+
+func TestRotateServerCertKeepsValidityPeriodAndKeyAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	certs := newTestTillerCerts(t, now, rsaKey)
+	clientset := fake.NewSimpleClientset(certs.caSecret, certs.serverSecret, newTestTillerDeployment())
+
+	newKeyPair, err := RotateServerCertFromClientE(clientset, testTillerNamespace, RotateOptions{})
+	require.NoError(t, err)
+	newRSAKey, isRSAKey := newKeyPair.PrivateKey.(*rsa.PrivateKey)
+	require.True(t, isRSAKey, "Expected an RSA private key, got %T", newKeyPair.PrivateKey)
+	assert.Equal(t, 1024, newRSAKey.N.BitLen())
+	assert.Equal(t, 2*time.Hour, newKeyPair.Certificate.NotAfter.Sub(newKeyPair.Certificate.NotBefore))
+}
+
+func TestRotateServerCertRejectsCertFromOtherCA(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	certs := newTestTillerCerts(t, now, nil)
+	otherCerts := newTestTillerCerts(t, now, nil)
+	clientset := fake.NewSimpleClientset(otherCerts.caSecret, certs.serverSecret, newTestTillerDeployment())
+
+	_, err := RotateServerCertFromClientE(clientset, testTillerNamespace, RotateOptions{})
+	_, isNotSignedErr := err.(CertificateNotSignedByCAError)
+	assert.True(t, isNotSignedErr, "Expected CertificateNotSignedByCAError, got %T: %s", err, err)
+
+	// Nothing is touched when the rotation is rejected.
+	secret, err := clientset.CoreV1().Secrets(testTillerNamespace).Get(testServerSecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, certs.serverSecret.Data, secret.Data)
+	deployment, err := clientset.AppsV1().Deployments(testTillerNamespace).Get(
+		tiller.DefaultDeploymentName,
+		metav1.GetOptions{},
+	)
+	require.NoError(t, err)
+	assert.Empty(t, deployment.Spec.Template.Annotations)
+}
+
+func TestRotateServerCertRequiresServerCredentialsSecret(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	certs := newTestTillerCerts(t, now, nil)
+	certs.serverSecret.Labels[helmhome.TillerCredentialsTypeLabel] = "client"
+	clientset := fake.NewSimpleClientset(certs.caSecret, certs.serverSecret, newTestTillerDeployment())
+
+	_, err := RotateServerCertFromClientE(clientset, testTillerNamespace, RotateOptions{})
+	_, isNotServerCredentialsErr := err.(NotServerCredentialsSecretError)
+	assert.True(t, isNotServerCredentialsErr, "Expected NotServerCredentialsSecretError, got %T: %s", err, err)
+}
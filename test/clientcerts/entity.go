@@ -0,0 +1,54 @@
+package clientcerts
+
+import (
+	"strings"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+)
+
+// The kinds of RBAC entities that can be granted access to Tiller. These match the grant_helm_client_rbac_user,
+// grant_helm_client_rbac_group, and grant_helm_client_rbac_service_account inputs of the root module.
+const (
+	UserEntity           = "user"
+	GroupEntity          = "group"
+	ServiceAccountEntity = "service-account"
+)
+
+// RBACEntity is a user, group, or ServiceAccount that is granted access to Tiller. The ID is the user name, the group
+// name, or the ServiceAccount in `NAMESPACE/NAME` format, which is the same as the rbac_entity_id of the root module.
+type RBACEntity struct {
+	Kind string
+	ID   string
+}
+
+func (entity RBACEntity) String() string {
+	return entity.Kind + " " + entity.ID
+}
+
+// SecretName returns the name of the Secret that holds the client TLS certificate key pair of the entity, following
+// the `tiller-client-${md5(local.rbac_entity_id)}-certs` convention of the root module and kubergrunt.
+func (entity RBACEntity) SecretName() string {
+	return helmhome.ClientCertSecretName(entity.ID)
+}
+
+// ValidateE returns an InvalidRBACEntityError if the kind is unknown, the ID is empty, or a ServiceAccount ID is not in
+// `NAMESPACE/NAME` format.
+func (entity RBACEntity) ValidateE() error {
+	switch entity.Kind {
+	case UserEntity, GroupEntity:
+		if entity.ID == "" {
+			return InvalidRBACEntityError{Entity: entity, Reason: "the ID is empty"}
+		}
+	case ServiceAccountEntity:
+		parts := strings.Split(entity.ID, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return InvalidRBACEntityError{Entity: entity, Reason: "ServiceAccounts must be in NAMESPACE/NAME format"}
+		}
+	default:
+		return InvalidRBACEntityError{
+			Entity: entity,
+			Reason: "the kind must be one of " + strings.Join([]string{UserEntity, GroupEntity, ServiceAccountEntity}, ", "),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package clientcerts
+
+import (
+	"fmt"
+)
+
+// InvalidRBACEntityError is returned when an RBAC entity can not be granted access to Tiller.
+type InvalidRBACEntityError struct {
+	Entity RBACEntity
+	Reason string
+}
+
+func (err InvalidRBACEntityError) Error() string {
+	return fmt.Sprintf("Invalid RBAC entity %q of kind %q: %s", err.Entity.ID, err.Entity.Kind, err.Reason)
+}
+
+// GrantAlreadyExistsError is returned when issuing a client cert for an RBAC entity that already has one.
+type GrantAlreadyExistsError struct {
+	TillerNamespace string
+	SecretName      string
+	Entity          RBACEntity
+}
+
+func (err GrantAlreadyExistsError) Error() string {
+	return fmt.Sprintf(
+		"The %s already has a client cert in Secret %s in namespace %s. Revoke it first to issue a new one.",
+		err.Entity,
+		err.SecretName,
+		err.TillerNamespace,
+	)
+}
+
+// GrantNotFoundError is returned when revoking the client cert of an RBAC entity that does not have one.
+type GrantNotFoundError struct {
+	TillerNamespace string
+	SecretName      string
+	Entity          RBACEntity
+}
+
+func (err GrantNotFoundError) Error() string {
+	return fmt.Sprintf(
+		"The %s does not have a client cert in namespace %s: Secret %s does not exist",
+		err.Entity,
+		err.TillerNamespace,
+		err.SecretName,
+	)
+}
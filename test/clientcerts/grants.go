@@ -0,0 +1,288 @@
+package clientcerts
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// The annotations that record which RBAC entity a client cert was issued to, because the Secret name only has a
+	// hash of the entity ID. Grants made by the root module or kubergrunt don't have these annotations.
+	RBACEntityKindAnnotation = "gruntwork.io/tiller-client-rbac-entity-kind"
+	RBACEntityIDAnnotation   = "gruntwork.io/tiller-client-rbac-entity-id"
+
+	// DefaultCASecretNamespace is the namespace that the k8s-tiller module stores the Tiller CA Secret in.
+	DefaultCASecretNamespace = "kube-system"
+
+	// DefaultValidityPeriod is the default validity_period_hours of the k8s-helm-client-tls-certs module.
+	DefaultValidityPeriod = 87660 * time.Hour
+)
+
+// IssueOptions describes where to find the Tiller CA that signs the client certs, and the certificate to issue.
+type IssueOptions struct {
+	// The namespace and name of the Secret that holds the Tiller CA certificate key pair. These are the
+	// tiller_ca_tls_certificate_key_pair_secret_namespace and tiller_ca_tls_certificate_key_pair_secret_name outputs of
+	// the k8s-tiller module.
+	CASecretNamespace string
+	CASecretName      string
+
+	// The subject of the client certificate. The common name defaults to the ID of the RBAC entity.
+	Subject pkix.Name
+
+	// How long the client certificate is valid for. Defaults to DefaultValidityPeriod.
+	ValidityPeriod time.Duration
+}
+
+// Grant is a client cert for an RBAC entity, stored in a Secret in the Tiller namespace. Entity is empty if the grant
+// was not made with this package.
+type Grant struct {
+	TillerNamespace string
+	SecretName      string
+	Entity          RBACEntity
+	CommonName      string
+	NotAfter        time.Time
+}
+
+// clientCredentialsLabels returns the labels that the root module and kubergrunt set on the client credentials Secrets.
+func clientCredentialsLabels(tillerNamespace string) map[string]string {
+	return map[string]string{
+		helmhome.TillerNamespaceLabel:       tillerNamespace,
+		helmhome.TillerCredentialsLabel:     "true",
+		helmhome.TillerCredentialsTypeLabel: helmhome.ClientCredentialsType,
+	}
+}
+
+// IssueClientCert issues a client cert for the RBAC entity, signed by the Tiller CA, and stores it in the Tiller
+// namespace of the provided KubectlOptions under the name and labels that kubergrunt expects. This will fail the test
+// if there is an error.
+func IssueClientCert(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	entity RBACEntity,
+	issueOptions IssueOptions,
+) *Grant {
+	grant, err := IssueClientCertE(t, options, entity, issueOptions)
+	require.NoError(t, err)
+	return grant
+}
+
+// IssueClientCertE issues a client cert for the RBAC entity, signed by the Tiller CA, and stores it in the Tiller
+// namespace of the provided KubectlOptions under the name and labels that kubergrunt expects.
+func IssueClientCertE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	entity RBACEntity,
+	issueOptions IssueOptions,
+) (*Grant, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return IssueClientCertFromClientE(clientset, options.Namespace, entity, issueOptions)
+}
+
+// IssueClientCertFromClientE issues a client cert for the RBAC entity, signed by the Tiller CA, and stores it in the
+// Tiller namespace using the given client. Returns a GrantAlreadyExistsError if the entity already has a client cert,
+// so that existing credentials are never overwritten.
+func IssueClientCertFromClientE(
+	clientset kubernetes.Interface,
+	tillerNamespace string,
+	entity RBACEntity,
+	issueOptions IssueOptions,
+) (*Grant, error) {
+	if err := entity.ValidateE(); err != nil {
+		return nil, err
+	}
+	secretName := entity.SecretName()
+	_, err := clientset.CoreV1().Secrets(tillerNamespace).Get(secretName, metav1.GetOptions{})
+	if err == nil {
+		return nil, GrantAlreadyExistsError{TillerNamespace: tillerNamespace, SecretName: secretName, Entity: entity}
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	caSecretNamespace := issueOptions.CASecretNamespace
+	if caSecretNamespace == "" {
+		caSecretNamespace = DefaultCASecretNamespace
+	}
+	caSecret, err := clientset.CoreV1().Secrets(caSecretNamespace).Get(issueOptions.CASecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	caKeyPair, err := tlscerts.KeyPairFromSecretE(caSecret, tlscerts.DefaultCAFilenameBase, "")
+	if err != nil {
+		return nil, err
+	}
+
+	subject := issueOptions.Subject
+	if subject.CommonName == "" {
+		subject.CommonName = entity.ID
+	}
+	validityPeriod := issueOptions.ValidityPeriod
+	if validityPeriod == 0 {
+		validityPeriod = DefaultValidityPeriod
+	}
+	// These match the default tls_certs_allowed_uses and tls_certs_ip_addresses of the k8s-helm-client-tls-certs
+	// module.
+	now := time.Now()
+	clientKeyPair, err := tlscerts.GenerateKeyPairE(
+		secretName,
+		tlscerts.CertificateOptions{
+			Subject:     subject,
+			NotBefore:   now,
+			NotAfter:    now.Add(validityPeriod),
+			KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		},
+		caKeyPair,
+	)
+	if err != nil {
+		return nil, err
+	}
+	data, err := clientKeyPair.SecretDataE(tlscerts.DefaultClientFilenameBase, tlscerts.DefaultCAFilenameBase)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: tillerNamespace,
+			Name:      secretName,
+			Labels:    clientCredentialsLabels(tillerNamespace),
+			Annotations: map[string]string{
+				RBACEntityKindAnnotation: entity.Kind,
+				RBACEntityIDAnnotation:   entity.ID,
+			},
+		},
+		Data: data,
+	}
+	if _, err := clientset.CoreV1().Secrets(tillerNamespace).Create(secret); err != nil {
+		return nil, err
+	}
+	return &Grant{
+		TillerNamespace: tillerNamespace,
+		SecretName:      secretName,
+		Entity:          entity,
+		CommonName:      clientKeyPair.Certificate.Subject.CommonName,
+		NotAfter:        clientKeyPair.Certificate.NotAfter,
+	}, nil
+}
+
+// ListGrants lists the client certs stored in the Tiller namespace of the provided KubectlOptions, sorted by Secret
+// name. This will fail the test if there is an error.
+func ListGrants(t *testing.T, options *k8s.KubectlOptions) []Grant {
+	grants, err := ListGrantsE(t, options)
+	require.NoError(t, err)
+	return grants
+}
+
+// ListGrantsE lists the client certs stored in the Tiller namespace of the provided KubectlOptions, sorted by Secret
+// name.
+func ListGrantsE(t *testing.T, options *k8s.KubectlOptions) ([]Grant, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return ListGrantsFromClientE(clientset, options.Namespace)
+}
+
+// ListGrantsFromClientE lists the client certs stored in the Tiller namespace using the given client, sorted by Secret
+// name. This includes the grants made by the root module and kubergrunt, which are found by their labels.
+func ListGrantsFromClientE(clientset kubernetes.Interface, tillerNamespace string) ([]Grant, error) {
+	secrets, err := clientset.CoreV1().Secrets(tillerNamespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(clientCredentialsLabels(tillerNamespace)).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	grants := []Grant{}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		clientKeyPair, err := tlscerts.KeyPairFromSecretE(secret, tlscerts.DefaultClientFilenameBase, "")
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, Grant{
+			TillerNamespace: tillerNamespace,
+			SecretName:      secret.Name,
+			Entity: RBACEntity{
+				Kind: secret.Annotations[RBACEntityKindAnnotation],
+				ID:   secret.Annotations[RBACEntityIDAnnotation],
+			},
+			CommonName: clientKeyPair.Certificate.Subject.CommonName,
+			NotAfter:   clientKeyPair.Certificate.NotAfter,
+		})
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].SecretName < grants[j].SecretName })
+	return grants, nil
+}
+
+// RevokeGrant deletes the client cert of the RBAC entity from the Tiller namespace of the provided KubectlOptions. This
+// will fail the test if there is an error.
+func RevokeGrant(t *testing.T, options *k8s.KubectlOptions, entity RBACEntity) {
+	require.NoError(t, RevokeGrantE(t, options, entity))
+}
+
+// RevokeGrantE deletes the client cert of the RBAC entity from the Tiller namespace of the provided KubectlOptions.
+func RevokeGrantE(t *testing.T, options *k8s.KubectlOptions, entity RBACEntity) error {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return err
+	}
+	return RevokeGrantFromClientE(clientset, options.Namespace, entity)
+}
+
+// RevokeGrantFromClientE deletes the client cert of the RBAC entity from the Tiller namespace using the given client.
+// Returns a GrantNotFoundError if the entity has no client cert, or a helmhome.NotClientCredentialsSecretError if the
+// Secret with the expected name is not labeled as client credentials, in which case it is left alone.
+//
+// NOTE: Tiller does not support certificate revocation lists, so this only removes the stored credentials. Anyone who
+// already has a copy of the client cert can keep using it until it expires or the Tiller CA is rotated.
+func RevokeGrantFromClientE(clientset kubernetes.Interface, tillerNamespace string, entity RBACEntity) error {
+	if err := entity.ValidateE(); err != nil {
+		return err
+	}
+	secretName := entity.SecretName()
+	secret, err := clientset.CoreV1().Secrets(tillerNamespace).Get(secretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return GrantNotFoundError{TillerNamespace: tillerNamespace, SecretName: secretName, Entity: entity}
+	}
+	if err != nil {
+		return err
+	}
+
+	expectedLabels := clientCredentialsLabels(tillerNamespace)
+	for _, key := range []string{
+		helmhome.TillerNamespaceLabel,
+		helmhome.TillerCredentialsLabel,
+		helmhome.TillerCredentialsTypeLabel,
+	} {
+		if actual := secret.Labels[key]; actual != expectedLabels[key] {
+			return helmhome.NotClientCredentialsSecretError{
+				Namespace:  tillerNamespace,
+				SecretName: secretName,
+				LabelKey:   key,
+				Expected:   expectedLabels[key],
+				Actual:     actual,
+			}
+		}
+	}
+	return clientset.CoreV1().Secrets(tillerNamespace).Delete(secretName, &metav1.DeleteOptions{})
+}
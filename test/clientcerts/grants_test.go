@@ -0,0 +1,166 @@
+package clientcerts
+
+import (
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testTillerNamespace = "tiller"
+	testCASecretName    = "tiller-namespace-tiller-ca-certs"
+)
+
+// newTestCA returns a Tiller CA key pair, and the Secret that the k8s-tiller module stores it in.
+func newTestCA(t *testing.T) (*tlscerts.KeyPair, *corev1.Secret) {
+	now := time.Now()
+	caKeyPair, err := tlscerts.GenerateKeyPairE("ca", tlscerts.CertificateOptions{
+		Subject:   pkix.Name{CommonName: "tiller CA"},
+		NotBefore: now,
+		NotAfter:  now.Add(time.Hour),
+		IsCA:      true,
+	}, nil)
+	require.NoError(t, err)
+	data, err := caKeyPair.SecretDataE(tlscerts.DefaultCAFilenameBase, "")
+	require.NoError(t, err)
+	return caKeyPair, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: DefaultCASecretNamespace, Name: testCASecretName},
+		Data:       data,
+	}
+}
+
+func TestIssueClientCertStoresKubergruntCompatibleSecret(t *testing.T) {
+	t.Parallel()
+
+	caKeyPair, caSecret := newTestCA(t)
+	clientset := fake.NewSimpleClientset(caSecret)
+	entity := RBACEntity{Kind: ServiceAccountEntity, ID: "apps/deployer"}
+
+	grant, err := IssueClientCertFromClientE(
+		clientset,
+		testTillerNamespace,
+		entity,
+		IssueOptions{
+			CASecretName:   testCASecretName,
+			Subject:        pkix.Name{Organization: []string{"Gruntwork"}},
+			ValidityPeriod: 24 * time.Hour,
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, helmhome.ClientCertSecretName("apps/deployer"), grant.SecretName)
+	assert.Equal(t, "apps/deployer", grant.CommonName)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), grant.NotAfter, time.Minute)
+
+	secret, err := clientset.CoreV1().Secrets(testTillerNamespace).Get(grant.SecretName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, clientCredentialsLabels(testTillerNamespace), secret.Labels)
+	clientKeyPair, err := tlscerts.KeyPairFromSecretE(
+		secret,
+		tlscerts.DefaultClientFilenameBase,
+		tlscerts.DefaultCAFilenameBase,
+	)
+	require.NoError(t, err)
+	require.NoError(t, tlscerts.VerifyChainE(caKeyPair, clientKeyPair))
+	require.NoError(t, tlscerts.VerifyKeyPairE(clientKeyPair, tlscerts.Expectations{
+		Subject:     map[string]string{"common_name": "apps/deployer", "organization": "Gruntwork"},
+		AllowedUses: []string{"key_encipherment", "digital_signature", "client_auth"},
+		IPAddresses: []string{"127.0.0.1"},
+	}))
+
+	// The helm home can be configured from the Secret, the same way as for the grants made by the root module.
+	helmHome, err := ioutil.TempDir("", "clientcerts")
+	require.NoError(t, err)
+	defer os.RemoveAll(helmHome)
+	require.NoError(t, helmhome.WriteHelmHomeE(clientset, helmHome, testTillerNamespace, entity.ID))
+
+	_, err = IssueClientCertFromClientE(clientset, testTillerNamespace, entity, IssueOptions{CASecretName: caSecret.Name})
+	_, isAlreadyExistsErr := err.(GrantAlreadyExistsError)
+	assert.True(t, isAlreadyExistsErr, "Expected GrantAlreadyExistsError, got %T: %s", err, err)
+}
+
+func TestListAndRevokeGrants(t *testing.T) {
+	t.Parallel()
+
+	_, caSecret := newTestCA(t)
+	clientset := fake.NewSimpleClientset(caSecret)
+	issueOptions := IssueOptions{CASecretName: testCASecretName}
+	user := RBACEntity{Kind: UserEntity, ID: "alice"}
+	group := RBACEntity{Kind: GroupEntity, ID: "developers"}
+	for _, entity := range []RBACEntity{user, group} {
+		_, err := IssueClientCertFromClientE(clientset, testTillerNamespace, entity, issueOptions)
+		require.NoError(t, err)
+	}
+
+	// Grants made by the root module have the same name and labels, but no annotations.
+	rootModuleSecret, err := clientset.CoreV1().Secrets(testTillerNamespace).Get(user.SecretName(), metav1.GetOptions{})
+	require.NoError(t, err)
+	rootModuleSecret = rootModuleSecret.DeepCopy()
+	rootModuleSecret.Name = helmhome.ClientCertSecretName("admin")
+	rootModuleSecret.Annotations = nil
+	_, err = clientset.CoreV1().Secrets(testTillerNamespace).Create(rootModuleSecret)
+	require.NoError(t, err)
+
+	grants, err := ListGrantsFromClientE(clientset, testTillerNamespace)
+	require.NoError(t, err)
+	grantsBySecretName := map[string]Grant{}
+	for _, grant := range grants {
+		grantsBySecretName[grant.SecretName] = grant
+	}
+	assert.Equal(t, 3, len(grantsBySecretName))
+	assert.Equal(t, user, grantsBySecretName[user.SecretName()].Entity)
+	assert.Equal(t, group, grantsBySecretName[group.SecretName()].Entity)
+	assert.Equal(t, RBACEntity{}, grantsBySecretName[rootModuleSecret.Name].Entity)
+	assert.Equal(t, "alice", grantsBySecretName[rootModuleSecret.Name].CommonName)
+
+	require.NoError(t, RevokeGrantFromClientE(clientset, testTillerNamespace, user))
+	grants, err = ListGrantsFromClientE(clientset, testTillerNamespace)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(grants))
+
+	err = RevokeGrantFromClientE(clientset, testTillerNamespace, user)
+	_, isNotFoundErr := err.(GrantNotFoundError)
+	assert.True(t, isNotFoundErr, "Expected GrantNotFoundError, got %T: %s", err, err)
+}
+
+func TestRevokeGrantLeavesOtherSecretsAlone(t *testing.T) {
+	t.Parallel()
+
+	entity := RBACEntity{Kind: UserEntity, ID: "alice"}
+	clientset := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testTillerNamespace, Name: entity.SecretName()},
+	})
+
+	err := RevokeGrantFromClientE(clientset, testTillerNamespace, entity)
+	_, isNotClientCredentialsErr := err.(helmhome.NotClientCredentialsSecretError)
+	assert.True(t, isNotClientCredentialsErr, "Expected NotClientCredentialsSecretError, got %T: %s", err, err)
+	_, err = clientset.CoreV1().Secrets(testTillerNamespace).Get(entity.SecretName(), metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+func TestValidateRBACEntity(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, RBACEntity{Kind: UserEntity, ID: "alice"}.ValidateE())
+	assert.NoError(t, RBACEntity{Kind: ServiceAccountEntity, ID: "apps/deployer"}.ValidateE())
+
+	for _, entity := range []RBACEntity{
+		{Kind: UserEntity},
+		{Kind: ServiceAccountEntity, ID: "deployer"},
+		{Kind: ServiceAccountEntity, ID: "apps/"},
+		{Kind: "robot", ID: "r2d2"},
+	} {
+		err := entity.ValidateE()
+		_, isInvalidEntityErr := err.(InvalidRBACEntityError)
+		assert.True(t, isInvalidEntityErr, "Expected InvalidRBACEntityError for %v, got %T: %s", entity, err, err)
+	}
+}
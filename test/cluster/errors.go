@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KindCommandFailedError is returned when a kind command fails.
+type KindCommandFailedError struct {
+	Args       []string
+	Output     string
+	Underlying error
+}
+
+func (err KindCommandFailedError) Error() string {
+	return fmt.Sprintf("kind %s failed: %s\n%s", strings.Join(err.Args, " "), err.Underlying, err.Output)
+}
+
+// ContextNotFoundError is returned when the kubeconfig does not have the requested context.
+type ContextNotFoundError struct {
+	KubeConfigPath string
+	ContextName    string
+}
+
+func (err ContextNotFoundError) Error() string {
+	return fmt.Sprintf("Context %s is not in the kubeconfig %s", err.ContextName, err.KubeConfigPath)
+}
+
+// NoClientCertificateError is returned when the user of the context does not authenticate with a client certificate,
+// so the RBAC user name can't be determined.
+type NoClientCertificateError struct {
+	ContextName string
+}
+
+func (err NoClientCertificateError) Error() string {
+	return fmt.Sprintf("The user of context %s does not authenticate with a client certificate", err.ContextName)
+}
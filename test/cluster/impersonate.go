@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// WriteImpersonatingKubeConfig writes a kubeconfig to destPath with a single context of the same name as the given
+// context, which authenticates with the credentials of that context but impersonates the given user. This will fail
+// the test if there is an error.
+func WriteImpersonatingKubeConfig(
+	t *testing.T,
+	kubeConfigPath string,
+	contextName string,
+	userName string,
+	destPath string,
+) {
+	require.NoError(t, WriteImpersonatingKubeConfigE(kubeConfigPath, contextName, userName, destPath))
+}
+
+// WriteImpersonatingKubeConfigE writes a kubeconfig to destPath with a single context of the same name as the given
+// context, which authenticates with the credentials of that context but impersonates the given user. This is for
+// tools that only take a kubeconfig, such as Helm 3, to act as e.g. a ServiceAccount without a token of the
+// ServiceAccount. The API server adds the groups of a ServiceAccount user name to the impersonated user, so the tool
+// gets exactly the permissions of the ServiceAccount. The credentials of the given context must be allowed to
+// impersonate users.
+func WriteImpersonatingKubeConfigE(kubeConfigPath string, contextName string, userName string, destPath string) error {
+	config, err := clientcmd.LoadFromFile(kubeConfigPath)
+	if err != nil {
+		return err
+	}
+	// Make the paths to certs and keys absolute, so that they still resolve from the new kubeconfig.
+	if err := clientcmd.ResolveLocalPaths(config); err != nil {
+		return err
+	}
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	context, hasContext := config.Contexts[contextName]
+	if !hasContext {
+		return ContextNotFoundError{KubeConfigPath: kubeConfigPath, ContextName: contextName}
+	}
+
+	authInfo := clientcmdapi.NewAuthInfo()
+	if existingAuthInfo, hasAuthInfo := config.AuthInfos[context.AuthInfo]; hasAuthInfo {
+		authInfo = existingAuthInfo.DeepCopy()
+	}
+	authInfo.Impersonate = userName
+	authInfo.ImpersonateGroups = nil
+	authInfo.ImpersonateUserExtra = nil
+
+	impersonatingContext := context.DeepCopy()
+	impersonatingContext.AuthInfo = userName
+
+	impersonatingConfig := clientcmdapi.NewConfig()
+	if cluster, hasCluster := config.Clusters[context.Cluster]; hasCluster {
+		impersonatingConfig.Clusters[context.Cluster] = cluster.DeepCopy()
+	}
+	impersonatingConfig.AuthInfos[userName] = authInfo
+	impersonatingConfig.Contexts[contextName] = impersonatingContext
+	impersonatingConfig.CurrentContext = contextName
+	return clientcmd.WriteToFile(*impersonatingConfig, destPath)
+}
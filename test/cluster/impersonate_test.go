@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestWriteImpersonatingKubeConfig(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, err := ioutil.TempDir("", "cluster")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	certPEM := []byte("not parsed")
+	kubeConfigPath := filepath.Join(tmpDir, "kubeconfig")
+	kubeConfig := fmt.Sprintf(testKubeConfigTemplate, base64.StdEncoding.EncodeToString(certPEM), "client.crt")
+	require.NoError(t, ioutil.WriteFile(kubeConfigPath, []byte(kubeConfig), 0600))
+
+	userName := "system:serviceaccount:resources:deployer"
+	for _, contextName := range []string{"", "cert-file"} {
+		destPath := filepath.Join(tmpDir, fmt.Sprintf("impersonating-%s", contextName))
+		require.NoError(t, WriteImpersonatingKubeConfigE(kubeConfigPath, contextName, userName, destPath))
+
+		config, err := clientcmd.LoadFromFile(destPath)
+		require.NoError(t, err)
+		expectedContextName := contextName
+		if expectedContextName == "" {
+			expectedContextName = "kind-test"
+		}
+		assert.Equal(t, expectedContextName, config.CurrentContext)
+		assert.Equal(t, 1, len(config.Contexts))
+		assert.Equal(t, 1, len(config.AuthInfos))
+		assert.Equal(t, 1, len(config.Clusters))
+
+		context := config.Contexts[expectedContextName]
+		require.NotNil(t, context)
+		assert.Equal(t, "kind-test", context.Cluster)
+		assert.Equal(t, userName, context.AuthInfo)
+		assert.Equal(t, "https://127.0.0.1:6443", config.Clusters["kind-test"].Server)
+
+		authInfo := config.AuthInfos[userName]
+		require.NotNil(t, authInfo)
+		assert.Equal(t, userName, authInfo.Impersonate)
+		if contextName == "" {
+			assert.Equal(t, certPEM, authInfo.ClientCertificateData)
+		} else {
+			// The relative path to the cert is resolved against the original kubeconfig.
+			assert.Equal(t, filepath.Join(tmpDir, "client.crt"), authInfo.ClientCertificate)
+		}
+	}
+
+	err = WriteImpersonatingKubeConfigE(kubeConfigPath, "missing", userName, filepath.Join(tmpDir, "missing"))
+	_, isContextNotFoundErr := err.(ContextNotFoundError)
+	assert.True(t, isContextNotFoundErr, "Expected ContextNotFoundError, got %T: %s", err, err)
+}
@@ -0,0 +1,189 @@
+package cluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// KindClusterNameEnvVar is the environment variable that names an existing kind cluster to run the tests against.
+	// When set, the tests reuse that cluster instead of creating a throwaway one, and leave it running when they are
+	// done. This is useful for rerunning test stages, which would otherwise each get a new cluster.
+	KindClusterNameEnvVar = "KIND_CLUSTER_NAME"
+
+	// KindNodeImageEnvVar is the environment variable that overrides the node image, and thus the Kubernetes version,
+	// of the throwaway kind cluster. When empty, DefaultKindNodeImage is used.
+	KindNodeImageEnvVar = "KIND_NODE_IMAGE"
+
+	// DefaultKindNodeImage is the node image of the throwaway kind cluster. This is pinned instead of using the default
+	// image of the installed kind version, because the suite targets the Kubernetes versions that Tiller v2.12 supports:
+	// newer versions drop policy/v1beta1, which the test charts use, and no longer create ServiceAccount token Secrets.
+	DefaultKindNodeImage = "kindest/node:v1.13.12"
+
+	// KindClusterWaitTimeout is how long to wait for the control plane of a new kind cluster to be ready.
+	KindClusterWaitTimeout = "5m"
+)
+
+// Cluster is a kind (Kubernetes in Docker) cluster to run the tests against, with its own kubeconfig file so that the
+// tests don't depend on or modify the kubeconfig in the home directory.
+type Cluster struct {
+	Name           string
+	KubeConfigPath string
+	ContextName    string
+
+	// AdminUserName is the RBAC user that the kubeconfig authenticates as, which has cluster admin permissions.
+	AdminUserName string
+
+	// Created is true when the cluster was created by Bootstrap, and so should be deleted when the tests are done.
+	Created bool
+}
+
+// Bootstrap creates a throwaway kind cluster, or reuses the one named by the KIND_CLUSTER_NAME environment variable,
+// and writes its kubeconfig to a temp file. This will fail the test if there is an error.
+func Bootstrap(t *testing.T) *Cluster {
+	cluster, err := BootstrapE(t)
+	require.NoError(t, err)
+	return cluster
+}
+
+// BootstrapE creates a throwaway kind cluster, or reuses the one named by the KIND_CLUSTER_NAME environment variable,
+// and writes its kubeconfig to a temp file.
+func BootstrapE(t *testing.T) (*Cluster, error) {
+	kubeConfigFile, err := ioutil.TempFile("", "kind-kubeconfig")
+	if err != nil {
+		return nil, err
+	}
+	kubeConfigFile.Close()
+
+	cluster := &Cluster{Name: os.Getenv(KindClusterNameEnvVar), KubeConfigPath: kubeConfigFile.Name()}
+	if cluster.Name != "" {
+		logger.Logf(t, "Reusing kind cluster %s", cluster.Name)
+		kubeConfig, err := runKindE("get", "kubeconfig", "--name", cluster.Name)
+		if err != nil {
+			cluster.cleanupE()
+			return nil, err
+		}
+		if err := ioutil.WriteFile(cluster.KubeConfigPath, []byte(kubeConfig), 0600); err != nil {
+			cluster.cleanupE()
+			return nil, err
+		}
+	} else {
+		cluster.Name = fmt.Sprintf("terratest-%s", strings.ToLower(random.UniqueId()))
+		logger.Logf(t, "Creating kind cluster %s", cluster.Name)
+		args := []string{
+			"create", "cluster",
+			"--name", cluster.Name,
+			"--kubeconfig", cluster.KubeConfigPath,
+			"--wait", KindClusterWaitTimeout,
+		}
+		image := os.Getenv(KindNodeImageEnvVar)
+		if image == "" {
+			image = DefaultKindNodeImage
+		}
+		args = append(args, "--image", image)
+		if _, err := runKindE(args...); err != nil {
+			cluster.cleanupE()
+			return nil, err
+		}
+		cluster.Created = true
+	}
+
+	// kind names the context after the cluster, with a kind- prefix.
+	cluster.ContextName = fmt.Sprintf("kind-%s", cluster.Name)
+	cluster.AdminUserName, err = GetUserNameE(cluster.KubeConfigPath, cluster.ContextName)
+	if err != nil {
+		// Delete the cluster if we created it, as the caller never gets a handle to it.
+		cluster.DeleteE()
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// KubectlOptions returns the options to access the given namespace of the cluster as the admin user.
+func (cluster *Cluster) KubectlOptions(namespace string) *k8s.KubectlOptions {
+	return k8s.NewKubectlOptions(cluster.ContextName, cluster.KubeConfigPath, namespace)
+}
+
+// Server returns the URL of the API server of the cluster, for kubeconfigs that point at the same cluster. This will
+// fail the test if there is an error.
+func (cluster *Cluster) Server(t *testing.T) string {
+	server, err := cluster.ServerE()
+	require.NoError(t, err)
+	return server
+}
+
+// ServerE returns the URL of the API server of the cluster, for kubeconfigs that point at the same cluster.
+func (cluster *Cluster) ServerE() (string, error) {
+	config, err := k8s.LoadApiClientConfigE(cluster.KubeConfigPath, cluster.ContextName)
+	if err != nil {
+		return "", err
+	}
+	return config.Host, nil
+}
+
+// TerraformEnvVars returns the environment variables that point the kubernetes provider at the cluster, for modules
+// that don't configure the provider themselves.
+func (cluster *Cluster) TerraformEnvVars() map[string]string {
+	return map[string]string{
+		"KUBE_CONFIG": cluster.KubeConfigPath,
+		"KUBECONFIG":  cluster.KubeConfigPath,
+		"KUBE_CTX":    cluster.ContextName,
+	}
+}
+
+// CopyKubeConfigToTemp copies the kubeconfig of the cluster to a new temp file, for tests that modify the kubeconfig
+// (e.g. to add contexts). This will fail the test if there is an error.
+func (cluster *Cluster) CopyKubeConfigToTemp(t *testing.T) string {
+	path, err := cluster.CopyKubeConfigToTempE()
+	require.NoError(t, err)
+	return path
+}
+
+// CopyKubeConfigToTempE copies the kubeconfig of the cluster to a new temp file, for tests that modify the kubeconfig
+// (e.g. to add contexts).
+func (cluster *Cluster) CopyKubeConfigToTempE() (string, error) {
+	data, err := ioutil.ReadFile(cluster.KubeConfigPath)
+	if err != nil {
+		return "", err
+	}
+	tmpFile, err := ioutil.TempFile("", "kind-kubeconfig")
+	if err != nil {
+		return "", err
+	}
+	defer tmpFile.Close()
+	_, err = tmpFile.Write(data)
+	return tmpFile.Name(), err
+}
+
+// DeleteE deletes the cluster if it was created by Bootstrap, and removes the kubeconfig file. This does not take a
+// testing.T, so that it can be called from TestMain once all the tests are done.
+func (cluster *Cluster) DeleteE() error {
+	if cluster.Created {
+		if _, err := runKindE("delete", "cluster", "--name", cluster.Name); err != nil {
+			return err
+		}
+	}
+	return cluster.cleanupE()
+}
+
+func (cluster *Cluster) cleanupE() error {
+	return os.Remove(cluster.KubeConfigPath)
+}
+
+// runKindE runs kind with the given args and returns the combined stdout and stderr.
+func runKindE(args ...string) (string, error) {
+	output, err := exec.Command("kind", args...).CombinedOutput()
+	if err != nil {
+		return string(output), KindCommandFailedError{Args: args, Output: string(output), Underlying: err}
+	}
+	return string(output), nil
+}
@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// GetUserNameE returns the name of the RBAC user that the given context of the kubeconfig authenticates as. This is the
+// common name of the client certificate of the context user, which is how the API server maps certificates to users.
+func GetUserNameE(kubeConfigPath string, contextName string) (string, error) {
+	config, err := clientcmd.LoadFromFile(kubeConfigPath)
+	if err != nil {
+		return "", err
+	}
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+	context, hasContext := config.Contexts[contextName]
+	if !hasContext {
+		return "", ContextNotFoundError{KubeConfigPath: kubeConfigPath, ContextName: contextName}
+	}
+	authInfo, hasAuthInfo := config.AuthInfos[context.AuthInfo]
+	if !hasAuthInfo {
+		return "", NoClientCertificateError{ContextName: contextName}
+	}
+
+	certPEM := authInfo.ClientCertificateData
+	if len(certPEM) == 0 && authInfo.ClientCertificate != "" {
+		certPEM, err = ioutil.ReadFile(authInfo.ClientCertificate)
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(certPEM) == 0 {
+		return "", NoClientCertificateError{ContextName: contextName}
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return "", NoClientCertificateError{ContextName: contextName}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	return cert.Subject.CommonName, nil
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeConfigTemplate = `---
+apiVersion: v1
+kind: Config
+clusters:
+- name: kind-test
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: kind-test
+  context:
+    cluster: kind-test
+    user: kind-test
+- name: cert-file
+  context:
+    cluster: kind-test
+    user: cert-file
+- name: token
+  context:
+    cluster: kind-test
+    user: token
+current-context: kind-test
+users:
+- name: kind-test
+  user:
+    client-certificate-data: %s
+- name: cert-file
+  user:
+    client-certificate: %s
+- name: token
+  user:
+    token: dummy
+`
+
+func TestGetUserName(t *testing.T) {
+	t.Parallel()
+
+	tmpDir, err := ioutil.TempDir("", "cluster")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	// kind authenticates the admin user with a client certificate for kubernetes-admin in the system:masters group.
+	keyPair, err := tlscerts.GenerateKeyPairE("admin", tlscerts.CertificateOptions{
+		Subject:   pkix.Name{CommonName: "kubernetes-admin", Organization: []string{"system:masters"}},
+		NotBefore: time.Now(),
+		NotAfter:  time.Now().Add(time.Hour),
+	}, nil)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: keyPair.Certificate.Raw})
+
+	certPath := filepath.Join(tmpDir, "client.crt")
+	require.NoError(t, ioutil.WriteFile(certPath, certPEM, 0600))
+	kubeConfigPath := filepath.Join(tmpDir, "kubeconfig")
+	kubeConfig := fmt.Sprintf(testKubeConfigTemplate, base64.StdEncoding.EncodeToString(certPEM), certPath)
+	require.NoError(t, ioutil.WriteFile(kubeConfigPath, []byte(kubeConfig), 0600))
+
+	for _, contextName := range []string{"", "kind-test", "cert-file"} {
+		userName, err := GetUserNameE(kubeConfigPath, contextName)
+		require.NoError(t, err, contextName)
+		assert.Equal(t, "kubernetes-admin", userName, contextName)
+	}
+
+	_, err = GetUserNameE(kubeConfigPath, "token")
+	_, isNoClientCertificateErr := err.(NoClientCertificateError)
+	assert.True(t, isNoClientCertificateErr)
+
+	_, err = GetUserNameE(kubeConfigPath, "missing")
+	_, isContextNotFoundErr := err.(ContextNotFoundError)
+	assert.True(t, isContextNotFoundErr)
+}
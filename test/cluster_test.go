@@ -0,0 +1,39 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/cluster"
+	"github.com/stretchr/testify/require"
+)
+
+// The tests that need a Kubernetes cluster share a single kind cluster, which is created by the first test that asks
+// for it, so that the plan and unit tests don't pay for it. The cluster is deleted by TestMain once all the tests are
+// done, unless it was reused from the KIND_CLUSTER_NAME environment variable.
+var (
+	testClusterOnce sync.Once
+	testCluster     *cluster.Cluster
+	testClusterErr  error
+)
+
+func TestMain(m *testing.M) {
+	exitCode := m.Run()
+	if testCluster != nil {
+		if err := testCluster.DeleteE(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error cleaning up kind cluster %s: %s\n", testCluster.Name, err)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// getTestCluster returns the kind cluster that the tests run against, bootstrapping it on first use.
+func getTestCluster(t *testing.T) *cluster.Cluster {
+	testClusterOnce.Do(func() {
+		testCluster, testClusterErr = cluster.BootstrapE(t)
+	})
+	require.NoError(t, testClusterErr)
+	return testCluster
+}
@@ -0,0 +1,150 @@
+// Command service-account-kubeconfig writes a standalone kubeconfig that authenticates as a ServiceAccount, such as the
+// ones created by the k8s-service-account module, using the kubeconfig package.
+//
+// Usage:
+//
+//	service-account-kubeconfig --namespace NAMESPACE --service-account NAME --output PATH
+//	service-account-kubeconfig --namespace NAMESPACE --service-account NAME --token-source token-request
+//
+// The token and cluster CA cert are embedded in the kubeconfig, so it can be handed to a CI deployer as is. The token
+// comes from the token Secret of the ServiceAccount, or from the TokenRequest API on clusters that don't create token
+// Secrets. Tokens from the TokenRequest API expire, so regenerate the kubeconfig for every run.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/kubeconfig"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err == flag.ErrHelp {
+		// The flag package already printed the usage.
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("service-account-kubeconfig", flag.ContinueOnError)
+	kubeConfigPath := flags.String(
+		"kubeconfig",
+		"",
+		"Path to the kubeconfig file used to look up the token. Defaults to the usual kubectl lookup.",
+	)
+	contextName := flags.String(
+		"kube-context",
+		"",
+		"The kubeconfig context used to look up the token. Defaults to the current context.",
+	)
+	serviceAccountName := flags.String("service-account", "", "The name of the ServiceAccount. Required.")
+	namespace := flags.String("namespace", "", "The namespace of the ServiceAccount. Required.")
+	server := flags.String(
+		"server",
+		"",
+		"The URL of the Kubernetes API server in the generated kubeconfig. Defaults to the server of --kube-context.",
+	)
+	clusterName := flags.String(
+		"cluster-name",
+		kubeconfig.DefaultClusterName,
+		"The name of the cluster in the generated kubeconfig.",
+	)
+	outputContextName := flags.String(
+		"context-name",
+		"",
+		"The name of the context in the generated kubeconfig. Defaults to the name of the ServiceAccount.",
+	)
+	tokenSource := flags.String(
+		"token-source",
+		string(kubeconfig.TokenSourceAuto),
+		fmt.Sprintf(
+			"Where the token comes from: %s, %s, or %s.",
+			kubeconfig.TokenSourceAuto,
+			kubeconfig.TokenSourceSecret,
+			kubeconfig.TokenSourceTokenRequest,
+		),
+	)
+	tokenSecretName := flags.String(
+		"token-secret-name",
+		"",
+		"The name of the token Secret (the token_secret_name output). Defaults to the token Secret of the ServiceAccount.",
+	)
+	tokenExpiration := flags.Duration(
+		"token-expiration",
+		kubeconfig.DefaultTokenExpiration,
+		"The requested lifetime of tokens from the TokenRequest API.",
+	)
+	caFile := flags.String(
+		"certificate-authority",
+		"",
+		"Path to the CA cert of the cluster. Defaults to the CA cert in the token Secret or the kube-root-ca.crt ConfigMap.",
+	)
+	outputPath := flags.String("output", "", "Path to write the kubeconfig to. Defaults to stdout.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *serviceAccountName == "" || *namespace == "" {
+		return fmt.Errorf("--service-account and --namespace are required")
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeConfigPath
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: *contextName},
+	).ClientConfig()
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	if *server == "" {
+		*server = restConfig.Host
+	}
+	var caData []byte
+	if *caFile != "" {
+		caData, err = ioutil.ReadFile(*caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	config, err := kubeconfig.GenerateForServiceAccountFromClientE(
+		clientset,
+		kubeconfig.Options{
+			ServiceAccountName: *serviceAccountName,
+			Namespace:          *namespace,
+			Server:             *server,
+			ClusterName:        *clusterName,
+			ContextName:        *outputContextName,
+			TokenSource:        kubeconfig.TokenSource(*tokenSource),
+			TokenSecretName:    *tokenSecretName,
+			TokenExpiration:    *tokenExpiration,
+			CAData:             caData,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if *outputPath != "" {
+		return clientcmd.WriteToFile(*config, *outputPath)
+	}
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
@@ -0,0 +1,196 @@
+// Command tiller-cert-rotation reports how many days are left before the Tiller TLS certs expire, and rotates the
+// Tiller server cert in place using the certrotation package.
+//
+// Usage:
+//
+//	tiller-cert-rotation report --warn-days 30
+//	tiller-cert-rotation rotate --tiller-namespace NAMESPACE
+//
+// The report covers every Secret labeled gruntwork.io/tiller-credentials=true across all namespaces, and exits with an
+// error if any certificate expires within the warning period. The rotation re-issues the Tiller server cert from the
+// same CA and restarts the Tiller Deployment so that it picks up the new cert.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/certrotation"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/clientcerts"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const usage = `Usage: tiller-cert-rotation COMMAND [OPTIONS]
+
+Commands:
+  report  Report the days to expiry of every Tiller TLS cert in the cluster
+  rotate  Re-issue the Tiller server cert from the same CA and restart Tiller
+
+Run tiller-cert-rotation COMMAND --help for the options of each command.
+`
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err == flag.ErrHelp {
+		// The flag package already printed the usage.
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+	switch args[0] {
+	case "report":
+		return runReport(args[1:], out)
+	case "rotate":
+		return runRotate(args[1:], out)
+	}
+	return fmt.Errorf("Unknown command %q\n\n%s", args[0], usage)
+}
+
+// kubeFlags are the flags that all the commands take to connect to the cluster.
+type kubeFlags struct {
+	kubeConfigPath string
+	contextName    string
+}
+
+func (kube *kubeFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(
+		&kube.kubeConfigPath,
+		"kubeconfig",
+		"",
+		"Path to the kubeconfig file. Defaults to the usual kubectl lookup.",
+	)
+	flags.StringVar(
+		&kube.contextName,
+		"kube-context",
+		"",
+		"The kubeconfig context to use. Defaults to the current context.",
+	)
+}
+
+func (kube *kubeFlags) clientset() (kubernetes.Interface, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kube.kubeConfigPath
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kube.contextName},
+	).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+func runReport(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	kube := &kubeFlags{}
+	kube.register(flags)
+	warnDays := flags.Int("warn-days", 30, "Fail if any certificate expires within this number of days.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	clientset, err := kube.clientset()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report, err := certrotation.GetExpiryReportFromClientE(clientset, now)
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAMESPACE\tSECRET\tTYPE\tKEY\tCOMMON NAME\tEXPIRES\tDAYS")
+	for _, expiry := range report {
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\t%s\t%d\n",
+			expiry.Namespace,
+			expiry.SecretName,
+			expiry.CredentialsType,
+			expiry.Key,
+			expiry.CommonName,
+			expiry.NotAfter.Format(time.RFC3339),
+			expiry.DaysToExpiry,
+		)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return certrotation.CheckNoneExpiringE(report, now, time.Duration(*warnDays)*24*time.Hour)
+}
+
+func runRotate(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	kube := &kubeFlags{}
+	kube.register(flags)
+	tillerNamespace := flags.String("tiller-namespace", "", "The namespace where Tiller is deployed. Required.")
+	caSecretNamespace := flags.String(
+		"ca-secret-namespace",
+		clientcerts.DefaultCASecretNamespace,
+		"The namespace of the Tiller CA Secret (the tiller_ca_tls_certificate_key_pair_secret_namespace output).",
+	)
+	caSecretName := flags.String(
+		"ca-secret-name",
+		"",
+		"The name of the Tiller CA Secret. Defaults to NAMESPACE-namespace-tiller-ca-certs.",
+	)
+	serverSecretName := flags.String(
+		"server-secret-name",
+		"",
+		"The name of the Tiller server cert Secret. Defaults to NAMESPACE-namespace-tiller-certs.",
+	)
+	deploymentName := flags.String("deployment-name", tiller.DefaultDeploymentName, "The name of the Tiller Deployment.")
+	validityHours := flags.Int(
+		"validity-hours",
+		0,
+		"The number of hours that the new cert is valid for. Defaults to the validity period of the current cert.",
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *tillerNamespace == "" {
+		return fmt.Errorf("--tiller-namespace is required")
+	}
+	clientset, err := kube.clientset()
+	if err != nil {
+		return err
+	}
+
+	keyPair, err := certrotation.RotateServerCertFromClientE(
+		clientset,
+		*tillerNamespace,
+		certrotation.RotateOptions{
+			CASecretNamespace: *caSecretNamespace,
+			CASecretName:      *caSecretName,
+			ServerSecretName:  *serverSecretName,
+			DeploymentName:    *deploymentName,
+			ValidityPeriod:    time.Duration(*validityHours) * time.Hour,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(
+		out,
+		"Rotated the Tiller server cert %s, valid until %s. Restarting Deployment %s in namespace %s.\n",
+		keyPair.Name,
+		keyPair.Certificate.NotAfter.Format(time.RFC3339),
+		*deploymentName,
+		*tillerNamespace,
+	)
+	return nil
+}
@@ -0,0 +1,277 @@
+// Command tiller-client-certs issues, lists, and revokes helm client certs for the Tiller deployed by the root module,
+// using the clientcerts package. The client certs are stored under the same Secret names and labels as the ones issued
+// by the root module and kubergrunt, so `kubergrunt helm configure` can set up a helm home from them.
+//
+// Usage:
+//
+//	tiller-client-certs issue --tiller-namespace NAMESPACE --ca-secret-name NAME --rbac-user USER
+//	tiller-client-certs list --tiller-namespace NAMESPACE
+//	tiller-client-certs revoke --tiller-namespace NAMESPACE --rbac-group GROUP
+//
+// Use --rbac-user, --rbac-group, or --rbac-service-account NAMESPACE/NAME to select the RBAC entity.
+package main
+
+import (
+	"crypto/x509/pkix"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/clientcerts"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const usage = `Usage: tiller-client-certs COMMAND [OPTIONS]
+
+Commands:
+  issue   Issue a client cert for an RBAC entity, signed by the Tiller CA
+  list    List the client certs in the Tiller namespace
+  revoke  Delete the client cert of an RBAC entity
+
+Run tiller-client-certs COMMAND --help for the options of each command.
+`
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err == flag.ErrHelp {
+		// The flag package already printed the usage.
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+	switch args[0] {
+	case "issue":
+		return runIssue(args[1:], out)
+	case "list":
+		return runList(args[1:], out)
+	case "revoke":
+		return runRevoke(args[1:], out)
+	}
+	return fmt.Errorf("Unknown command %q\n\n%s", args[0], usage)
+}
+
+// commonFlags are the flags that all the commands take to connect to the cluster and find Tiller.
+type commonFlags struct {
+	kubeConfigPath  string
+	contextName     string
+	tillerNamespace string
+}
+
+func (common *commonFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(
+		&common.kubeConfigPath,
+		"kubeconfig",
+		"",
+		"Path to the kubeconfig file. Defaults to the usual kubectl lookup.",
+	)
+	flags.StringVar(
+		&common.contextName,
+		"kube-context",
+		"",
+		"The kubeconfig context to use. Defaults to the current context.",
+	)
+	flags.StringVar(
+		&common.tillerNamespace,
+		"tiller-namespace",
+		"",
+		"The namespace where Tiller is deployed. Required.",
+	)
+}
+
+func (common *commonFlags) clientset() (kubernetes.Interface, error) {
+	if common.tillerNamespace == "" {
+		return nil, fmt.Errorf("--tiller-namespace is required")
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = common.kubeConfigPath
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: common.contextName},
+	).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// entityFlags are the flags that select the RBAC entity to issue or revoke a client cert for.
+type entityFlags struct {
+	user           string
+	group          string
+	serviceAccount string
+}
+
+func (entity *entityFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&entity.user, "rbac-user", "", "The name of the RBAC user.")
+	flags.StringVar(&entity.group, "rbac-group", "", "The name of the RBAC group.")
+	flags.StringVar(&entity.serviceAccount, "rbac-service-account", "", "The ServiceAccount in NAMESPACE/NAME format.")
+}
+
+func (entity *entityFlags) rbacEntity() (clientcerts.RBACEntity, error) {
+	entities := []clientcerts.RBACEntity{}
+	if entity.user != "" {
+		entities = append(entities, clientcerts.RBACEntity{Kind: clientcerts.UserEntity, ID: entity.user})
+	}
+	if entity.group != "" {
+		entities = append(entities, clientcerts.RBACEntity{Kind: clientcerts.GroupEntity, ID: entity.group})
+	}
+	if entity.serviceAccount != "" {
+		entities = append(
+			entities,
+			clientcerts.RBACEntity{Kind: clientcerts.ServiceAccountEntity, ID: entity.serviceAccount},
+		)
+	}
+	if len(entities) != 1 {
+		return clientcerts.RBACEntity{}, fmt.Errorf(
+			"Exactly one of --rbac-user, --rbac-group, or --rbac-service-account is required",
+		)
+	}
+	return entities[0], entities[0].ValidateE()
+}
+
+func runIssue(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("issue", flag.ContinueOnError)
+	common := &commonFlags{}
+	common.register(flags)
+	entity := &entityFlags{}
+	entity.register(flags)
+	caSecretNamespace := flags.String(
+		"ca-secret-namespace",
+		clientcerts.DefaultCASecretNamespace,
+		"The namespace of the Tiller CA Secret (the tiller_ca_tls_certificate_key_pair_secret_namespace output).",
+	)
+	caSecretName := flags.String(
+		"ca-secret-name",
+		"",
+		"The name of the Tiller CA Secret (the tiller_ca_tls_certificate_key_pair_secret_name output). Required.",
+	)
+	commonName := flags.String("common-name", "", "The common name of the client cert. Defaults to the RBAC entity.")
+	organization := flags.String("organization", "", "The organization of the client cert.")
+	validityHours := flags.Int(
+		"validity-hours",
+		int(clientcerts.DefaultValidityPeriod/time.Hour),
+		"The number of hours that the client cert is valid for.",
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	rbacEntity, err := entity.rbacEntity()
+	if err != nil {
+		return err
+	}
+	if *caSecretName == "" {
+		return fmt.Errorf("--ca-secret-name is required")
+	}
+	clientset, err := common.clientset()
+	if err != nil {
+		return err
+	}
+
+	subject := pkix.Name{CommonName: *commonName}
+	if *organization != "" {
+		subject.Organization = []string{*organization}
+	}
+	grant, err := clientcerts.IssueClientCertFromClientE(
+		clientset,
+		common.tillerNamespace,
+		rbacEntity,
+		clientcerts.IssueOptions{
+			CASecretNamespace: *caSecretNamespace,
+			CASecretName:      *caSecretName,
+			Subject:           subject,
+			ValidityPeriod:    time.Duration(*validityHours) * time.Hour,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(
+		out,
+		"Issued a client cert for %s in Secret %s in namespace %s, valid until %s\n",
+		grant.Entity,
+		grant.SecretName,
+		grant.TillerNamespace,
+		grant.NotAfter.Format(time.RFC3339),
+	)
+	return nil
+}
+
+func runList(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	common := &commonFlags{}
+	common.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	clientset, err := common.clientset()
+	if err != nil {
+		return err
+	}
+
+	grants, err := clientcerts.ListGrantsFromClientE(clientset, common.tillerNamespace)
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "SECRET\tKIND\tENTITY\tCOMMON NAME\tEXPIRES")
+	for _, grant := range grants {
+		// Grants made by the root module or kubergrunt don't record the RBAC entity.
+		kind, id := grant.Entity.Kind, grant.Entity.ID
+		if kind == "" {
+			kind, id = "-", "-"
+		}
+		fmt.Fprintf(
+			writer,
+			"%s\t%s\t%s\t%s\t%s\n",
+			grant.SecretName,
+			kind,
+			id,
+			grant.CommonName,
+			grant.NotAfter.Format(time.RFC3339),
+		)
+	}
+	return writer.Flush()
+}
+
+func runRevoke(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	common := &commonFlags{}
+	common.register(flags)
+	entity := &entityFlags{}
+	entity.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	rbacEntity, err := entity.rbacEntity()
+	if err != nil {
+		return err
+	}
+	clientset, err := common.clientset()
+	if err != nil {
+		return err
+	}
+	if err := clientcerts.RevokeGrantFromClientE(clientset, common.tillerNamespace, rbacEntity); err != nil {
+		return err
+	}
+	fmt.Fprintf(
+		out,
+		"Deleted the client cert of %s from namespace %s. Existing copies stay valid until they expire.\n",
+		rbacEntity,
+		common.tillerNamespace,
+	)
+	return nil
+}
@@ -0,0 +1,88 @@
+package helm3
+
+import (
+	"fmt"
+)
+
+// NotHelm3Error is returned when the helm client that the tests run is not a Helm 3 client.
+type NotHelm3Error struct {
+	Binary  string
+	Version string
+}
+
+func (err NotHelm3Error) Error() string {
+	return fmt.Sprintf(
+		"%s is not a Helm 3 client (version %q). Set %s to the path of a Helm 3 client.",
+		err.Binary,
+		err.Version,
+		BinaryEnvVar,
+	)
+}
+
+// ReleaseNotFoundError is returned when there are no release Secrets of a release.
+type ReleaseNotFoundError struct {
+	ReleaseName string
+}
+
+func (err ReleaseNotFoundError) Error() string {
+	return fmt.Sprintf("Found no Helm 3 release Secrets of release %s", err.ReleaseName)
+}
+
+// ReleaseSecretsOutsideNamespaceError is returned when Helm 3 stored release Secrets of a release outside of the
+// namespace that the release was installed into.
+type ReleaseSecretsOutsideNamespaceError struct {
+	ReleaseName string
+	Namespace   string
+	Secrets     []ReleaseSecret
+}
+
+func (err ReleaseSecretsOutsideNamespaceError) Error() string {
+	return fmt.Sprintf(
+		"Found %d release Secrets of release %s outside of namespace %s: %v",
+		len(err.Secrets),
+		err.ReleaseName,
+		err.Namespace,
+		err.Secrets,
+	)
+}
+
+// InvalidReleaseSecretTypeError is returned when a Secret with the labels of a Helm 3 release Secret does not have the
+// type of one.
+type InvalidReleaseSecretTypeError struct {
+	Secret ReleaseSecret
+}
+
+func (err InvalidReleaseSecretTypeError) Error() string {
+	return fmt.Sprintf("Release Secret %s has type %s, expected %s", err.Secret, err.Secret.Type, ReleaseSecretType)
+}
+
+// MissingReleaseDataError is returned when a Helm 3 release Secret has no release data.
+type MissingReleaseDataError struct {
+	Namespace  string
+	SecretName string
+}
+
+func (err MissingReleaseDataError) Error() string {
+	return fmt.Sprintf(
+		"Secret %s in namespace %s has no %s data to decode the release from",
+		err.SecretName,
+		err.Namespace,
+		ReleaseDataKey,
+	)
+}
+
+// InvalidReleaseRecordError is returned when the release data of a Helm 3 release Secret can not be decoded.
+type InvalidReleaseRecordError struct {
+	Namespace  string
+	SecretName string
+	Underlying error
+}
+
+func (err InvalidReleaseRecordError) Error() string {
+	return fmt.Sprintf(
+		"Error decoding the release in Secret %s in namespace %s: %s",
+		err.SecretName,
+		err.Namespace,
+		err.Underlying,
+	)
+}
@@ -0,0 +1,222 @@
+package helm3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReleaseDataKey is the key of the Secret data that holds the encoded release record.
+const ReleaseDataKey = "release"
+
+// The statuses of a Helm 3 release revision.
+const (
+	StatusUnknown         = "unknown"
+	StatusDeployed        = "deployed"
+	StatusUninstalled     = "uninstalled"
+	StatusSuperseded      = "superseded"
+	StatusFailed          = "failed"
+	StatusUninstalling    = "uninstalling"
+	StatusPendingInstall  = "pending-install"
+	StatusPendingUpgrade  = "pending-upgrade"
+	StatusPendingRollback = "pending-rollback"
+)
+
+// Release is a revision of a release in the JSON format that Helm 3 stores in the release Secrets. This mirrors the
+// release.Release type of Helm 3, so that we don't need to depend on the whole of helm. The fields that the migration
+// from Helm 2 doesn't fill in, such as the chart lock and schema and the last run of the hooks, are left out, and are
+// skipped when decoding.
+type Release struct {
+	Name      string                 `json:"name,omitempty"`
+	Info      *Info                  `json:"info,omitempty"`
+	Chart     *Chart                 `json:"chart,omitempty"`
+	Config    map[string]interface{} `json:"config,omitempty"`
+	Manifest  string                 `json:"manifest,omitempty"`
+	Hooks     []*Hook                `json:"hooks,omitempty"`
+	Version   int                    `json:"version,omitempty"`
+	Namespace string                 `json:"namespace,omitempty"`
+}
+
+// Info is the deployment information of a release revision.
+type Info struct {
+	FirstDeployed Time   `json:"first_deployed,omitempty"`
+	LastDeployed  Time   `json:"last_deployed,omitempty"`
+	Deleted       Time   `json:"deleted"`
+	Description   string `json:"description,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+}
+
+// Chart is the chart that a release revision was installed from.
+type Chart struct {
+	Metadata  *Metadata              `json:"metadata"`
+	Templates []*File                `json:"templates"`
+	Values    map[string]interface{} `json:"values"`
+	Files     []*File                `json:"files"`
+}
+
+// Metadata is the contents of the Chart.yaml of a chart.
+type Metadata struct {
+	Name        string            `json:"name,omitempty"`
+	Home        string            `json:"home,omitempty"`
+	Sources     []string          `json:"sources,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Keywords    []string          `json:"keywords,omitempty"`
+	Maintainers []*Maintainer     `json:"maintainers,omitempty"`
+	Icon        string            `json:"icon,omitempty"`
+	APIVersion  string            `json:"apiVersion,omitempty"`
+	Condition   string            `json:"condition,omitempty"`
+	Tags        string            `json:"tags,omitempty"`
+	AppVersion  string            `json:"appVersion,omitempty"`
+	Deprecated  bool              `json:"deprecated,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	KubeVersion string            `json:"kubeVersion,omitempty"`
+}
+
+// Maintainer is a maintainer of a chart.
+type Maintainer struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// File is a template or other file of a chart, with its path within the chart.
+type File struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// Hook is a hook of a release revision. The events and delete policies use the names of the helm.sh/hook and
+// helm.sh/hook-delete-policy annotations, e.g. pre-install and before-hook-creation.
+type Hook struct {
+	Name           string   `json:"name,omitempty"`
+	Kind           string   `json:"kind,omitempty"`
+	Path           string   `json:"path,omitempty"`
+	Manifest       string   `json:"manifest,omitempty"`
+	Events         []string `json:"events,omitempty"`
+	Weight         int      `json:"weight,omitempty"`
+	DeletePolicies []string `json:"delete_policies,omitempty"`
+}
+
+// Time is a time that is encoded as an empty string when it is zero, the same way as the time type of Helm 3 does, so
+// that the encoded releases match the ones that Helm 3 writes.
+type Time struct {
+	time.Time
+}
+
+// MarshalJSON encodes the time as an empty string if it is zero, and in RFC 3339 format otherwise.
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte(`""`), nil
+	}
+	return t.Time.MarshalJSON()
+}
+
+// UnmarshalJSON decodes an empty string or null as the zero time, and any other value as an RFC 3339 time.
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` || string(data) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	return t.Time.UnmarshalJSON(data)
+}
+
+// ReleaseSecretName returns the name of the Secret that Helm 3 stores the given revision of the named release in.
+func ReleaseSecretName(releaseName string, version int) string {
+	return fmt.Sprintf("sh.helm.release.v1.%s.v%d", releaseName, version)
+}
+
+// EncodeRecordE encodes the release in the format that Helm 3 stores in the release key of the Secret: base64 encoded,
+// gzipped JSON.
+func EncodeRecordE(release *Release) ([]byte, error) {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buffer.Bytes())), nil
+}
+
+// DecodeRecordE decodes a release in the format that Helm 3 stores in the release key of the Secret. This is the
+// inverse of EncodeRecordE.
+func DecodeRecordE(data []byte) (*Release, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(decoded))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decoded, err = ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &Release{}
+	if err := json.Unmarshal(decoded, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// NewReleaseSecretE returns the Secret that Helm 3 would store the release in: a Secret of the release type in the
+// namespace of the release, with the same name and labels as Helm 3 sets.
+func NewReleaseSecretE(release *Release) (*corev1.Secret, error) {
+	data, err := EncodeRecordE(release)
+	if err != nil {
+		return nil, err
+	}
+	status := StatusUnknown
+	if release.Info != nil && release.Info.Status != "" {
+		status = release.Info.Status
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: release.Namespace,
+			Name:      ReleaseSecretName(release.Name, release.Version),
+			Labels: map[string]string{
+				OwnerLabel:   OwnerLabelValue,
+				NameLabel:    release.Name,
+				StatusLabel:  status,
+				VersionLabel: strconv.Itoa(release.Version),
+			},
+		},
+		Type: ReleaseSecretType,
+		Data: map[string][]byte{ReleaseDataKey: data},
+	}, nil
+}
+
+// DecodeReleaseSecretE decodes the release stored in the given Helm 3 release Secret.
+func DecodeReleaseSecretE(secret *corev1.Secret) (*Release, error) {
+	data, hasData := secret.Data[ReleaseDataKey]
+	if !hasData {
+		return nil, MissingReleaseDataError{Namespace: secret.Namespace, SecretName: secret.Name}
+	}
+	release, err := DecodeRecordE(data)
+	if err != nil {
+		return nil, InvalidReleaseRecordError{Namespace: secret.Namespace, SecretName: secret.Name, Underlying: err}
+	}
+	return release, nil
+}
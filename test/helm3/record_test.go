@@ -0,0 +1,67 @@
+package helm3
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseSecretRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	release := &Release{
+		Name: "web",
+		Info: &Info{
+			FirstDeployed: Time{time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)},
+			LastDeployed:  Time{time.Date(2019, 3, 1, 14, 0, 0, 0, time.UTC)},
+			Description:   "Upgrade complete",
+			Status:        StatusDeployed,
+		},
+		Chart: &Chart{
+			Metadata:  &Metadata{Name: "deployment", Version: "0.1.0", APIVersion: "v1"},
+			Templates: []*File{{Name: "templates/deployment.yaml", Data: []byte("kind: Deployment\n")}},
+			Values:    map[string]interface{}{"replicaCount": float64(1)},
+		},
+		Config:    map[string]interface{}{"image": map[string]interface{}{"tag": "3.0"}},
+		Manifest:  "---\nkind: Deployment\n",
+		Hooks:     []*Hook{{Name: "web-pre-install", Events: []string{"pre-install"}, Weight: -5}},
+		Version:   3,
+		Namespace: "resources",
+	}
+
+	secret, err := NewReleaseSecretE(release)
+	require.NoError(t, err)
+	assert.Equal(t, "resources", secret.Namespace)
+	assert.Equal(t, "sh.helm.release.v1.web.v3", secret.Name)
+	assert.Equal(t, ReleaseSecretType, secret.Type)
+	assert.Equal(
+		t,
+		map[string]string{"owner": "helm", "name": "web", "status": "deployed", "version": "3"},
+		secret.Labels,
+	)
+
+	decoded, err := DecodeReleaseSecretE(secret)
+	require.NoError(t, err)
+	assert.Equal(t, release, decoded)
+
+	secret.Data[ReleaseDataKey] = []byte("not base64!")
+	_, err = DecodeReleaseSecretE(secret)
+	_, isInvalidRecordErr := err.(InvalidReleaseRecordError)
+	assert.True(t, isInvalidRecordErr, "Expected InvalidReleaseRecordError, got %T: %s", err, err)
+}
+
+func TestTimeEncodesZeroAsEmptyString(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(Info{Status: StatusDeployed})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"first_deployed": "", "last_deployed": "", "deleted": "", "status": "deployed"}`, string(data))
+
+	info := Info{}
+	require.NoError(t, json.Unmarshal(data, &info))
+	assert.True(t, info.FirstDeployed.IsZero())
+	assert.True(t, info.Deleted.IsZero())
+}
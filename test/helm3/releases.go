@@ -0,0 +1,136 @@
+package helm3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ReleaseSecretType is the type of the Secrets that Helm 3 stores each revision of a release in.
+	ReleaseSecretType = corev1.SecretType("helm.sh/release.v1")
+
+	// The labels that Helm 3 sets on each release Secret.
+	OwnerLabel   = "owner"
+	NameLabel    = "name"
+	VersionLabel = "version"
+	StatusLabel  = "status"
+
+	// OwnerLabelValue is the value of the owner label of the Helm 3 release Secrets. Note that Tiller labels its
+	// release records with the upper case OWNER=TILLER instead, so the two never match the same selector.
+	OwnerLabelValue = "helm"
+)
+
+// ReleaseSecret is a Secret that Helm 3 stores a revision of a release in. The release record itself is not decoded.
+type ReleaseSecret struct {
+	Namespace   string
+	Name        string
+	Type        corev1.SecretType
+	ReleaseName string
+	Version     int
+	Status      string
+}
+
+func (secret ReleaseSecret) String() string {
+	return fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
+}
+
+// ReleaseLabelSelector returns the label selector of the Helm 3 release Secrets of the named release.
+func ReleaseLabelSelector(releaseName string) string {
+	return fmt.Sprintf("%s=%s,%s=%s", OwnerLabel, OwnerLabelValue, NameLabel, releaseName)
+}
+
+// ListReleaseSecrets lists the Helm 3 release Secrets of the named release across all namespaces, sorted by namespace
+// and version. The namespace of the kubectl options is ignored. This will fail the test if there is an error.
+func ListReleaseSecrets(t *testing.T, options *k8s.KubectlOptions, releaseName string) []ReleaseSecret {
+	secrets, err := ListReleaseSecretsE(t, options, releaseName)
+	require.NoError(t, err)
+	return secrets
+}
+
+// ListReleaseSecretsE lists the Helm 3 release Secrets of the named release across all namespaces, sorted by namespace
+// and version. The namespace of the kubectl options is ignored.
+func ListReleaseSecretsE(t *testing.T, options *k8s.KubectlOptions, releaseName string) ([]ReleaseSecret, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return ListReleaseSecretsFromClientE(clientset, releaseName)
+}
+
+// ListReleaseSecretsFromClientE lists the Helm 3 release Secrets of the named release across all namespaces using the
+// given client, sorted by namespace and version. Looking across all namespaces is what lets the tests catch release
+// Secrets that end up outside of the namespace of the release.
+func ListReleaseSecretsFromClientE(clientset kubernetes.Interface, releaseName string) ([]ReleaseSecret, error) {
+	secrets, err := clientset.CoreV1().Secrets(metav1.NamespaceAll).List(
+		metav1.ListOptions{LabelSelector: ReleaseLabelSelector(releaseName)},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseSecrets := []ReleaseSecret{}
+	for _, secret := range secrets.Items {
+		// Helm 3 always sets the version label, but a Secret that was labeled by hand may not have it. Leave the
+		// version at 0 in that case, so that the Secret still shows up in the checks.
+		version, _ := strconv.Atoi(secret.Labels[VersionLabel])
+		releaseSecrets = append(releaseSecrets, ReleaseSecret{
+			Namespace:   secret.Namespace,
+			Name:        secret.Name,
+			Type:        secret.Type,
+			ReleaseName: secret.Labels[NameLabel],
+			Version:     version,
+			Status:      secret.Labels[StatusLabel],
+		})
+	}
+	sort.Slice(releaseSecrets, func(i, j int) bool {
+		if releaseSecrets[i].Namespace != releaseSecrets[j].Namespace {
+			return releaseSecrets[i].Namespace < releaseSecrets[j].Namespace
+		}
+		return releaseSecrets[i].Version < releaseSecrets[j].Version
+	})
+	return releaseSecrets, nil
+}
+
+// CheckReleaseSecretsInNamespace verifies that Helm 3 stored the named release in Secrets of the release type in the
+// given namespace, and nowhere else. This will fail the test if it did not.
+func CheckReleaseSecretsInNamespace(t *testing.T, secrets []ReleaseSecret, releaseName string, namespace string) {
+	require.NoError(t, CheckReleaseSecretsInNamespaceE(secrets, releaseName, namespace))
+}
+
+// CheckReleaseSecretsInNamespaceE verifies that Helm 3 stored the named release in Secrets of the release type in the
+// given namespace, and nowhere else. Returns a ReleaseNotFoundError if the release has no Secrets, a
+// ReleaseSecretsOutsideNamespaceError if any of them are in another namespace, or an InvalidReleaseSecretTypeError if
+// any of them has the wrong type.
+func CheckReleaseSecretsInNamespaceE(secrets []ReleaseSecret, releaseName string, namespace string) error {
+	releaseSecrets := []ReleaseSecret{}
+	outside := []ReleaseSecret{}
+	for _, secret := range secrets {
+		if secret.ReleaseName != releaseName {
+			continue
+		}
+		releaseSecrets = append(releaseSecrets, secret)
+		if secret.Namespace != namespace {
+			outside = append(outside, secret)
+		}
+	}
+	if len(releaseSecrets) == 0 {
+		return ReleaseNotFoundError{ReleaseName: releaseName}
+	}
+	if len(outside) > 0 {
+		return ReleaseSecretsOutsideNamespaceError{ReleaseName: releaseName, Namespace: namespace, Secrets: outside}
+	}
+	for _, secret := range releaseSecrets {
+		if secret.Type != ReleaseSecretType {
+			return InvalidReleaseSecretTypeError{Secret: secret}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package helm3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestReleaseSecret(namespace string, releaseName string, version string, status string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      "sh.helm.release.v1." + releaseName + ".v" + version,
+			Labels: map[string]string{
+				OwnerLabel:   OwnerLabelValue,
+				NameLabel:    releaseName,
+				VersionLabel: version,
+				StatusLabel:  status,
+			},
+		},
+		Type: ReleaseSecretType,
+		Data: map[string][]byte{"release": []byte("not decoded")},
+	}
+}
+
+func TestListReleaseSecretsAcrossNamespaces(t *testing.T) {
+	t.Parallel()
+
+	// Tiller labels its release records differently, so they are not listed even if the release name matches.
+	tillerRecord := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "tiller",
+			Name:      "web.v1",
+			Labels:    map[string]string{"OWNER": "TILLER", "NAME": "web", "VERSION": "1"},
+		},
+	}
+	clientset := fake.NewSimpleClientset(
+		newTestReleaseSecret("resources", "web", "2", "deployed"),
+		newTestReleaseSecret("resources", "web", "1", "superseded"),
+		newTestReleaseSecret("resources", "other", "1", "deployed"),
+		newTestReleaseSecret("default", "web", "1", "deployed"),
+		tillerRecord,
+	)
+
+	secrets, err := ListReleaseSecretsFromClientE(clientset, "web")
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]ReleaseSecret{
+			{
+				Namespace:   "default",
+				Name:        "sh.helm.release.v1.web.v1",
+				Type:        ReleaseSecretType,
+				ReleaseName: "web",
+				Version:     1,
+				Status:      "deployed",
+			},
+			{
+				Namespace:   "resources",
+				Name:        "sh.helm.release.v1.web.v1",
+				Type:        ReleaseSecretType,
+				ReleaseName: "web",
+				Version:     1,
+				Status:      "superseded",
+			},
+			{
+				Namespace:   "resources",
+				Name:        "sh.helm.release.v1.web.v2",
+				Type:        ReleaseSecretType,
+				ReleaseName: "web",
+				Version:     2,
+				Status:      "deployed",
+			},
+		},
+		secrets,
+	)
+
+	err = CheckReleaseSecretsInNamespaceE(secrets, "web", "resources")
+	outsideErr, isOutsideErr := err.(ReleaseSecretsOutsideNamespaceError)
+	require.True(t, isOutsideErr, "Expected ReleaseSecretsOutsideNamespaceError, got %T: %s", err, err)
+	assert.Equal(t, []ReleaseSecret{secrets[0]}, outsideErr.Secrets)
+
+	CheckReleaseSecretsInNamespace(t, secrets[1:], "web", "resources")
+}
+
+func TestCheckReleaseSecretsInNamespaceRejectsMissingAndMistypedSecrets(t *testing.T) {
+	t.Parallel()
+
+	err := CheckReleaseSecretsInNamespaceE([]ReleaseSecret{}, "web", "resources")
+	_, isNotFoundErr := err.(ReleaseNotFoundError)
+	assert.True(t, isNotFoundErr, "Expected ReleaseNotFoundError, got %T: %s", err, err)
+
+	secrets := []ReleaseSecret{{Namespace: "resources", Name: "web", Type: corev1.SecretTypeOpaque, ReleaseName: "web"}}
+	err = CheckReleaseSecretsInNamespaceE(secrets, "web", "resources")
+	_, isInvalidTypeErr := err.(InvalidReleaseSecretTypeError)
+	assert.True(t, isInvalidTypeErr, "Expected InvalidReleaseSecretTypeError, got %T: %s", err, err)
+}
@@ -0,0 +1,90 @@
+package helm3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// BinaryEnvVar is the environment variable to set to the path of the Helm 3 client, since the helm binary on the
+	// PATH is the Helm 2 client that the Tiller tests use.
+	BinaryEnvVar = "HELM3_BINARY"
+
+	// DefaultBinary is the name of the Helm 3 client when BinaryEnvVar is not set.
+	DefaultBinary = "helm3"
+
+	// VersionPrefix is the prefix of the short version of every Helm 3 client.
+	VersionPrefix = "v3."
+)
+
+// Binary returns the path to the Helm 3 client, from BinaryEnvVar or DefaultBinary.
+func Binary() string {
+	if binary := os.Getenv(BinaryEnvVar); binary != "" {
+		return binary
+	}
+	return DefaultBinary
+}
+
+// RunHelm runs the Helm 3 client with the given args, using the helm home for the config, cache, and data of the client
+// and the cluster from the kubectl options. This will fail the test if helm exits with a non zero exit code.
+func RunHelm(t *testing.T, options *k8s.KubectlOptions, helmHome string, args ...string) *helmhome.CommandResult {
+	result, err := RunHelmE(t, options, helmHome, args...)
+	require.NoError(t, err)
+	return result
+}
+
+// RunHelmE runs the Helm 3 client with the given args, using the helm home for the config, cache, and data of the
+// client and the cluster from the kubectl options. Unlike Helm 2, every Helm 3 command supports the --namespace flag,
+// which is also the namespace that the releases are stored in. If helm exits with a non zero exit code, this returns
+// the result along with a helmhome.CommandFailedError, so that tests can assert on the error output.
+func RunHelmE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	helmHome string,
+	args ...string,
+) (*helmhome.CommandResult, error) {
+	return helmhome.RunCommandE(t, HelmCommand(options, helmHome, args...))
+}
+
+// HelmCommand returns the command to run the Helm 3 client with the given args. Helm 3 has no env file or Tiller
+// settings, so the command only points the XDG directories of the client into the helm home, which keeps the test
+// isolated from the repositories and plugins of the user. Each arg is passed to helm as is, without going through a
+// shell.
+func HelmCommand(options *k8s.KubectlOptions, helmHome string, args ...string) shell.Command {
+	return shell.Command{
+		Command: Binary(),
+		Args:    append(helmhome.ClusterArgs(options, options.Namespace), args...),
+		Env: map[string]string{
+			"HELM_CACHE_HOME":  filepath.Join(helmHome, "cache"),
+			"HELM_CONFIG_HOME": filepath.Join(helmHome, "config"),
+			"HELM_DATA_HOME":   filepath.Join(helmHome, "data"),
+		},
+	}
+}
+
+// CheckVersion verifies that the client returned by Binary is a Helm 3 client. This will fail the test if it is not.
+func CheckVersion(t *testing.T, helmHome string) {
+	require.NoError(t, CheckVersionE(t, helmHome))
+}
+
+// CheckVersionE verifies that the client returned by Binary is a Helm 3 client, and returns a NotHelm3Error if it is
+// not. A Helm 2 client would try to find Tiller instead, and fail with confusing errors later in the test. Only the
+// client version is requested, so this doesn't need a cluster.
+func CheckVersionE(t *testing.T, helmHome string) error {
+	result, err := RunHelmE(t, &k8s.KubectlOptions{}, helmHome, "version", "--client", "--short")
+	if err != nil {
+		return err
+	}
+	version := strings.TrimSpace(result.Stdout)
+	if !strings.HasPrefix(version, VersionPrefix) {
+		return NotHelm3Error{Binary: Binary(), Version: version}
+	}
+	return nil
+}
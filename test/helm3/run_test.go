@@ -0,0 +1,44 @@
+package helm3
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelmCommandIsolatesClientDirectories(t *testing.T) {
+	t.Parallel()
+
+	helmHome := filepath.Join("/tmp", "helm 3 home")
+	options := k8s.NewKubectlOptions("my context", "/path/to/kube config", "resources")
+	cmd := HelmCommand(options, helmHome, "install", "my-release", "./chart", "--set", "greeting=hello world")
+	assert.Equal(t, Binary(), cmd.Command)
+	assert.Equal(
+		t,
+		[]string{
+			"--kube-context", "my context",
+			"--kubeconfig", "/path/to/kube config",
+			"--namespace", "resources",
+			"install", "my-release", "./chart", "--set", "greeting=hello world",
+		},
+		cmd.Args,
+	)
+	assert.Equal(
+		t,
+		map[string]string{
+			"HELM_CACHE_HOME":  filepath.Join(helmHome, "cache"),
+			"HELM_CONFIG_HOME": filepath.Join(helmHome, "config"),
+			"HELM_DATA_HOME":   filepath.Join(helmHome, "data"),
+		},
+		cmd.Env,
+	)
+}
+
+func TestHelmCommandLeavesOutEmptyOptions(t *testing.T) {
+	t.Parallel()
+
+	cmd := HelmCommand(&k8s.KubectlOptions{}, "/tmp/helm", "version", "--client", "--short")
+	assert.Equal(t, []string{"version", "--client", "--short"}, cmd.Args)
+}
@@ -0,0 +1,174 @@
+package test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/releases"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The test charts under ./charts. Each chart exercises a different part of the permissions that the
+// k8s-namespace-roles module grants Tiller in the resource namespace.
+const (
+	localChartsDir = "./charts"
+
+	// All the test charts use the same version, so that we know the name of the packaged chart.
+	localChartVersion = "0.1.0"
+
+	// Deployment and Service, for the core and apps API groups.
+	deploymentChart = "deployment"
+
+	// ServiceAccount, Role, and RoleBinding, for the rbac.authorization.k8s.io API group.
+	rbacChart = "rbac"
+
+	// PodDisruptionBudget, for the policy API group.
+	pdbChart = "pdb"
+
+	// ConfigMap in the default namespace, which Tiller should not have access to.
+	crossNamespaceChart = "cross-namespace"
+)
+
+// validateLocalChartInstalls installs each of the test charts through the helm client configured in the helm home, and
+// verifies that the charts that stay within the namespace of the kubectl options install successfully while the chart
+// that writes to another namespace is rejected. The releases are deleted at the end.
+func validateLocalChartInstalls(t *testing.T, options *k8s.KubectlOptions, helmHome string) {
+	packageDir, err := ioutil.TempDir("", "helm-test-charts")
+	require.NoError(t, err)
+	defer os.RemoveAll(packageDir)
+
+	for _, chartName := range []string{deploymentChart, rbacChart, pdbChart} {
+		releaseName := localChartReleaseName(chartName)
+		defer deleteHelmRelease(t, options, helmHome, releaseName)
+		installLocalChart(t, options, helmHome, packageDir, chartName, releaseName, "--wait")
+	}
+
+	releaseName := localChartReleaseName(crossNamespaceChart)
+	defer deleteHelmRelease(t, options, helmHome, releaseName)
+	err = installLocalChartE(t, options, helmHome, packageDir, crossNamespaceChart, releaseName)
+	assertForbiddenInNamespace(t, err, "default")
+}
+
+// assertForbiddenInNamespace checks that the helm command failed because the API server forbade a request in the given
+// namespace, and not for some unrelated reason such as a broken chart or an unreachable Tiller.
+func assertForbiddenInNamespace(t *testing.T, err error, namespace string) {
+	require.Error(t, err, "Expected the request in namespace %s to be forbidden", namespace)
+	commandFailedErr, isCommandFailedErr := err.(helmhome.CommandFailedError)
+	require.True(t, isCommandFailedErr, "Expected helmhome.CommandFailedError, got %T: %s", err, err)
+	assert.Contains(t, commandFailedErr.Stderr, "forbidden")
+	assert.Contains(t, commandFailedErr.Stderr, fmt.Sprintf("namespace \"%s\"", namespace))
+}
+
+// validateReleaseHistoryMax installs the deployment test chart, upgrades the release more times than the history max
+// allows, and verifies through the release records in the Tiller namespace that Tiller pruned the oldest revisions. The
+// release is deleted at the end.
+func validateReleaseHistoryMax(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	tillerKubectlOptions *k8s.KubectlOptions,
+	helmHome string,
+	historyMax int,
+) {
+	packageDir, err := ioutil.TempDir("", "helm-test-charts")
+	require.NoError(t, err)
+	defer os.RemoveAll(packageDir)
+
+	chartPath, err := packageLocalChartE(t, options, helmHome, packageDir, deploymentChart)
+	require.NoError(t, err)
+	releaseName := localChartReleaseName(deploymentChart)
+	defer deleteHelmRelease(t, options, helmHome, releaseName)
+	helmhome.RunHelm(t, options, helmHome, "install", chartPath, "--name", releaseName, "--wait")
+
+	numRevisions := historyMax + 2
+	for revision := 2; revision <= numRevisions; revision++ {
+		helmhome.RunHelm(t, options, helmHome, "upgrade", releaseName, chartPath, "--wait")
+	}
+
+	revisions := releases.FilterByName(releases.ListReleases(t, tillerKubectlOptions), releaseName)
+	releases.CheckHistoryMax(t, revisions, releaseName, historyMax)
+	// Tiller prunes the least recent revisions, so only the latest revision is deployed, and the rest are superseded.
+	for i, revision := range revisions {
+		expectedVersion := int32(numRevisions - len(revisions) + i + 1)
+		assert.Equal(t, expectedVersion, revision.Version)
+		assert.Equal(t, deploymentChart, revision.ChartName)
+		assert.Equal(t, options.Namespace, revision.Namespace)
+		if i == len(revisions)-1 {
+			assert.Equal(t, releases.StatusDeployed, revision.Status)
+		} else {
+			assert.Equal(t, releases.StatusSuperseded, revision.Status)
+		}
+	}
+}
+
+// installLocalChart packages the named chart under ./charts and installs it with the given release name. This will
+// fail the test if the install fails.
+func installLocalChart(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	helmHome string,
+	packageDir string,
+	chartName string,
+	releaseName string,
+	args ...string,
+) {
+	require.NoError(t, installLocalChartE(t, options, helmHome, packageDir, chartName, releaseName, args...))
+}
+
+// installLocalChartE packages the named chart under ./charts and installs it with the given release name.
+func installLocalChartE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	helmHome string,
+	packageDir string,
+	chartName string,
+	releaseName string,
+	args ...string,
+) error {
+	chartPath, err := packageLocalChartE(t, options, helmHome, packageDir, chartName)
+	if err != nil {
+		return err
+	}
+	installArgs := append([]string{"install", chartPath, "--name", releaseName}, args...)
+	_, err = helmhome.RunHelmE(t, options, helmHome, installArgs...)
+	return err
+}
+
+// packageLocalChartE packages the named chart under ./charts into the package dir and returns the path to the packaged
+// chart.
+func packageLocalChartE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	helmHome string,
+	packageDir string,
+	chartName string,
+) (string, error) {
+	chartDir, err := filepath.Abs(filepath.Join(localChartsDir, chartName))
+	if err != nil {
+		return "", err
+	}
+	if _, err := helmhome.RunHelmE(t, options, helmHome, "package", chartDir, "--destination", packageDir); err != nil {
+		return "", err
+	}
+	return filepath.Join(packageDir, fmt.Sprintf("%s-%s.tgz", chartName, localChartVersion)), nil
+}
+
+// deleteHelmRelease purges the release. Errors are only logged, because the release may not exist if the install
+// failed.
+func deleteHelmRelease(t *testing.T, options *k8s.KubectlOptions, helmHome string, releaseName string) {
+	if _, err := helmhome.RunHelmE(t, options, helmHome, "delete", releaseName, "--purge"); err != nil {
+		logger.Logf(t, "Error deleting helm release %s: %s", releaseName, err)
+	}
+}
+
+func localChartReleaseName(chartName string) string {
+	return fmt.Sprintf("%s-%s", chartName, strings.ToLower(random.UniqueId()))
+}
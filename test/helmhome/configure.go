@@ -0,0 +1,175 @@
+package helmhome
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// These constants mirror the naming and labeling conventions that the root module and kubergrunt use for the Tiller
+// client credentials Secrets.
+const (
+	TillerNamespaceLabel       = "gruntwork.io/tiller-namespace"
+	TillerCredentialsLabel     = "gruntwork.io/tiller-credentials"
+	TillerCredentialsTypeLabel = "gruntwork.io/tiller-credentials-type"
+	ClientCredentialsType      = "client"
+
+	// The file names of the TLS files that the helm client looks for in the helm home when TLS is enabled.
+	CACertFileName     = "ca.pem"
+	ClientCertFileName = "cert.pem"
+	ClientKeyFileName  = "key.pem"
+
+	// EnvFileName is the name of the file in the helm home that can be sourced to set up the environment for helm.
+	EnvFileName = "env"
+
+	// StableRepoURL is the URL of the archive of the stable chart repo, which replaced the retired
+	// kubernetes-charts.storage.googleapis.com repo that helm 2 defaults to.
+	StableRepoURL = "https://charts.helm.sh/stable"
+)
+
+// envFileTemplate is the template used to render the env file in the helm home. This matches what `kubergrunt helm
+// configure` generates, except that the helm home is quoted, as it is a path that may contain spaces.
+var envFileTemplate = template.Must(template.New("env").Funcs(template.FuncMap{"shellQuote": shellQuote}).Parse(
+	`export HELM_HOME={{ shellQuote .HelmHome }}
+export TILLER_NAMESPACE={{ .TillerNamespace }}
+export HELM_TLS_VERIFY=true
+export HELM_TLS_ENABLE=true
+`,
+))
+
+// shellQuote wraps the value in single quotes, so that a POSIX shell takes it literally. Single quotes in the value are
+// closed, escaped, and reopened, which unquoteEnvValue reverses.
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// ClientCertSecretName returns the name of the Secret that holds the client TLS certificate key pair for the given RBAC
+// entity. This follows the `tiller-client-${md5(local.rbac_entity_id)}-certs` convention of the root module, where the
+// RBAC entity ID is the user name, group name, or ServiceAccount in `NAMESPACE/NAME` format.
+func ClientCertSecretName(rbacEntityID string) string {
+	return fmt.Sprintf("tiller-client-%x-certs", md5.Sum([]byte(rbacEntityID)))
+}
+
+// ConfigureHelmHome sets up the helm home so that the helm client can talk to the Tiller deployed in the namespace of
+// the kubectl options using the client TLS certificates granted to the given RBAC entity. This is the native
+// equivalent of `kubergrunt helm configure`. This will fail the test if there is an error.
+func ConfigureHelmHome(t *testing.T, tillerOptions *k8s.KubectlOptions, helmHome string, rbacEntityID string) {
+	require.NoError(t, ConfigureHelmHomeE(t, tillerOptions, helmHome, rbacEntityID))
+}
+
+// ConfigureHelmHomeE sets up the helm home so that the helm client can talk to the Tiller deployed in the namespace of
+// the kubectl options using the client TLS certificates granted to the given RBAC entity. This is the native
+// equivalent of `kubergrunt helm configure`.
+func ConfigureHelmHomeE(t *testing.T, tillerOptions *k8s.KubectlOptions, helmHome string, rbacEntityID string) error {
+	if err := shell.RunCommandE(t, InitClientOnlyCommand(helmHome)); err != nil {
+		return err
+	}
+
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, tillerOptions)
+	if err != nil {
+		return err
+	}
+	logger.Logf(t, "Writing Tiller client credentials for %s to helm home %s", rbacEntityID, helmHome)
+	return WriteHelmHomeE(clientset, helmHome, tillerOptions.Namespace, rbacEntityID)
+}
+
+// InitClientOnlyCommand returns the command to initialize the helm home directory structure and repository config,
+// without touching the cluster or the network. Without --skip-refresh, helm init downloads the index of the stable
+// repo, which fails in air-gapped environments and since the original stable repo was retired. The stable repo URL
+// is only recorded in the repository config, so that a later `helm repo update` uses the archive of the stable repo.
+func InitClientOnlyCommand(helmHome string) shell.Command {
+	return shell.Command{
+		Command: "helm",
+		Args: []string{
+			"init",
+			"--client-only",
+			"--skip-refresh",
+			"--stable-repo-url", StableRepoURL,
+			"--home", helmHome,
+		},
+	}
+}
+
+// WriteHelmHomeE looks up the client credentials Secret for the given RBAC entity in the tiller namespace, writes the
+// TLS certificate key pair into the helm home, and renders the env file that can be sourced to configure helm to talk
+// to Tiller over TLS. This assumes the Secret uses the default filename bases of the k8s-helm-client-tls-certs module.
+func WriteHelmHomeE(
+	clientset kubernetes.Interface,
+	helmHome string,
+	tillerNamespace string,
+	rbacEntityID string,
+) error {
+	secretName := ClientCertSecretName(rbacEntityID)
+	secret, err := clientset.CoreV1().Secrets(tillerNamespace).Get(secretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	expectedLabels := map[string]string{
+		TillerNamespaceLabel:       tillerNamespace,
+		TillerCredentialsLabel:     "true",
+		TillerCredentialsTypeLabel: ClientCredentialsType,
+	}
+	for key, expected := range expectedLabels {
+		if actual := secret.Labels[key]; actual != expected {
+			return NotClientCredentialsSecretError{
+				Namespace:  tillerNamespace,
+				SecretName: secretName,
+				LabelKey:   key,
+				Expected:   expected,
+				Actual:     actual,
+			}
+		}
+	}
+
+	// Map the keys in the Secret to the file names the helm client expects.
+	secretKeyToFile := map[string]string{
+		fmt.Sprintf("%s.crt", tlscerts.DefaultCAFilenameBase):     CACertFileName,
+		fmt.Sprintf("%s.crt", tlscerts.DefaultClientFilenameBase): ClientCertFileName,
+		fmt.Sprintf("%s.pem", tlscerts.DefaultClientFilenameBase): ClientKeyFileName,
+	}
+	for key, fileName := range secretKeyToFile {
+		data, hasKey := secret.Data[key]
+		if !hasKey {
+			return tlscerts.MissingSecretKeyError{Namespace: tillerNamespace, SecretName: secretName, Key: key}
+		}
+		if err := ioutil.WriteFile(filepath.Join(helmHome, fileName), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return writeEnvFile(helmHome, tillerNamespace)
+}
+
+// writeEnvFile renders the env file into the helm home.
+func writeEnvFile(helmHome string, tillerNamespace string) error {
+	absHelmHome, err := filepath.Abs(helmHome)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = envFileTemplate.Execute(&buf, struct {
+		HelmHome        string
+		TillerNamespace string
+	}{
+		HelmHome:        absHelmHome,
+		TillerNamespace: tillerNamespace,
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(helmHome, EnvFileName), buf.Bytes(), 0600)
+}
@@ -0,0 +1,148 @@
+package helmhome
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testTillerNamespace = "tiller-ns"
+	testRBACEntityID    = "minikube"
+)
+
+func TestClientCertSecretNameMatchesTerraformNaming(t *testing.T) {
+	t.Parallel()
+
+	// Expected value computed with `md5("minikube")` in terraform console.
+	assert.Equal(t, "tiller-client-054358cefa61ead5951990079af92856-certs", ClientCertSecretName(testRBACEntityID))
+}
+
+func TestInitClientOnlyCommandSkipsRepoRefresh(t *testing.T) {
+	t.Parallel()
+
+	cmd := InitClientOnlyCommand("/path/to/helm home")
+	assert.Equal(t, "helm", cmd.Command)
+	assert.Equal(
+		t,
+		[]string{
+			"init",
+			"--client-only",
+			"--skip-refresh",
+			"--stable-repo-url", "https://charts.helm.sh/stable",
+			"--home", "/path/to/helm home",
+		},
+		cmd.Args,
+	)
+}
+
+func TestWriteHelmHomeWritesCertsAndEnvFile(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+
+	clientset := fake.NewSimpleClientset(newTestClientSecret(testRBACEntityID, clientCredentialsLabels()))
+	require.NoError(t, WriteHelmHomeE(clientset, helmHome, testTillerNamespace, testRBACEntityID))
+
+	assertFileContents(t, filepath.Join(helmHome, CACertFileName), "CA CERT")
+	assertFileContents(t, filepath.Join(helmHome, ClientCertFileName), "CLIENT CERT")
+	assertFileContents(t, filepath.Join(helmHome, ClientKeyFileName), "CLIENT KEY")
+	assertFileContents(
+		t,
+		filepath.Join(helmHome, EnvFileName),
+		fmt.Sprintf(
+			"export HELM_HOME='%s'\nexport TILLER_NAMESPACE=%s\nexport HELM_TLS_VERIFY=true\nexport HELM_TLS_ENABLE=true\n",
+			helmHome,
+			testTillerNamespace,
+		),
+	)
+}
+
+func TestWriteHelmHomeRejectsSecretWithoutClientLabels(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+
+	labels := clientCredentialsLabels()
+	labels[TillerCredentialsTypeLabel] = "server"
+	clientset := fake.NewSimpleClientset(newTestClientSecret(testRBACEntityID, labels))
+	err := WriteHelmHomeE(clientset, helmHome, testTillerNamespace, testRBACEntityID)
+	require.Error(t, err)
+	labelErr, isLabelErr := err.(NotClientCredentialsSecretError)
+	require.True(t, isLabelErr, "Expected NotClientCredentialsSecretError, got %T: %s", err, err)
+	assert.Equal(t, TillerCredentialsTypeLabel, labelErr.LabelKey)
+	assert.Equal(t, "server", labelErr.Actual)
+}
+
+func TestWriteHelmHomeRejectsSecretWithMissingKey(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+
+	secret := newTestClientSecret(testRBACEntityID, clientCredentialsLabels())
+	delete(secret.Data, "client.pem")
+	clientset := fake.NewSimpleClientset(secret)
+	err := WriteHelmHomeE(clientset, helmHome, testTillerNamespace, testRBACEntityID)
+	require.Error(t, err)
+	keyErr, isKeyErr := err.(tlscerts.MissingSecretKeyError)
+	require.True(t, isKeyErr, "Expected tlscerts.MissingSecretKeyError, got %T: %s", err, err)
+	assert.Equal(t, "client.pem", keyErr.Key)
+}
+
+func TestWriteHelmHomeErrorsWhenSecretIsForOtherEntity(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+
+	clientset := fake.NewSimpleClientset(newTestClientSecret("some-other-user", clientCredentialsLabels()))
+	assert.Error(t, WriteHelmHomeE(clientset, helmHome, testTillerNamespace, testRBACEntityID))
+}
+
+func createTempHelmHome(t *testing.T) string {
+	helmHome, err := ioutil.TempDir("", "helm-home")
+	require.NoError(t, err)
+	return helmHome
+}
+
+func clientCredentialsLabels() map[string]string {
+	return map[string]string{
+		TillerNamespaceLabel:       testTillerNamespace,
+		TillerCredentialsLabel:     "true",
+		TillerCredentialsTypeLabel: ClientCredentialsType,
+	}
+}
+
+func newTestClientSecret(rbacEntityID string, labels map[string]string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ClientCertSecretName(rbacEntityID),
+			Namespace: testTillerNamespace,
+			Labels:    labels,
+		},
+		Data: map[string][]byte{
+			"ca.crt":     []byte("CA CERT"),
+			"client.crt": []byte("CLIENT CERT"),
+			"client.pem": []byte("CLIENT KEY"),
+			"client.pub": []byte("CLIENT PUBLIC KEY"),
+		},
+	}
+}
+
+func assertFileContents(t *testing.T, path string, expected string) {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(data))
+}
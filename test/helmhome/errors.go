@@ -0,0 +1,52 @@
+package helmhome
+
+import "fmt"
+
+// NotClientCredentialsSecretError is returned when the Secret found for the RBAC entity does not have the labels that
+// mark it as Tiller client credentials for the given Tiller namespace.
+type NotClientCredentialsSecretError struct {
+	Namespace  string
+	SecretName string
+	LabelKey   string
+	Expected   string
+	Actual     string
+}
+
+func (err NotClientCredentialsSecretError) Error() string {
+	return fmt.Sprintf(
+		"Secret %s/%s is not a Tiller client credentials Secret: expected label %s=%s, got %q",
+		err.Namespace,
+		err.SecretName,
+		err.LabelKey,
+		err.Expected,
+		err.Actual,
+	)
+}
+
+// InvalidEnvFileLineError is returned when a line in the env file is not a supported env var assignment.
+type InvalidEnvFileLineError struct {
+	LineNumber int
+	Line       string
+}
+
+func (err InvalidEnvFileLineError) Error() string {
+	return fmt.Sprintf("Line %d of the env file is not a supported env var assignment: %s", err.LineNumber, err.Line)
+}
+
+// CommandFailedError is returned when a command exits with a non zero exit code.
+type CommandFailedError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+func (err CommandFailedError) Error() string {
+	return fmt.Sprintf(
+		"Command %s with args %q exited with code %d: %s",
+		err.Command,
+		err.Args,
+		err.ExitCode,
+		err.Stderr,
+	)
+}
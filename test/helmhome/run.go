@@ -0,0 +1,187 @@
+package helmhome
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/stretchr/testify/require"
+)
+
+// CommandResult holds the output of a command, with stdout and stderr captured separately.
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// RunHelm runs the helm client with the given args, using the environment from the env file in the helm home and the
+// cluster from the kubectl options. This will fail the test if helm exits with a non zero exit code.
+func RunHelm(t *testing.T, options *k8s.KubectlOptions, helmHome string, args ...string) *CommandResult {
+	result, err := RunHelmE(t, options, helmHome, args...)
+	require.NoError(t, err)
+	return result
+}
+
+// RunHelmE runs the helm client with the given args, using the environment from the env file in the helm home and the
+// cluster from the kubectl options. See HelmCommandE for how the namespace of the kubectl options is passed. If helm
+// exits with a non zero exit code, this returns the result along with a CommandFailedError, so that tests can assert on
+// the error output.
+func RunHelmE(t *testing.T, options *k8s.KubectlOptions, helmHome string, args ...string) (*CommandResult, error) {
+	cmd, err := HelmCommandE(options, helmHome, args...)
+	if err != nil {
+		return nil, err
+	}
+	return RunCommandE(t, cmd)
+}
+
+// namespacedCommands are the helm 2 commands that accept the --namespace flag. The other commands either look up
+// releases by name across namespaces (e.g. delete and history), or don't talk to the cluster at all (e.g. package), and
+// fail on the unknown flag.
+var namespacedCommands = map[string]bool{
+	"install":  true,
+	"upgrade":  true,
+	"list":     true,
+	"ls":       true,
+	"template": true,
+	"lint":     true,
+}
+
+// HelmCommandE returns the command to run the helm client with the given args, using the environment from the env file
+// in the helm home and the cluster from the kubectl options. The namespace of the kubectl options is only passed as
+// --namespace to the commands that support the flag, so the same options can be used for every command. Each arg is
+// passed to helm as is, without going through a shell.
+func HelmCommandE(options *k8s.KubectlOptions, helmHome string, args ...string) (shell.Command, error) {
+	env, err := ParseEnvFileE(filepath.Join(helmHome, EnvFileName))
+	if err != nil {
+		return shell.Command{}, err
+	}
+
+	namespace := ""
+	if len(args) > 0 && namespacedCommands[args[0]] {
+		namespace = options.Namespace
+	}
+
+	return shell.Command{
+		Command: "helm",
+		Args:    append(ClusterArgs(options, namespace), args...),
+		Env:     env,
+	}, nil
+}
+
+// ClusterArgs returns the global helm flags that point the client at the cluster of the kubectl options, and at the
+// given namespace if it is not empty. This is shared by the helm 2 and helm 3 clients, which use the same flags.
+func ClusterArgs(options *k8s.KubectlOptions, namespace string) []string {
+	args := []string{}
+	if options.ContextName != "" {
+		args = append(args, "--kube-context", options.ContextName)
+	}
+	if options.ConfigPath != "" {
+		args = append(args, "--kubeconfig", options.ConfigPath)
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	return args
+}
+
+// RunCommandE runs the command and returns stdout, stderr, and the exit code. The env vars of the command are merged
+// into the environment of the current process. If the command exits with a non zero exit code, this returns the result
+// along with a CommandFailedError.
+func RunCommandE(t *testing.T, command shell.Command) (*CommandResult, error) {
+	logger.Logf(t, "Running command %s with args %q", command.Command, command.Args)
+
+	cmd := exec.Command(command.Command, command.Args...)
+	cmd.Dir = command.WorkingDir
+	cmd.Env = os.Environ()
+	for key, value := range command.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	result := &CommandResult{Stdout: stdout.String(), Stderr: stderr.String()}
+	logger.Logf(t, "stdout:\n%s\nstderr:\n%s", result.Stdout, result.Stderr)
+	if runErr == nil {
+		return result, nil
+	}
+
+	exitErr, isExitErr := runErr.(*exec.ExitError)
+	if !isExitErr {
+		return nil, runErr
+	}
+	if status, hasStatus := exitErr.Sys().(syscall.WaitStatus); hasStatus {
+		result.ExitCode = status.ExitStatus()
+	} else {
+		result.ExitCode = -1
+	}
+	return result, CommandFailedError{
+		Command:  command.Command,
+		Args:     command.Args,
+		ExitCode: result.ExitCode,
+		Stderr:   result.Stderr,
+	}
+}
+
+// ParseEnvFileE reads the env file at the given path and returns the env vars it sets. See ParseEnvFile for the
+// supported syntax.
+func ParseEnvFileE(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEnvFile(string(data))
+}
+
+// ParseEnvFile parses the contents of a POSIX shell env file, such as the one written to the helm home, into a map of
+// env vars. Each line is expected to be an assignment of the form `KEY=VALUE` or `export KEY=VALUE`, where the value
+// may be wrapped in single or double quotes. Blank lines and comments are ignored.
+func ParseEnvFile(contents string) (map[string]string, error) {
+	env := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.ContainsAny(parts[0], " \t") {
+			return nil, InvalidEnvFileLineError{LineNumber: lineNumber, Line: scanner.Text()}
+		}
+		value, err := unquoteEnvValue(parts[1])
+		if err != nil {
+			return nil, InvalidEnvFileLineError{LineNumber: lineNumber, Line: scanner.Text()}
+		}
+		env[parts[0]] = value
+	}
+	return env, scanner.Err()
+}
+
+// unquoteEnvValue strips the quotes around an env var value. Single quoted values are taken literally, apart from the
+// escaped single quotes that shellQuote writes, while double quoted values support the usual escapes.
+func unquoteEnvValue(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.Replace(value[1:len(value)-1], `'\''`, "'", -1), nil
+	}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return strconv.Unquote(value)
+	}
+	return value, nil
+}
@@ -0,0 +1,151 @@
+package helmhome
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvFileReadsRenderedEnvFile(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+
+	require.NoError(t, writeEnvFile(helmHome, testTillerNamespace))
+	env, err := ParseEnvFileE(filepath.Join(helmHome, EnvFileName))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string]string{
+			"HELM_HOME":        helmHome,
+			"TILLER_NAMESPACE": testTillerNamespace,
+			"HELM_TLS_VERIFY":  "true",
+			"HELM_TLS_ENABLE":  "true",
+		},
+		env,
+	)
+}
+
+func TestParseEnvFileReadsQuotedHelmHome(t *testing.T) {
+	t.Parallel()
+
+	tempDir := createTempHelmHome(t)
+	defer os.RemoveAll(tempDir)
+	helmHome := filepath.Join(tempDir, "it's a helm home")
+	require.NoError(t, os.Mkdir(helmHome, 0700))
+
+	require.NoError(t, writeEnvFile(helmHome, testTillerNamespace))
+	env, err := ParseEnvFileE(filepath.Join(helmHome, EnvFileName))
+	require.NoError(t, err)
+	assert.Equal(t, helmHome, env["HELM_HOME"])
+}
+
+func TestParseEnvFileHandlesQuotesAndComments(t *testing.T) {
+	t.Parallel()
+
+	env, err := ParseEnvFile(`
+# A comment
+export SINGLE='a "literal" $value'
+DOUBLE="with spaces\tand escapes"
+  export EMPTY=
+EQUALS=a=b
+`)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string]string{
+			"SINGLE": `a "literal" $value`,
+			"DOUBLE": "with spaces\tand escapes",
+			"EMPTY":  "",
+			"EQUALS": "a=b",
+		},
+		env,
+	)
+}
+
+func TestParseEnvFileRejectsInvalidLines(t *testing.T) {
+	t.Parallel()
+
+	for _, contents := range []string{"not an assignment", "export =value", "FOO BAR=baz", `FOO="unterminated\"`} {
+		_, err := ParseEnvFile(contents)
+		_, isInvalidLineErr := err.(InvalidEnvFileLineError)
+		assert.True(t, isInvalidLineErr, "Expected InvalidEnvFileLineError for %q, got %v", contents, err)
+	}
+}
+
+func TestHelmCommandDoesNotSplitArgs(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+	require.NoError(t, writeEnvFile(helmHome, testTillerNamespace))
+
+	options := k8s.NewKubectlOptions("my context", "/path/to/kube config", "resources")
+	cmd, err := HelmCommandE(options, helmHome, "install", "./chart", "--set", "greeting=hello world")
+	require.NoError(t, err)
+	assert.Equal(t, "helm", cmd.Command)
+	assert.Equal(
+		t,
+		[]string{
+			"--kube-context", "my context",
+			"--kubeconfig", "/path/to/kube config",
+			"--namespace", "resources",
+			"install", "./chart", "--set", "greeting=hello world",
+		},
+		cmd.Args,
+	)
+	assert.Equal(t, testTillerNamespace, cmd.Env["TILLER_NAMESPACE"])
+}
+
+func TestHelmCommandOnlyPassesNamespaceToNamespacedCommands(t *testing.T) {
+	t.Parallel()
+
+	helmHome := createTempHelmHome(t)
+	defer os.RemoveAll(helmHome)
+	require.NoError(t, writeEnvFile(helmHome, testTillerNamespace))
+
+	options := k8s.NewKubectlOptions("", "", "resources")
+	testCases := []struct {
+		args         []string
+		expectedArgs []string
+	}{
+		{[]string{"list", "--short"}, []string{"--namespace", "resources", "list", "--short"}},
+		{[]string{"delete", "my-release", "--purge"}, []string{"delete", "my-release", "--purge"}},
+		{[]string{"history", "my-release"}, []string{"history", "my-release"}},
+		{[]string{"package", "./chart"}, []string{"package", "./chart"}},
+	}
+	for _, testCase := range testCases {
+		cmd, err := HelmCommandE(options, helmHome, testCase.args...)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.expectedArgs, cmd.Args)
+	}
+}
+
+func TestRunCommandSeparatesOutputAndExitCode(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("This test uses sh to simulate a failing command.")
+	}
+
+	cmd := shell.Command{
+		Command: "sh",
+		Args:    []string{"-c", `echo "out $GREETING"; echo "err $1" >&2; exit 3`, "sh", "with spaces"},
+		Env:     map[string]string{"GREETING": "hello world"},
+	}
+	result, err := RunCommandE(t, cmd)
+	require.Error(t, err)
+	failedErr, isFailedErr := err.(CommandFailedError)
+	require.True(t, isFailedErr, "Expected CommandFailedError, got %T: %s", err, err)
+	assert.Equal(t, 3, failedErr.ExitCode)
+	assert.Equal(t, "out hello world\n", result.Stdout)
+	assert.Equal(t, "err with spaces\n", result.Stderr)
+	assert.Equal(t, 3, result.ExitCode)
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/cluster"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/rbac"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This test verifies a Tiller-less setup on the same namespaces as the Tiller tests: it deploys the
+// k8s-namespace-with-service-account example, which creates the namespace with the k8s-namespace and
+// k8s-namespace-roles modules and the deployer ServiceAccount with the k8s-service-account module, and then installs
+// the test charts with Helm 3 acting as that ServiceAccount through an impersonating kubeconfig. Helm 3 stores the
+// releases as Secrets in the namespace of each release, so the test also checks that the release Secrets land in the
+// resource namespace.
+func TestK8SHelm3NamespaceDeployer(t *testing.T) {
+	t.Parallel()
+
+	// Uncomment any of the following to skip that section during the test
+	// os.Setenv("SKIP_create_test_copy_of_examples", "true")
+	// os.Setenv("SKIP_create_terratest_options", "true")
+	// os.Setenv("SKIP_terraform_apply", "true")
+	// os.Setenv("SKIP_setup_helm3_client", "true")
+	// os.Setenv("SKIP_validate", "true")
+	// os.Setenv("SKIP_cleanup", "true")
+
+	// Create a directory path that won't conflict
+	workingDir := filepath.Join(".", "stages", t.Name())
+
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
+	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
+		testFolder := test_structure.CopyTerraformFolderToTemp(t, "..", "examples")
+		logger.Logf(t, "path to test folder %s\n", testFolder)
+		k8sNamespaceTerraformModulePath := filepath.Join(testFolder, "k8s-namespace-with-service-account")
+		helmHome := filepath.Join(testFolder, ".helm3")
+		// make sure to create the helm home directory
+		require.NoError(t, os.Mkdir(helmHome, 0700))
+
+		test_structure.SaveString(t, workingDir, "k8sNamespaceTerraformModulePath", k8sNamespaceTerraformModulePath)
+		test_structure.SaveString(t, workingDir, "helmHome", helmHome)
+	})
+
+	test_structure.RunTestStage(t, "create_terratest_options", func() {
+		k8sNamespaceTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sNamespaceTerraformModulePath")
+		uniqueID := random.UniqueId()
+		k8sNamespaceTerratestOptions := createExampleK8SNamespaceTerraformOptions(
+			t, uniqueID, k8sNamespaceTerraformModulePath, getTestCluster(t), true)
+		test_structure.SaveString(t, workingDir, "uniqueID", uniqueID)
+		test_structure.SaveTerraformOptions(t, workingDir, k8sNamespaceTerratestOptions)
+	})
+
+	defer test_structure.RunTestStage(t, "cleanup", func() {
+		k8sNamespaceTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		terraform.Destroy(t, k8sNamespaceTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "terraform_apply", func() {
+		k8sNamespaceTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		terraform.InitAndApply(t, k8sNamespaceTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "setup_helm3_client", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sNamespaceTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		namespace := terraform.OutputRequired(t, k8sNamespaceTerratestOptions, "name")
+		serviceAccountName := terraform.OutputRequired(t, k8sNamespaceTerratestOptions, "service_account_access_all")
+		testCluster := getTestCluster(t)
+
+		helm3.CheckVersion(t, helmHome)
+
+		// The kubeconfig lives in the helm home, so that it is removed along with the copy of the examples.
+		deployerKubeConfigPath := filepath.Join(helmHome, "kubeconfig")
+		cluster.WriteImpersonatingKubeConfig(
+			t,
+			testCluster.KubeConfigPath,
+			testCluster.ContextName,
+			rbac.ServiceAccountUserName(namespace, serviceAccountName),
+			deployerKubeConfigPath,
+		)
+		test_structure.SaveString(t, workingDir, "deployerKubeConfigPath", deployerKubeConfigPath)
+	})
+
+	test_structure.RunTestStage(t, "validate", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		deployerKubeConfigPath := test_structure.LoadString(t, workingDir, "deployerKubeConfigPath")
+		k8sNamespaceTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		namespace := terraform.OutputRequired(t, k8sNamespaceTerratestOptions, "name")
+		deployerKubectlOptions := k8s.NewKubectlOptions(getTestCluster(t).ContextName, deployerKubeConfigPath, namespace)
+
+		// Make sure that helm runs as the ServiceAccount, and not as the admin user: the ServiceAccount can't read the
+		// release Secrets in the default namespace.
+		defaultKubectlOptions := k8s.NewKubectlOptions(getTestCluster(t).ContextName, deployerKubeConfigPath, "default")
+		_, err := helm3.RunHelmE(t, defaultKubectlOptions, helmHome, "list")
+		assertForbiddenInNamespace(t, err, "default")
+
+		validateLocalChartInstallsWithHelm3(t, deployerKubectlOptions, helmHome)
+	})
+}
+
+// validateLocalChartInstallsWithHelm3 installs each of the test charts with the Helm 3 client, and verifies that the
+// charts that stay within the namespace of the kubectl options install successfully and are stored in release Secrets
+// in that namespace, while the chart that writes to another namespace is rejected. The releases are uninstalled at the
+// end.
+func validateLocalChartInstallsWithHelm3(t *testing.T, options *k8s.KubectlOptions, helmHome string) {
+	// The release Secrets are listed across all namespaces, which the ServiceAccount is not allowed to do.
+	adminKubectlOptions := getTestCluster(t).KubectlOptions("")
+
+	for _, chartName := range []string{deploymentChart, rbacChart, pdbChart} {
+		releaseName := localChartReleaseName(chartName)
+		defer uninstallHelm3Release(t, options, helmHome, releaseName)
+		chartDir, err := filepath.Abs(filepath.Join(localChartsDir, chartName))
+		require.NoError(t, err)
+		helm3.RunHelm(t, options, helmHome, "install", releaseName, chartDir, "--wait")
+
+		releaseSecrets := helm3.ListReleaseSecrets(t, adminKubectlOptions, releaseName)
+		helm3.CheckReleaseSecretsInNamespace(t, releaseSecrets, releaseName, options.Namespace)
+		result := helm3.RunHelm(t, options, helmHome, "list", "--short")
+		assert.Contains(t, result.Stdout, releaseName)
+	}
+
+	releaseName := localChartReleaseName(crossNamespaceChart)
+	defer uninstallHelm3Release(t, options, helmHome, releaseName)
+	chartDir, err := filepath.Abs(filepath.Join(localChartsDir, crossNamespaceChart))
+	require.NoError(t, err)
+	_, err = helm3.RunHelmE(t, options, helmHome, "install", releaseName, chartDir)
+	assertForbiddenInNamespace(t, err, "default")
+	// Depending on how far the install got, Helm 3 may have recorded the failed release, but only in the namespace of
+	// the release.
+	for _, secret := range helm3.ListReleaseSecrets(t, adminKubectlOptions, releaseName) {
+		assert.Equal(t, options.Namespace, secret.Namespace, "Release Secret %s is outside of the namespace", secret)
+	}
+}
+
+// uninstallHelm3Release uninstalls the release with the Helm 3 client. Errors are only logged, because the release may
+// not exist if the install failed.
+func uninstallHelm3Release(t *testing.T, options *k8s.KubectlOptions, helmHome string, releaseName string) {
+	if _, err := helm3.RunHelmE(t, options, helmHome, "uninstall", releaseName); err != nil {
+		logger.Logf(t, "Error uninstalling helm release %s: %s", releaseName, err)
+	}
+}
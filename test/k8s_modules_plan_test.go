@@ -0,0 +1,470 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/rbac"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfplan"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// The plan tests in this file run `terraform plan` against each module and assert on the planned attribute values. They
+// only need terraform and the providers: the kubernetes provider is pointed at a dummy kubeconfig, so no API server is
+// contacted.
+//
+// NOTE: k8s-helm-client-tls-certs is not covered here, because it reads the CA Secret through a data source, which
+// requires a live API server to plan.
+
+// dummyKubeConfig is a kubeconfig for a cluster that does not exist. The kubernetes provider only needs a loadable
+// config to plan resources that don't depend on data sources.
+const dummyKubeConfig = `---
+apiVersion: v1
+kind: Config
+clusters:
+- name: dummy
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: dummy
+  context:
+    cluster: dummy
+    user: dummy
+current-context: dummy
+users:
+- name: dummy
+  user:
+    token: dummy
+`
+
+func TestK8STillerPlan(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                  string
+		tillerListenLocalhost bool
+		expectedArgs          []string
+	}{
+		{
+			"ListenLocalhost",
+			true,
+			[]string{
+				"--storage=secret",
+				"--tls-key=/etc/certs/tls.pem",
+				"--tls-cert=/etc/certs/tls.crt",
+				"--tls-ca-cert=/etc/certs/ca.crt",
+				"--listen=localhost:44134",
+			},
+		},
+		{
+			"ListenAll",
+			false,
+			[]string{
+				"--storage=secret",
+				"--tls-key=/etc/certs/tls.pem",
+				"--tls-cert=/etc/certs/tls.crt",
+				"--tls-ca-cert=/etc/certs/ca.crt",
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change when the subtests run in parallel
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			namespace := strings.ToLower(random.UniqueId())
+			options := createModulePlanOptions(t, "k8s-tiller", map[string]interface{}{
+				"namespace":                                namespace,
+				"tiller_service_account_name":              "tiller",
+				"tiller_service_account_token_secret_name": "tiller-token-abcde",
+				"tiller_tls_gen_method":                    "provider",
+				"tiller_image_version":                     "v2.12.2",
+				"tiller_listen_localhost":                  testCase.tillerListenLocalhost,
+			})
+			plan := tfplan.InitAndPlanAndShow(t, options)
+
+			deployment := plan.ResourceChange(t, "kubernetes_deployment.tiller")
+			assert.Equal(t, map[string]string{"app": "helm", "name": "tiller"}, deployment.AfterAttributeStringMap(t, "metadata.0.labels"))
+			assert.Equal(t, "tiller", deployment.AfterAttribute(t, "spec.0.template.0.spec.0.service_account_name"))
+
+			container := "spec.0.template.0.spec.0.container.0"
+			assert.Equal(t, "gcr.io/kubernetes-helm/tiller:v2.12.2", deployment.AfterAttribute(t, container+".image"))
+			assert.Equal(t, testCase.expectedArgs, deployment.AfterAttributeStringList(t, container+".args"))
+
+			env := plannedContainerEnv(t, deployment, container)
+			assert.Equal(t, namespace, env["TILLER_NAMESPACE"])
+			assert.Equal(t, "1", env["TILLER_TLS_VERIFY"])
+			assert.Equal(t, "1", env["TILLER_TLS_ENABLE"])
+			assert.Equal(t, "/etc/certs", env["TILLER_TLS_CERTS"])
+
+			// The TLS certs generated by the provider should be mounted from the Secret named by the kubergrunt convention.
+			serverCertsSecret := plan.ResourceChange(t, "module.tiller_tls_certs.kubernetes_secret.signed_tls[0]")
+			serverCertsSecretName := fmt.Sprintf("%s-namespace-tiller-certs", namespace)
+			assert.Equal(t, serverCertsSecretName, serverCertsSecret.AfterAttribute(t, "metadata.0.name"))
+			assert.Equal(t, "server", serverCertsSecret.AfterAttributeStringMap(t, "metadata.0.labels")["gruntwork.io/tiller-credentials-type"])
+			assert.Equal(t, serverCertsSecretName, deployment.AfterAttribute(t, "spec.0.template.0.spec.0.volume.1.secret.0.secret_name"))
+		})
+	}
+}
+
+// TestK8STillerPlanRejectsInvalidInputs checks that the k8s-tiller module fails the plan with a clear message when the
+// inputs break the contracts documented on the variables, instead of silently falling through to a default. Terraform
+// 0.12 can't validate variables, so the module validates them in locals, which only fail once the values are known:
+// `terraform validate` must still pass.
+func TestK8STillerPlanRejectsInvalidInputs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		vars          map[string]interface{}
+		expectedError string
+	}{
+		{
+			"UnknownTLSGenMethod",
+			map[string]interface{}{"tiller_tls_gen_method": "kubergrunts"},
+			"var.tiller_tls_gen_method must be one of provider, kubergrunt, or none, but got kubergrunts",
+		},
+		{
+			"UnknownPrivateKeyAlgorithm",
+			map[string]interface{}{"private_key_algorithm": "DSA"},
+			"var.private_key_algorithm must be one of RSA or ECDSA, but got DSA",
+		},
+		{
+			"ServerEndpointWithoutToken",
+			map[string]interface{}{
+				"kubectl_server_endpoint": "https://127.0.0.1:6443",
+				"kubectl_ca_b64_data":     "ZHVtbXk=",
+			},
+			"var.kubectl_ca_b64_data and var.kubectl_token must be set when var.kubectl_server_endpoint is set",
+		},
+		{
+			"NoneWithoutSecretName",
+			map[string]interface{}{"tiller_tls_gen_method": "none"},
+			"var.tiller_tls_secret_name must be set when var.tiller_tls_gen_method is none",
+		},
+		// The valid counterparts of the cases above, to make sure the validation doesn't reject them.
+		{
+			"ServerEndpointWithToken",
+			map[string]interface{}{
+				"kubectl_server_endpoint": "https://127.0.0.1:6443",
+				"kubectl_ca_b64_data":     "ZHVtbXk=",
+				"kubectl_token":           "dummy",
+			},
+			"",
+		},
+		{
+			"NoneWithSecretName",
+			map[string]interface{}{"tiller_tls_gen_method": "none", "tiller_tls_secret_name": "tiller-certs"},
+			"",
+		},
+	}
+
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change when the subtests run in parallel
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			terraformVars := map[string]interface{}{
+				"namespace":                                strings.ToLower(random.UniqueId()),
+				"tiller_service_account_name":              "tiller",
+				"tiller_service_account_token_secret_name": "tiller-token-abcde",
+				"tiller_tls_gen_method":                    "provider",
+			}
+			for key, value := range testCase.vars {
+				terraformVars[key] = value
+			}
+			options := createModulePlanOptions(t, "k8s-tiller", terraformVars)
+
+			terraform.Init(t, options)
+			terraform.RunTerraformCommand(t, options, "validate")
+
+			out, err := terraform.PlanE(t, options)
+			if testCase.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			// Terraform wraps the error message, so compare with the whitespace collapsed.
+			assert.Contains(t, strings.Join(strings.Fields(out), " "), "ERROR: "+testCase.expectedError)
+		})
+	}
+}
+
+func TestK8STillerTLSCertsPlan(t *testing.T) {
+	t.Parallel()
+
+	options := createModulePlanOptions(t, "k8s-tiller-tls-certs", map[string]interface{}{
+		"ca_tls_subject": map[string]string{
+			"common_name":  "tiller CA",
+			"organization": "Gruntwork",
+		},
+		"signed_tls_subject": map[string]string{
+			"common_name":  "tiller",
+			"organization": "Gruntwork",
+		},
+		"ca_tls_certificate_key_pair_secret_namespace":     "kube-system",
+		"ca_tls_certificate_key_pair_secret_name":          "tiller-ca-certs",
+		"signed_tls_certificate_key_pair_secret_namespace": "tiller",
+		"signed_tls_certificate_key_pair_secret_name":      "tiller-certs",
+		"private_key_algorithm":                            "RSA",
+		"private_key_rsa_bits":                             4096,
+	})
+	plan := tfplan.InitAndPlanAndShow(t, options)
+
+	for _, address := range []string{"tls_private_key.ca[0]", "tls_private_key.cert[0]"} {
+		privateKey := plan.ResourceChange(t, address)
+		assert.Equal(t, "RSA", privateKey.AfterAttribute(t, "algorithm"))
+		assert.Equal(t, float64(4096), privateKey.AfterAttribute(t, "rsa_bits"))
+	}
+
+	caCert := plan.ResourceChange(t, "tls_self_signed_cert.ca[0]")
+	assert.Equal(t, true, caCert.AfterAttribute(t, "is_ca_certificate"))
+	assert.Equal(t, "tiller CA", caCert.AfterAttribute(t, "subject.0.common_name"))
+	assert.Equal(
+		t,
+		[]string{"cert_signing", "key_encipherment", "digital_signature", "server_auth", "client_auth"},
+		caCert.AfterAttributeStringList(t, "allowed_uses"),
+	)
+
+	signedCert := plan.ResourceChange(t, "tls_locally_signed_cert.cert[0]")
+	assert.Equal(t, float64(87660), signedCert.AfterAttribute(t, "validity_period_hours"))
+	assert.Equal(
+		t,
+		[]string{"key_encipherment", "digital_signature", "server_auth"},
+		signedCert.AfterAttributeStringList(t, "allowed_uses"),
+	)
+
+	caSecret := plan.ResourceChange(t, "kubernetes_secret.ca_secret[0]")
+	assert.Equal(t, "kube-system", caSecret.AfterAttribute(t, "metadata.0.namespace"))
+	assert.Equal(t, "tiller-ca-certs", caSecret.AfterAttribute(t, "metadata.0.name"))
+	signedSecret := plan.ResourceChange(t, "kubernetes_secret.signed_tls[0]")
+	assert.Equal(t, "tiller", signedSecret.AfterAttribute(t, "metadata.0.namespace"))
+	assert.Equal(t, "tiller-certs", signedSecret.AfterAttribute(t, "metadata.0.name"))
+}
+
+func TestK8SNamespaceRolesPlan(t *testing.T) {
+	t.Parallel()
+
+	namespace := strings.ToLower(random.UniqueId())
+	options := createModulePlanOptions(t, "k8s-namespace-roles", map[string]interface{}{
+		"namespace": namespace,
+		"labels":    map[string]string{"app": "plan-test"},
+	})
+	plan := tfplan.InitAndPlanAndShow(t, options)
+
+	accessAll := plan.ResourceChange(t, "kubernetes_role.rbac_role_access_all[0]")
+	assert.Equal(t, namespace+"-access-all", accessAll.AfterAttribute(t, "metadata.0.name"))
+	assert.Equal(t, namespace, accessAll.AfterAttribute(t, "metadata.0.namespace"))
+	assert.Equal(t, map[string]string{"app": "plan-test"}, accessAll.AfterAttributeStringMap(t, "metadata.0.labels"))
+	assertPlannedRule(t, accessAll, 0, []string{"*"}, []string{"*"}, []string{"*"})
+
+	readOnly := plan.ResourceChange(t, "kubernetes_role.rbac_role_access_read_only[0]")
+	assert.Equal(t, namespace+"-access-read-only", readOnly.AfterAttribute(t, "metadata.0.name"))
+	assertPlannedRule(t, readOnly, 0, []string{"*"}, []string{"*"}, []string{"get", "list", "watch"})
+
+	tillerMetadata := plan.ResourceChange(t, "kubernetes_role.rbac_tiller_metadata_access[0]")
+	assert.Equal(t, namespace+"-tiller-metadata-access", tillerMetadata.AfterAttribute(t, "metadata.0.name"))
+	assertPlannedRule(t, tillerMetadata, 0, []string{"", "extensions", "apps"}, []string{"secrets"}, []string{"*"})
+
+	tillerResource := plan.ResourceChange(t, "kubernetes_role.rbac_tiller_resource_access[0]")
+	assert.Equal(t, namespace+"-tiller-resource-access", tillerResource.AfterAttribute(t, "metadata.0.name"))
+	assertPlannedRule(
+		t,
+		tillerResource,
+		0,
+		[]string{"", "batch", "extensions", "apps", "rbac.authorization.k8s.io"},
+		[]string{"*"},
+		[]string{"*"},
+	)
+	assertPlannedRule(t, tillerResource, 1, []string{"policy"}, []string{"poddisruptionbudgets"}, []string{"*"})
+}
+
+// TestK8SNamespaceRolesRBACMatrixPlan evaluates the RBAC expectation matrix in kubefixtures against the planned rules
+// of the roles, so that changes to the rules that are not reflected in the matrix are caught without a cluster.
+func TestK8SNamespaceRolesRBACMatrixPlan(t *testing.T) {
+	t.Parallel()
+
+	matrix, err := rbac.LoadExpectationMatrixE(namespaceRolesRBACMatrixPath)
+	require.NoError(t, err)
+
+	options := createModulePlanOptions(t, "k8s-namespace-roles", map[string]interface{}{
+		"namespace": strings.ToLower(random.UniqueId()),
+	})
+	plan := tfplan.InitAndPlanAndShow(t, options)
+
+	roleAddresses := map[string]string{
+		"rbac_access_all_role":             "kubernetes_role.rbac_role_access_all[0]",
+		"rbac_access_read_only_role":       "kubernetes_role.rbac_role_access_read_only[0]",
+		"rbac_tiller_metadata_access_role": "kubernetes_role.rbac_tiller_metadata_access[0]",
+		"rbac_tiller_resource_access_role": "kubernetes_role.rbac_tiller_resource_access[0]",
+	}
+	require.Equal(t, len(roleAddresses), len(matrix.Roles()))
+	for _, roleOutput := range matrix.Roles() {
+		address, hasAddress := roleAddresses[roleOutput]
+		require.True(t, hasAddress, "No planned role for matrix entry %s", roleOutput)
+		rules := plannedPolicyRules(t, plan.ResourceChange(t, address))
+		assert.NoError(
+			t,
+			rbac.CheckAccessE(roleOutput, matrix.AccessExpectations(roleOutput, ""), rbac.PolicyRulesAccessCheck(rules)),
+		)
+	}
+}
+
+func TestK8SNamespaceRolesPlanNoCreate(t *testing.T) {
+	t.Parallel()
+
+	options := createModulePlanOptions(t, "k8s-namespace-roles", map[string]interface{}{
+		"namespace":        strings.ToLower(random.UniqueId()),
+		"create_resources": false,
+	})
+	plan := tfplan.InitAndPlanAndShow(t, options)
+	assert.Equal(t, 0, len(plan.ResourceChangesOfType("kubernetes_role")))
+}
+
+func TestK8SNamespacePlan(t *testing.T) {
+	t.Parallel()
+
+	name := strings.ToLower(random.UniqueId())
+	options := createModulePlanOptions(t, "k8s-namespace", map[string]interface{}{
+		"name":        name,
+		"labels":      map[string]string{"app": "plan-test"},
+		"annotations": map[string]string{"gruntwork.io/purpose": "plan-test"},
+	})
+	plan := tfplan.InitAndPlanAndShow(t, options)
+
+	namespace := plan.ResourceChange(t, "kubernetes_namespace.namespace[0]")
+	assert.Equal(t, name, namespace.AfterAttribute(t, "metadata.0.name"))
+	assert.Equal(t, map[string]string{"app": "plan-test"}, namespace.AfterAttributeStringMap(t, "metadata.0.labels"))
+	assert.Equal(
+		t,
+		map[string]string{"gruntwork.io/purpose": "plan-test"},
+		namespace.AfterAttributeStringMap(t, "metadata.0.annotations"),
+	)
+
+	// The roles are namespaced to the ID of the namespace resource, which is only known after apply, so we can only
+	// check that all four are planned.
+	assert.Equal(t, 4, len(plan.ResourceChangesOfType("kubernetes_role")))
+}
+
+func TestK8SServiceAccountPlan(t *testing.T) {
+	t.Parallel()
+
+	namespace := strings.ToLower(random.UniqueId())
+	serviceAccountName := fmt.Sprintf("%s-sa", namespace)
+	otherNamespace := fmt.Sprintf("%s-resources", namespace)
+	options := createModulePlanOptions(t, "k8s-service-account", map[string]interface{}{
+		"name":           serviceAccountName,
+		"namespace":      namespace,
+		"num_rbac_roles": 2,
+		"rbac_roles": []map[string]string{
+			{
+				"name":      namespace + "-tiller-metadata-access",
+				"namespace": namespace,
+			},
+			{
+				"name":      otherNamespace + "-tiller-resource-access",
+				"namespace": otherNamespace,
+			},
+		},
+		"secrets_for_pulling_images":      []string{"registry-credentials"},
+		"automount_service_account_token": false,
+	})
+	plan := tfplan.InitAndPlanAndShow(t, options)
+
+	serviceAccount := plan.ResourceChange(t, "kubernetes_service_account.service_account[0]")
+	assert.Equal(t, serviceAccountName, serviceAccount.AfterAttribute(t, "metadata.0.name"))
+	assert.Equal(t, namespace, serviceAccount.AfterAttribute(t, "metadata.0.namespace"))
+	assert.Equal(t, "registry-credentials", serviceAccount.AfterAttribute(t, "image_pull_secret.0.name"))
+	assert.Equal(t, false, serviceAccount.AfterAttribute(t, "automount_service_account_token"))
+
+	expectedBindings := []struct {
+		roleName      string
+		roleNamespace string
+	}{
+		{namespace + "-tiller-metadata-access", namespace},
+		{otherNamespace + "-tiller-resource-access", otherNamespace},
+	}
+	for i, expected := range expectedBindings {
+		binding := plan.ResourceChange(t, fmt.Sprintf("kubernetes_role_binding.service_account_role_binding[%d]", i))
+		assert.Equal(
+			t,
+			fmt.Sprintf("%s-%s-role-binding", serviceAccountName, expected.roleName),
+			binding.AfterAttribute(t, "metadata.0.name"),
+		)
+		assert.Equal(t, expected.roleNamespace, binding.AfterAttribute(t, "metadata.0.namespace"))
+		assert.Equal(t, "Role", binding.AfterAttribute(t, "role_ref.0.kind"))
+		assert.Equal(t, expected.roleName, binding.AfterAttribute(t, "role_ref.0.name"))
+		// The subject is always the ServiceAccount in its own namespace, even when the role lives in another namespace.
+		assert.Equal(t, "ServiceAccount", binding.AfterAttribute(t, "subject.0.kind"))
+		assert.Equal(t, serviceAccountName, binding.AfterAttribute(t, "subject.0.name"))
+		assert.Equal(t, namespace, binding.AfterAttribute(t, "subject.0.namespace"))
+	}
+}
+
+// createModulePlanOptions copies the repo to a temp folder and returns terraform options for planning the named module
+// against a dummy kubeconfig.
+func createModulePlanOptions(t *testing.T, moduleName string, terraformVars map[string]interface{}) *terraform.Options {
+	modulePath := test_structure.CopyTerraformFolderToTemp(t, "..", "modules/"+moduleName)
+	kubeConfigPath := k8s.StoreConfigToTempFile(t, dummyKubeConfig)
+	return createPlanOnlyTerraformOptions(t, modulePath, kubeConfigPath, terraformVars)
+}
+
+// plannedContainerEnv returns the env vars of the container at the given path of the planned resource as a map.
+func plannedContainerEnv(t *testing.T, change *tfplan.ResourceChange, containerPath string) map[string]string {
+	env := map[string]string{}
+	rawEnvList, isList := change.AfterAttribute(t, containerPath+".env").([]interface{})
+	assert.True(t, isList)
+	for i := range rawEnvList {
+		name := change.AfterAttribute(t, fmt.Sprintf("%s.env.%d.name", containerPath, i))
+		value := change.AfterAttribute(t, fmt.Sprintf("%s.env.%d.value", containerPath, i))
+		env[fmt.Sprint(name)] = fmt.Sprint(value)
+	}
+	return env
+}
+
+// plannedPolicyRules converts the planned rule blocks of a kubernetes_role resource to RBAC policy rules.
+func plannedPolicyRules(t *testing.T, role *tfplan.ResourceChange) []rbacv1.PolicyRule {
+	rules := []rbacv1.PolicyRule{}
+	rawRules, isList := role.AfterAttribute(t, "rule").([]interface{})
+	require.True(t, isList)
+	for i := range rawRules {
+		rulePath := fmt.Sprintf("rule.%d", i)
+		rule := rbacv1.PolicyRule{
+			APIGroups: role.AfterAttributeStringList(t, rulePath+".api_groups"),
+			Resources: role.AfterAttributeStringList(t, rulePath+".resources"),
+			Verbs:     role.AfterAttributeStringList(t, rulePath+".verbs"),
+		}
+		if resourceNames, err := role.AfterAttributeE(rulePath + ".resource_names"); err == nil && resourceNames != nil {
+			rule.ResourceNames = role.AfterAttributeStringList(t, rulePath+".resource_names")
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// assertPlannedRule checks the api groups, resources, and verbs of the RBAC rule at the given index of a planned role.
+func assertPlannedRule(
+	t *testing.T,
+	role *tfplan.ResourceChange,
+	ruleIndex int,
+	expectedAPIGroups []string,
+	expectedResources []string,
+	expectedVerbs []string,
+) {
+	rulePath := fmt.Sprintf("rule.%d", ruleIndex)
+	assert.Equal(t, expectedAPIGroups, role.AfterAttributeStringList(t, rulePath+".api_groups"))
+	assert.Equal(t, expectedResources, role.AfterAttributeStringList(t, rulePath+".resources"))
+	assert.Equal(t, expectedVerbs, role.AfterAttributeStringList(t, rulePath+".verbs"))
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/rbac"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const namespaceRolesRBACMatrixPath = "./kubefixtures/namespace-roles-rbac-matrix.yml"
+
+// TestK8SNamespaceRolesRBACMatrix deploys the k8s-namespace-roles module and checks every cell of the RBAC expectation
+// matrix in kubefixtures against the cluster, by binding each role to a test user and running SubjectAccessReviews.
+func TestK8SNamespaceRolesRBACMatrix(t *testing.T) {
+	t.Parallel()
+
+	matrix, err := rbac.LoadExpectationMatrixE(namespaceRolesRBACMatrixPath)
+	require.NoError(t, err)
+
+	namespace := strings.ToLower(random.UniqueId())
+	testCluster := getTestCluster(t)
+	kubectlOptions := testCluster.KubectlOptions("")
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResources(t, leakSnapshot, namespace)
+	k8s.CreateNamespace(t, kubectlOptions, namespace)
+	defer k8s.DeleteNamespace(t, kubectlOptions, namespace)
+
+	modulePath := test_structure.CopyTerraformFolderToTemp(t, "..", "modules/k8s-namespace-roles")
+	terratestOptions := &terraform.Options{
+		TerraformDir: modulePath,
+		Vars:         map[string]interface{}{"namespace": namespace},
+		// The module doesn't configure the kubernetes provider, so we point the provider at the cluster through the
+		// environment.
+		EnvVars: testCluster.TerraformEnvVars(),
+	}
+	defer terraform.Destroy(t, terratestOptions)
+	terraform.InitAndApply(t, terratestOptions)
+
+	// Make sure the matrix covers every role output of the module, so that new roles have to be added to the matrix.
+	outputNames := []string{}
+	for outputName := range terraform.OutputAll(t, terratestOptions) {
+		outputNames = append(outputNames, outputName)
+	}
+	sort.Strings(outputNames)
+	require.Equal(t, outputNames, matrix.Roles())
+
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, kubectlOptions)
+	require.NoError(t, err)
+
+	for _, roleOutput := range matrix.Roles() {
+		roleName := terraform.Output(t, terratestOptions, roleOutput)
+		userName := fmt.Sprintf("rbac-matrix:%s", roleName)
+		roleBinding := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: roleName + "-rbac-matrix", Namespace: namespace},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: roleName},
+			Subjects:   []rbacv1.Subject{{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: userName}},
+		}
+		_, err := clientset.RbacV1().RoleBindings(namespace).Create(roleBinding)
+		require.NoError(t, err)
+
+		// The roles are namespaced, so every cell should be denied outside of the role namespace.
+		expectations := append(
+			matrix.AccessExpectations(roleOutput, namespace),
+			matrix.DeniedAccessExpectations(roleOutput, "default")...,
+		)
+
+		// The authorizer works off of a cache, so retry a few times to give it a chance to see the new binding.
+		_, err = retry.DoWithRetryE(
+			t,
+			fmt.Sprintf("Check RBAC matrix for %s", roleOutput),
+			10,
+			3*time.Second,
+			func() (string, error) {
+				return "", rbac.AssertSubjectAccessE(t, kubectlOptions, userName, nil, expectations)
+			},
+		)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,216 @@
+package test
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/rbac"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The addresses of the resources of the k8s-namespace module, as they appear in the output of terraform destroy.
+const (
+	namespaceResourceAddress   = "kubernetes_namespace.namespace[0]"
+	namespaceRolesModulePrefix = "module.namespace_roles.kubernetes_role."
+)
+
+// terraformProgressRegexp matches the progress lines of terraform apply and destroy, e.g.
+// `kubernetes_namespace.namespace[0]: Destruction complete after 1s`.
+var terraformProgressRegexp = regexp.MustCompile(`^(\S+): (Destroying\.\.\.|Destruction complete)`)
+
+// TestK8SNamespaceLifecycle applies the k8s-namespace module on its own, checks the namespace and roles it creates
+// against the cluster, and checks that the roles are destroyed before the namespace.
+func TestK8SNamespaceLifecycle(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name            string
+		labels          map[string]string
+		annotations     map[string]string
+		createResources bool
+	}{
+		{"Defaults", nil, nil, true},
+		{
+			"LabelsAndAnnotations",
+			map[string]string{"team": "platform", "gruntwork.io/test": "namespace-lifecycle"},
+			map[string]string{"gruntwork.io/owner": "terratest", "description": "Namespace with labels and annotations"},
+			true,
+		},
+		{"NoCreate", map[string]string{"team": "platform"}, map[string]string{"gruntwork.io/owner": "terratest"}, false},
+	}
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change across the parallel subtests
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			uniqueID := random.UniqueId()
+			namespace := strings.ToLower(uniqueID)
+			testCluster := getTestCluster(t)
+			leakSnapshot := snapshotClusterResources(t)
+			defer checkForLeakedResources(t, leakSnapshot, namespace)
+
+			modulePath := test_structure.CopyTerraformFolderToTemp(t, "..", "modules/k8s-namespace")
+			terraformVars := tfvars.NamespaceModuleVars{
+				Name:            namespace,
+				Labels:          testCase.labels,
+				Annotations:     testCase.annotations,
+				CreateResources: tfvars.Bool(testCase.createResources),
+			}
+			terratestOptions := createTerraformOptionsFromVars(t, modulePath, terraformVars.ToVars())
+			// The module doesn't configure the kubernetes provider, so we point the provider at the cluster through the
+			// environment.
+			terratestOptions.EnvVars = testCluster.TerraformEnvVars()
+			// Parse the progress lines of terraform destroy without the color codes.
+			terratestOptions.NoColor = true
+			defer terraform.Destroy(t, terratestOptions)
+			terraform.InitAndApply(t, terratestOptions)
+
+			clientset, err := k8s.GetKubernetesClientFromOptionsE(t, testCluster.KubectlOptions(""))
+			require.NoError(t, err)
+
+			if !testCase.createResources {
+				validateNamespaceNotCreated(t, clientset, terratestOptions, namespace)
+				validateNoCreateOutputsConsumable(t, uniqueID)
+				return
+			}
+			validateNamespaceMetadata(t, clientset, terratestOptions, testCase.labels, testCase.annotations)
+			validateNamespaceDestroyOrder(t, clientset, terratestOptions)
+		})
+	}
+}
+
+// validateNamespaceMetadata verifies that the namespace and every role in the role outputs exist, and carry the labels
+// and annotations that were passed to the module.
+func validateNamespaceMetadata(
+	t *testing.T,
+	clientset kubernetes.Interface,
+	terratestOptions *terraform.Options,
+	labels map[string]string,
+	annotations map[string]string,
+) {
+	namespace := terraform.Output(t, terratestOptions, "name")
+	k8sNamespace, err := clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.NamespaceActive, k8sNamespace.Status.Phase)
+	assertHasMetadata(t, k8sNamespace.ObjectMeta, labels, annotations)
+
+	for outputName, roleName := range roleOutputs(t, terratestOptions) {
+		require.NotEmpty(t, roleName, "Output %s is empty", outputName)
+		role, err := clientset.RbacV1().Roles(namespace).Get(roleName, metav1.GetOptions{})
+		require.NoError(t, err, "Role %s of output %s", roleName, outputName)
+		assertHasMetadata(t, role.ObjectMeta, labels, annotations)
+	}
+}
+
+// validateNamespaceDestroyOrder destroys the module, and verifies from the terraform output that every role was
+// destroyed before terraform started destroying the namespace. Deleting the namespace would delete the roles anyway, so
+// checking that they are gone afterwards is not enough to catch a missing dependency.
+func validateNamespaceDestroyOrder(t *testing.T, clientset kubernetes.Interface, terratestOptions *terraform.Options) {
+	namespace := terraform.Output(t, terratestOptions, "name")
+	roleNames := roleOutputs(t, terratestOptions)
+	output := terraform.Destroy(t, terratestOptions)
+
+	destroyedRoles := []string{}
+	namespaceDestroyStarted := false
+	for _, line := range strings.Split(output, "\n") {
+		match := terraformProgressRegexp.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		address, event := match[1], match[2]
+		switch {
+		case address == namespaceResourceAddress && event == "Destroying...":
+			namespaceDestroyStarted = true
+		case strings.HasPrefix(address, namespaceRolesModulePrefix) && event == "Destruction complete":
+			assert.False(t, namespaceDestroyStarted, "Role %s was destroyed after the namespace", address)
+			destroyedRoles = append(destroyedRoles, address)
+		}
+	}
+	assert.True(t, namespaceDestroyStarted, "Namespace %s was not destroyed", namespace)
+	assert.Equal(t, len(roleNames), len(destroyedRoles), "Destroyed roles: %v", destroyedRoles)
+
+	for _, roleName := range roleNames {
+		_, err := clientset.RbacV1().Roles(namespace).Get(roleName, metav1.GetOptions{})
+		assert.True(t, errors.IsNotFound(err), "Expected role %s to be deleted, got %v", roleName, err)
+	}
+	_, err := clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err), "Expected namespace %s to be deleted, got %v", namespace, err)
+}
+
+// validateNamespaceNotCreated verifies that the module creates nothing when create_resources is false, and that all of
+// its outputs are empty.
+func validateNamespaceNotCreated(
+	t *testing.T,
+	clientset kubernetes.Interface,
+	terratestOptions *terraform.Options,
+	namespace string,
+) {
+	_, err := clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err), "Expected namespace %s to not exist, got %v", namespace, err)
+	for outputName, value := range terraform.OutputAll(t, terratestOptions) {
+		assert.Equal(t, "", value, "Expected output %s to be empty", outputName)
+	}
+}
+
+// validateNoCreateOutputsConsumable applies the k8s-namespace-with-service-account example with create_resources set
+// to false, which feeds the empty outputs of k8s-namespace into k8s-service-account with num_rbac_roles set. The
+// apply must succeed, and the outputs of the service accounts must be empty too.
+func validateNoCreateOutputsConsumable(t *testing.T, uniqueID string) {
+	examplePath := test_structure.CopyTerraformFolderToTemp(t, "..", "examples/k8s-namespace-with-service-account")
+	terratestOptions := createExampleK8SNamespaceTerraformOptions(t, uniqueID, examplePath, getTestCluster(t), false)
+	defer terraform.Destroy(t, terratestOptions)
+	terraform.InitAndApply(t, terratestOptions)
+
+	for outputName, value := range terraform.OutputAll(t, terratestOptions) {
+		assert.Equal(t, "", value, "Expected output %s to be empty", outputName)
+	}
+}
+
+// roleOutputs returns the role outputs of the k8s-namespace module, keyed by output name. These are the outputs of
+// k8s-namespace-roles passed through, so they must match the roles in its RBAC expectation matrix.
+func roleOutputs(t *testing.T, terratestOptions *terraform.Options) map[string]string {
+	matrix, err := rbac.LoadExpectationMatrixE(namespaceRolesRBACMatrixPath)
+	require.NoError(t, err)
+
+	roles := map[string]string{}
+	outputNames := []string{}
+	for outputName, value := range terraform.OutputAll(t, terratestOptions) {
+		if strings.HasPrefix(outputName, "rbac_") {
+			roles[outputName] = fmt.Sprint(value)
+			outputNames = append(outputNames, outputName)
+		}
+	}
+	sort.Strings(outputNames)
+	require.Equal(t, matrix.Roles(), outputNames)
+	return roles
+}
+
+// assertHasMetadata checks that the object has all the given labels and annotations. The cluster may add labels of its
+// own (e.g. kubernetes.io/metadata.name on namespaces), so extra labels and annotations are allowed.
+func assertHasMetadata(
+	t *testing.T,
+	objectMeta metav1.ObjectMeta,
+	labels map[string]string,
+	annotations map[string]string,
+) {
+	for key, value := range labels {
+		assert.Equal(t, value, objectMeta.Labels[key], "Label %s of %s", key, objectMeta.Name)
+	}
+	for key, value := range annotations {
+		assert.Equal(t, value, objectMeta.Annotations[key], "Annotation %s of %s", key, objectMeta.Name)
+	}
+}
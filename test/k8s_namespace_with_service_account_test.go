@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 	"text/template"
 	"time"
 
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/rbac"
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/gruntwork-io/terratest/modules/random"
@@ -20,9 +22,21 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+// Set this environment variable to check RBAC permissions from within a Pod running as the ServiceAccount, instead of
+// impersonating the ServiceAccount.
+const rbacCheckE2EEnvVar = "RBAC_CHECK_E2E"
+
+// TemplateArgs are the arguments of the templates in the kubefixtures directory. Each template only uses the fields it
+// needs.
 type TemplateArgs struct {
 	Namespace          string
 	ServiceAccountName string
+	PodName            string
+	Image              string
+
+	RegistryPort     int
+	RegistryUsername string
+	RegistryPassword string
 }
 
 func TestK8SNamespaceWithServiceAccountNoCreate(t *testing.T) {
@@ -39,6 +53,10 @@ func TestK8SNamespaceWithServiceAccountNoCreate(t *testing.T) {
 	// Create a directory path that won't conflict
 	workingDir := filepath.Join(".", "stages", t.Name())
 
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
 	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
 		testFolder := test_structure.CopyTerraformFolderToTemp(t, "..", "examples")
 		logger.Logf(t, "path to test folder %s\n", testFolder)
@@ -50,8 +68,7 @@ func TestK8SNamespaceWithServiceAccountNoCreate(t *testing.T) {
 		k8sNamespaceTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sNamespaceTerraformModulePath")
 		uniqueID := random.UniqueId()
 		k8sNamespaceTerratestOptions := createExampleK8SNamespaceTerraformOptions(
-			t, uniqueID, k8sNamespaceTerraformModulePath)
-		k8sNamespaceTerratestOptions.Vars["create_resources"] = 0
+			t, uniqueID, k8sNamespaceTerraformModulePath, getTestCluster(t), false)
 		test_structure.SaveString(t, workingDir, "uniqueID", uniqueID)
 		test_structure.SaveTerraformOptions(t, workingDir, k8sNamespaceTerratestOptions)
 	})
@@ -85,6 +102,10 @@ func TestK8SNamespaceWithServiceAccount(t *testing.T) {
 	// Create a directory path that won't conflict
 	workingDir := filepath.Join(".", "stages", t.Name())
 
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
 	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
 		testFolder := test_structure.CopyTerraformFolderToTemp(t, "..", "examples")
 		logger.Logf(t, "path to test folder %s\n", testFolder)
@@ -96,7 +117,7 @@ func TestK8SNamespaceWithServiceAccount(t *testing.T) {
 		k8sNamespaceTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sNamespaceTerraformModulePath")
 		uniqueID := random.UniqueId()
 		k8sNamespaceTerratestOptions := createExampleK8SNamespaceTerraformOptions(
-			t, uniqueID, k8sNamespaceTerraformModulePath)
+			t, uniqueID, k8sNamespaceTerraformModulePath, getTestCluster(t), true)
 		test_structure.SaveString(t, workingDir, "uniqueID", uniqueID)
 		test_structure.SaveTerraformOptions(t, workingDir, k8sNamespaceTerratestOptions)
 	})
@@ -135,7 +156,7 @@ func TestK8SNamespaceWithServiceAccount(t *testing.T) {
 // validateNamespace verifies that the namespace was created and is active.
 func validateNamespace(t *testing.T, k8sNamespaceTerratestOptions *terraform.Options) {
 	namespace := terraform.Output(t, k8sNamespaceTerratestOptions, "name")
-	kubectlOptions := k8s.NewKubectlOptions("", "", "")
+	kubectlOptions := getTestCluster(t).KubectlOptions("")
 	k8sNamespace := k8s.GetNamespace(t, kubectlOptions, namespace)
 	assert.Equal(t, k8sNamespace.Name, namespace)
 	assert.Equal(t, k8sNamespace.Status.Phase, corev1.NamespaceActive)
@@ -143,65 +164,64 @@ func validateNamespace(t *testing.T, k8sNamespaceTerratestOptions *terraform.Opt
 
 // validateRbacAccessAll verifies that the access all RBAC role has read and write privileges to the namespace
 func validateRbacAccessAll(t *testing.T, k8sNamespaceTerratestOptions *terraform.Options) {
-	kubectlOptions := k8s.NewKubectlOptions("", "", "")
 	namespace := terraform.Output(t, k8sNamespaceTerratestOptions, "name")
 	serviceAccountName := terraform.Output(t, k8sNamespaceTerratestOptions, "service_account_access_all")
-	templateArgs := TemplateArgs{
-		Namespace:          namespace,
-		ServiceAccountName: serviceAccountName,
-	}
-	defaultNamespaceTemplateArgs := TemplateArgs{
-		Namespace: "default",
-	}
-	checkCreatePod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-create-pod.json.tpl", templateArgs)
-	checkListPod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-list-pod.json.tpl", templateArgs)
-	checkDefaultCreatePod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-create-pod.json.tpl", defaultNamespaceTemplateArgs)
-	checkDefaultListPod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-list-pod.json.tpl", defaultNamespaceTemplateArgs)
 
-	// Verify read write access to the targeted namespace using auth can-i API, but not to the default namespace
-	checkAccessForServiceAccount(
-		t,
-		kubectlOptions,
-		namespace,
-		serviceAccountName,
-		func(t *testing.T, namespacedKubectlOptions *k8s.KubectlOptions, curlPodName string) {
-			assert.True(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkCreatePod))
-			assert.True(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkListPod))
-			assert.False(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkDefaultCreatePod))
-			assert.False(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkDefaultListPod))
-		},
-	)
+	// Verify read write access to the targeted namespace, but not to the default namespace
+	expectations := []rbac.AccessExpectation{
+		{Verb: "create", Resource: "pods", Namespace: namespace, Allowed: true},
+		{Verb: "list", Resource: "pods", Namespace: namespace, Allowed: true},
+		{Verb: "delete", Group: "apps", Resource: "deployments", Namespace: namespace, Allowed: true},
+		{Verb: "create", Resource: "pods", Namespace: "default", Allowed: false},
+		{Verb: "list", Resource: "pods", Namespace: "default", Allowed: false},
+	}
+	assertServiceAccountAccess(t, namespace, serviceAccountName, expectations)
 }
 
 // validateRbacAccessReadOnly verifies that the access read only RBAC role has read only privileges to the namespace
 func validateRbacAccessReadOnly(t *testing.T, k8sNamespaceTerratestOptions *terraform.Options) {
-	kubectlOptions := k8s.NewKubectlOptions("", "", "")
 	namespace := terraform.Output(t, k8sNamespaceTerratestOptions, "name")
 	serviceAccountName := terraform.Output(t, k8sNamespaceTerratestOptions, "service_account_access_read_only")
-	templateArgs := TemplateArgs{
-		Namespace:          namespace,
-		ServiceAccountName: serviceAccountName,
+
+	// Verify read only access to the targeted namespace, but not to the default namespace
+	expectations := []rbac.AccessExpectation{
+		{Verb: "create", Resource: "pods", Namespace: namespace, Allowed: false},
+		{Verb: "list", Resource: "pods", Namespace: namespace, Allowed: true},
+		{Verb: "get", Group: "apps", Resource: "deployments", Namespace: namespace, Allowed: true},
+		{Verb: "delete", Group: "apps", Resource: "deployments", Namespace: namespace, Allowed: false},
+		{Verb: "create", Resource: "pods", Namespace: "default", Allowed: false},
+		{Verb: "list", Resource: "pods", Namespace: "default", Allowed: false},
 	}
-	defaultNamespaceTemplateArgs := TemplateArgs{
-		Namespace: "default",
+	assertServiceAccountAccess(t, namespace, serviceAccountName, expectations)
+}
+
+// assertServiceAccountAccess checks the expectations for the ServiceAccount. By default, this impersonates the
+// ServiceAccount and asks the API for the access review directly. When RBAC_CHECK_E2E is set, this instead makes the
+// access review requests from within a Pod that runs as the ServiceAccount, which is slower but exercises the
+// ServiceAccount token end to end.
+func assertServiceAccountAccess(
+	t *testing.T,
+	namespace string,
+	serviceAccountName string,
+	expectations []rbac.AccessExpectation,
+) {
+	kubectlOptions := getTestCluster(t).KubectlOptions("")
+	if os.Getenv(rbacCheckE2EEnvVar) == "" {
+		rbac.AssertServiceAccountAccess(t, kubectlOptions, namespace, serviceAccountName, expectations)
+		return
 	}
-	checkCreatePod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-create-pod.json.tpl", templateArgs)
-	checkListPod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-list-pod.json.tpl", templateArgs)
-	checkDefaultCreatePod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-create-pod.json.tpl", defaultNamespaceTemplateArgs)
-	checkDefaultListPod := RenderTemplateAsString(t, "./kubefixtures/namespace-check-list-pod.json.tpl", defaultNamespaceTemplateArgs)
 
-	// Verify read only access to the targeted namespace using auth can-i API, but not to the default namespace
 	checkAccessForServiceAccount(
 		t,
 		kubectlOptions,
 		namespace,
 		serviceAccountName,
 		func(t *testing.T, namespacedKubectlOptions *k8s.KubectlOptions, curlPodName string) {
-			// Verify read write access to the targeted namespace using auth can-i API, but not to the default namespace
-			assert.False(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkCreatePod))
-			assert.True(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkListPod))
-			assert.False(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkDefaultCreatePod))
-			assert.False(t, canAccess(t, namespacedKubectlOptions, curlPodName, checkDefaultListPod))
+			checkAccess := func(expectation rbac.AccessExpectation) (bool, string, error) {
+				return canAccess(t, namespacedKubectlOptions, curlPodName, expectation), "", nil
+			}
+			userName := rbac.ServiceAccountUserName(namespace, serviceAccountName)
+			require.NoError(t, rbac.CheckAccessE(userName, expectations, checkAccess))
 		},
 	)
 }
@@ -228,15 +248,22 @@ func checkAccessForServiceAccount(
 	// Wait for up to 5 minutes for pod to start (60 tries, 5 seconds inbetween each trial)
 	// We explicitly set the namespace to default here, because the Kubernetes API requires an explicit namespace when
 	// looking up pods by name.
-	namespacedKubectlOptions := k8s.NewKubectlOptions("", "", namespace)
+	namespacedKubectlOptions := k8s.NewKubectlOptions(kubectlOptions.ContextName, kubectlOptions.ConfigPath, namespace)
 	k8s.WaitUntilPodAvailable(t, namespacedKubectlOptions, curlPodName, 60, 5*time.Second)
 
 	// Run the check function while the curl pod is up
 	accessCheckFunc(t, namespacedKubectlOptions, curlPodName)
 }
 
-// canAccess checks if the ServiceAccount of the curl pod can perform the action described in the json data.
-func canAccess(t *testing.T, kubectlOptions *k8s.KubectlOptions, curlPodName string, actionJsonData string) bool {
+// canAccess checks if the ServiceAccount of the curl pod can perform the action described in the expectation.
+func canAccess(
+	t *testing.T,
+	kubectlOptions *k8s.KubectlOptions,
+	curlPodName string,
+	expectation rbac.AccessExpectation,
+) bool {
+	actionJsonData, err := json.Marshal(expectation.SelfSubjectAccessReview())
+	require.NoError(t, err)
 	rawCheckResult, err := k8s.RunKubectlAndGetOutputE(
 		t,
 		kubectlOptions,
@@ -254,7 +281,7 @@ func canAccess(t *testing.T, kubectlOptions *k8s.KubectlOptions, curlPodName str
 		"-H",
 		"Content-type: application/json",
 		"-d",
-		actionJsonData,
+		string(actionJsonData),
 		"localhost:8001/apis/authorization.k8s.io/v1/selfsubjectaccessreviews",
 	)
 	require.NoError(t, err)
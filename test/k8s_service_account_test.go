@@ -0,0 +1,325 @@
+package test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// The image of the test Pod, which is pulled through the test registry. It must have the sleep and cat commands.
+	serviceAccountPodImage = "library/busybox:1.31"
+	dummyRegistryServer    = "registry.example.com"
+
+	podAsServiceAccountTemplatePath = "./kubefixtures/pod-as-service-account.yml.tpl"
+	htpasswdRegistryTemplatePath    = "./kubefixtures/htpasswd-registry.yml.tpl"
+	serviceAccountTokenMountPath    = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// testRegistry is the registry with htpasswd auth that the image pull secret of the test authenticates to.
+type testRegistry struct {
+	PodName  string
+	Port     int
+	Username string
+	Password string
+}
+
+// Server returns the address that the container runtime of the node pulls images from the registry with.
+func (registry testRegistry) Server() string {
+	return fmt.Sprintf("localhost:%d", registry.Port)
+}
+
+// Image returns the reference of the test Pod image in the registry.
+func (registry testRegistry) Image() string {
+	return fmt.Sprintf("%s/%s", registry.Server(), serviceAccountPodImage)
+}
+
+// startTestRegistry starts a registry with htpasswd auth in the namespace of the provided KubectlOptions, on a random
+// port of the host network, and waits for it to be ready. Returns the registry and its Pod config, which the caller
+// should delete when the test is done.
+func startTestRegistry(t *testing.T, kubectlOptions *k8s.KubectlOptions, uniqueID string) (testRegistry, string) {
+	registry := testRegistry{
+		PodName: fmt.Sprintf("%s-registry", kubectlOptions.Namespace),
+		// Stay clear of the NodePort range, which kube-proxy reserves on the host network.
+		Port:     random.Random(40000, 49999),
+		Username: "terratest",
+		Password: uniqueID,
+	}
+	templateArgs := TemplateArgs{
+		Namespace:        kubectlOptions.Namespace,
+		PodName:          registry.PodName,
+		RegistryPort:     registry.Port,
+		RegistryUsername: registry.Username,
+		RegistryPassword: registry.Password,
+	}
+	registryConfig := RenderTemplateAsString(t, htpasswdRegistryTemplatePath, templateArgs)
+	k8s.KubectlApplyFromString(t, kubectlOptions, registryConfig)
+	// Wait for up to 5 minutes for the registry to start (60 tries, 5 seconds inbetween each trial)
+	k8s.WaitUntilPodAvailable(t, kubectlOptions, registry.PodName, 60, 5*time.Second)
+	return registry, registryConfig
+}
+
+// TestK8SServiceAccountSecrets applies the k8s-service-account module with image pull secrets and pod secrets, and
+// checks that they end up on the ServiceAccount and on the Pods that run as it, and that the token is only mounted into
+// those Pods when automount_service_account_token is set.
+func TestK8SServiceAccountSecrets(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                         string
+		automountServiceAccountToken bool
+	}{
+		{"Automount", true},
+		{"NoAutomount", false},
+	}
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change across the parallel subtests
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			uniqueID := random.UniqueId()
+			namespace := strings.ToLower(uniqueID)
+			testCluster := getTestCluster(t)
+			kubectlOptions := testCluster.KubectlOptions(namespace)
+			leakSnapshot := snapshotClusterResources(t)
+			defer checkForLeakedResources(t, leakSnapshot, namespace)
+			k8s.CreateNamespace(t, kubectlOptions, namespace)
+			defer k8s.DeleteNamespace(t, kubectlOptions, namespace)
+
+			clientset, err := k8s.GetKubernetesClientFromOptionsE(t, kubectlOptions)
+			require.NoError(t, err)
+			registry, registryConfig := startTestRegistry(t, kubectlOptions, uniqueID)
+			defer k8s.KubectlDeleteFromString(t, kubectlOptions, registryConfig)
+			pullSecretNames := []string{
+				fmt.Sprintf("%s-registry", namespace),
+				fmt.Sprintf("%s-registry-mirror", namespace),
+			}
+			createDockerRegistrySecret(
+				t,
+				clientset,
+				namespace,
+				pullSecretNames[0],
+				registry.Server(),
+				registry.Username,
+				registry.Password,
+			)
+			createDockerRegistrySecret(t, clientset, namespace, pullSecretNames[1], dummyRegistryServer, "mirror", uniqueID)
+			podSecretNames := []string{fmt.Sprintf("%s-app-config", namespace)}
+			_, err = clientset.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: podSecretNames[0], Namespace: namespace},
+				StringData: map[string]string{"config.yaml": "replicas: 1\n"},
+			})
+			require.NoError(t, err)
+
+			modulePath := test_structure.CopyTerraformFolderToTemp(t, "..", "modules/k8s-service-account")
+			terraformVars := tfvars.ServiceAccountModuleVars{
+				Name:                         fmt.Sprintf("%s-deployer", namespace),
+				Namespace:                    namespace,
+				AutomountServiceAccountToken: tfvars.Bool(testCase.automountServiceAccountToken),
+				SecretsForPullingImages:      pullSecretNames,
+				SecretsForPods:               podSecretNames,
+			}
+			terratestOptions := createTerraformOptionsFromVars(t, modulePath, terraformVars.ToVars())
+			// The module doesn't configure the kubernetes provider, so we point the provider at the cluster through the
+			// environment.
+			terratestOptions.EnvVars = testCluster.TerraformEnvVars()
+			defer terraform.Destroy(t, terratestOptions)
+			terraform.InitAndApply(t, terratestOptions)
+
+			serviceAccountName := terraform.Output(t, terratestOptions, "name")
+			validateServiceAccountSecrets(t, clientset, namespace, serviceAccountName, pullSecretNames, podSecretNames)
+			validatePodAsServiceAccount(
+				t,
+				kubectlOptions,
+				clientset,
+				serviceAccountName,
+				registry.Image(),
+				pullSecretNames,
+				testCase.automountServiceAccountToken,
+			)
+			validateImagePullRequiresSecret(t, kubectlOptions, clientset, registry.Image())
+		})
+	}
+}
+
+// validateServiceAccountSecrets verifies the imagePullSecrets and secrets fields of the ServiceAccount.
+func validateServiceAccountSecrets(
+	t *testing.T,
+	clientset kubernetes.Interface,
+	namespace string,
+	serviceAccountName string,
+	pullSecretNames []string,
+	podSecretNames []string,
+) {
+	serviceAccount, err := clientset.CoreV1().ServiceAccounts(namespace).Get(serviceAccountName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	imagePullSecretNames := []string{}
+	for _, reference := range serviceAccount.ImagePullSecrets {
+		imagePullSecretNames = append(imagePullSecretNames, reference.Name)
+	}
+	assert.Equal(t, pullSecretNames, imagePullSecretNames)
+
+	// Older clusters also add the token Secret of the ServiceAccount to the secrets field.
+	secretNames := []string{}
+	for _, reference := range serviceAccount.Secrets {
+		secretNames = append(secretNames, reference.Name)
+	}
+	for _, podSecretName := range podSecretNames {
+		assert.Contains(t, secretNames, podSecretName)
+	}
+}
+
+// validatePodAsServiceAccount launches a Pod that runs as the ServiceAccount, and verifies that it gets the image pull
+// secrets of the ServiceAccount, and that the token is mounted into it if and only if automountServiceAccountToken is
+// set.
+func validatePodAsServiceAccount(
+	t *testing.T,
+	kubectlOptions *k8s.KubectlOptions,
+	clientset kubernetes.Interface,
+	serviceAccountName string,
+	image string,
+	pullSecretNames []string,
+	automountServiceAccountToken bool,
+) {
+	podName := fmt.Sprintf("%s-pod", serviceAccountName)
+	templateArgs := TemplateArgs{
+		Namespace:          kubectlOptions.Namespace,
+		ServiceAccountName: serviceAccountName,
+		PodName:            podName,
+		Image:              image,
+	}
+	podConfig := RenderTemplateAsString(t, podAsServiceAccountTemplatePath, templateArgs)
+	defer k8s.KubectlDeleteFromString(t, kubectlOptions, podConfig)
+	k8s.KubectlApplyFromString(t, kubectlOptions, podConfig)
+
+	// The ServiceAccount admission controller fills in the image pull secrets and token volume when the Pod is created.
+	pod, err := clientset.CoreV1().Pods(kubectlOptions.Namespace).Get(podName, metav1.GetOptions{})
+	require.NoError(t, err)
+	podPullSecretNames := []string{}
+	for _, reference := range pod.Spec.ImagePullSecrets {
+		podPullSecretNames = append(podPullSecretNames, reference.Name)
+	}
+	assert.Equal(t, pullSecretNames, podPullSecretNames)
+	hasTokenMount := false
+	for _, container := range pod.Spec.Containers {
+		for _, volumeMount := range container.VolumeMounts {
+			if volumeMount.MountPath == serviceAccountTokenMountPath {
+				hasTokenMount = true
+			}
+		}
+	}
+	assert.Equal(t, automountServiceAccountToken, hasTokenMount)
+
+	// Wait for up to 5 minutes for pod to start (60 tries, 5 seconds inbetween each trial)
+	k8s.WaitUntilPodAvailable(t, kubectlOptions, podName, 60, 5*time.Second)
+	output, err := k8s.RunKubectlAndGetOutputE(
+		t,
+		kubectlOptions,
+		"exec",
+		podName,
+		"-c",
+		"main",
+		"--",
+		"cat",
+		fmt.Sprintf("%s/token", serviceAccountTokenMountPath),
+	)
+	if automountServiceAccountToken {
+		assert.NoError(t, err)
+	} else {
+		// Make sure that cat failed because the token is not there, and not because the exec itself failed.
+		require.Error(t, err)
+		assert.Contains(t, output, "No such file")
+	}
+}
+
+// validateImagePullRequiresSecret verifies that a Pod running as the default ServiceAccount of the namespace, which has
+// no image pull secrets, can not pull the image from the test registry. This makes sure that the Pod running as the
+// ServiceAccount of the module pulled the image with the image pull secret, and not anonymously.
+func validateImagePullRequiresSecret(
+	t *testing.T,
+	kubectlOptions *k8s.KubectlOptions,
+	clientset kubernetes.Interface,
+	image string,
+) {
+	podName := fmt.Sprintf("%s-no-pull-secret", kubectlOptions.Namespace)
+	templateArgs := TemplateArgs{
+		Namespace:          kubectlOptions.Namespace,
+		ServiceAccountName: "default",
+		PodName:            podName,
+		Image:              image,
+	}
+	podConfig := RenderTemplateAsString(t, podAsServiceAccountTemplatePath, templateArgs)
+	defer k8s.KubectlDeleteFromString(t, kubectlOptions, podConfig)
+	k8s.KubectlApplyFromString(t, kubectlOptions, podConfig)
+
+	_, err := retry.DoWithRetryE(
+		t,
+		fmt.Sprintf("Wait for the image pull of Pod %s to fail", podName),
+		30,
+		5*time.Second,
+		func() (string, error) {
+			pod, err := clientset.CoreV1().Pods(kubectlOptions.Namespace).Get(podName, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.State.Running != nil {
+					return "", retry.FatalError{Underlying: fmt.Errorf("Pod %s pulled %s without credentials", podName, image)}
+				}
+				waiting := status.State.Waiting
+				if waiting != nil && (waiting.Reason == "ErrImagePull" || waiting.Reason == "ImagePullBackOff") {
+					return waiting.Reason, nil
+				}
+			}
+			return "", fmt.Errorf("Pod %s has not failed to pull %s yet", podName, image)
+		},
+	)
+	assert.NoError(t, err)
+}
+
+// createDockerRegistrySecret creates a Secret of type kubernetes.io/dockerconfigjson with the credentials for the
+// registry server, like `kubectl create secret docker-registry` does.
+func createDockerRegistrySecret(
+	t *testing.T,
+	clientset kubernetes.Interface,
+	namespace string,
+	name string,
+	server string,
+	username string,
+	password string,
+) {
+	auth := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password)))
+	dockerConfig, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			server: map[string]string{
+				"username": username,
+				"password": password,
+				"auth":     auth,
+			},
+		},
+	})
+	require.NoError(t, err)
+	_, err = clientset.CoreV1().Secrets(namespace).Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+	})
+	require.NoError(t, err)
+}
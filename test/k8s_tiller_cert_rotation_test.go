@@ -0,0 +1,202 @@
+package test
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/certrotation"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// The validity period of the short lived server cert that the test rotates away from.
+	tillerShortLivedCertValidityPeriod = time.Hour
+
+	// The default validity_period_hours of the TLS modules, which the test rotates back to.
+	tillerCertValidityPeriod = defaultTLSValidityPeriodHours * time.Hour
+
+	// How far ahead the expiry report warns about certificates in the test.
+	tillerCertExpiryWarningPeriod = 30 * 24 * time.Hour
+)
+
+// This test deploys the root module, replaces the Tiller server cert with a short lived one from the same CA, and
+// checks that the expiry report flags it. It then rotates the server cert again, and verifies that Tiller serves the
+// new cert after the restart and that the existing helm client certs keep working.
+func TestK8STillerCertRotation(t *testing.T) {
+	t.Parallel()
+
+	// Uncomment any of the following to skip that section during the test
+	// os.Setenv("SKIP_create_test_copy_of_examples", "true")
+	// os.Setenv("SKIP_create_terratest_options", "true")
+	// os.Setenv("SKIP_terraform_apply", "true")
+	// os.Setenv("SKIP_setup_helm_client", "true")
+	// os.Setenv("SKIP_rotate_to_short_lived_cert", "true")
+	// os.Setenv("SKIP_rotate_server_cert", "true")
+	// os.Setenv("SKIP_validate", "true")
+	// os.Setenv("SKIP_cleanup", "true")
+
+	// Create a directory path that won't conflict
+	workingDir := filepath.Join(".", "stages", t.Name())
+
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
+	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
+		uniqueID := random.UniqueId()
+		k8sTillerTerraformModulePath := test_structure.CopyTerraformFolderToTemp(t, "..", ".")
+		logger.Logf(t, "path to test folder %s\n", k8sTillerTerraformModulePath)
+		helmHome := filepath.Join(k8sTillerTerraformModulePath, ".helm")
+		// make sure to create the helm home directory
+		require.NoError(t, os.Mkdir(helmHome, 0700))
+
+		test_structure.SaveString(t, workingDir, "k8sTillerTerraformModulePath", k8sTillerTerraformModulePath)
+		test_structure.SaveString(t, workingDir, "helmHome", helmHome)
+		test_structure.SaveString(t, workingDir, "uniqueID", uniqueID)
+	})
+
+	test_structure.RunTestStage(t, "create_terratest_options", func() {
+		uniqueID := test_structure.LoadString(t, workingDir, "uniqueID")
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
+
+		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(t, k8sTillerTerraformModulePath, getTestCluster(t), helmHome, uniqueID)
+
+		test_structure.SaveTerraformOptions(t, workingDir, k8sTillerTerratestOptions)
+	})
+
+	defer test_structure.RunTestStage(t, "cleanup", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		terraform.Destroy(t, k8sTillerTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "terraform_apply", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		terraform.InitAndApply(t, k8sTillerTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "setup_helm_client", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+		rootModuleVars := tfvars.RootModuleVars{}
+		tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+		tillerVersion := rootModuleVars.TillerVersion
+		rbacUser := rootModuleVars.GrantHelmClientRBACUser
+
+		// Wait for up to 5 minutes for Tiller to come up (60 tries, 5 seconds inbetween each trial)
+		tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+		tiller.WaitForTiller(t, tillerKubectlOptions, tiller.DefaultDeploymentName, tillerVersion, 60, 5*time.Second)
+		helmhome.ConfigureHelmHome(t, tillerKubectlOptions, helmHome, rbacUser)
+	})
+
+	test_structure.RunTestStage(t, "rotate_to_short_lived_cert", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+
+		serverCert := rotateTillerServerCert(t, k8sTillerTerratestOptions, tillerShortLivedCertValidityPeriod)
+
+		// The report must flag the short lived server cert, and only that one.
+		now := time.Now()
+		err := certrotation.CheckNoneExpiringE(
+			getTillerCertExpiryReport(t, tillerNamespace, now),
+			now,
+			tillerCertExpiryWarningPeriod,
+		)
+		expiringErr, isExpiringErr := err.(certrotation.CertificatesExpiringError)
+		require.True(t, isExpiringErr, "Expected CertificatesExpiringError, got %T: %s", err, err)
+		require.Equal(t, 1, len(expiringErr.Expiring))
+		assert.Equal(t, certrotation.ServerCredentialsType, expiringErr.Expiring[0].CredentialsType)
+		assert.Equal(t, serverCert.NotAfter, expiringErr.Expiring[0].NotAfter)
+		assert.Equal(t, 0, expiringErr.Expiring[0].DaysToExpiry)
+	})
+
+	test_structure.RunTestStage(t, "rotate_server_cert", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+
+		rotateTillerServerCert(t, k8sTillerTerratestOptions, tillerCertValidityPeriod)
+
+		now := time.Now()
+		certrotation.CheckNoneExpiring(
+			t,
+			getTillerCertExpiryReport(t, tillerNamespace, now),
+			now,
+			tillerCertExpiryWarningPeriod,
+		)
+	})
+
+	test_structure.RunTestStage(t, "validate", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		resourceNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "resource_namespace")
+
+		// The helm home is unchanged, as the client certs and the CA are not rotated.
+		validateLocalChartInstalls(t, getTestCluster(t).KubectlOptions(resourceNamespace), helmHome)
+	})
+}
+
+// rotateTillerServerCert rotates the Tiller server cert of the root module to a new one with the given validity period,
+// waits for Tiller to restart, and verifies that every Tiller Pod serves the new cert. Returns the new certificate.
+func rotateTillerServerCert(
+	t *testing.T,
+	k8sTillerTerratestOptions *terraform.Options,
+	validityPeriod time.Duration,
+) *x509.Certificate {
+	tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+	rootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+	tillerVersion := rootModuleVars.TillerVersion
+	tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+
+	serverKeyPair := certrotation.RotateServerCert(
+		t,
+		tillerKubectlOptions,
+		certrotation.RotateOptions{ValidityPeriod: validityPeriod},
+	)
+	// Wait for up to 5 minutes for the restart to roll out (60 tries, 5 seconds inbetween each trial)
+	tiller.WaitForTiller(t, tillerKubectlOptions, tiller.DefaultDeploymentName, tillerVersion, 60, 5*time.Second)
+
+	caKeyPair, clientKeyPair := getTillerCAAndClientKeyPairs(t, k8sTillerTerratestOptions)
+	// The Pods from before the restart may still be terminating, and they serve the old cert until they are gone.
+	pods := tiller.ListTillerPods(t, tillerKubectlOptions, tiller.DefaultDeploymentName)
+	require.NotEmpty(t, pods)
+	for _, pod := range pods {
+		var servedCert []byte
+		tlsConfig := tiller.NewClientTLSConfig(clientKeyPair, caKeyPair.Certificate)
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			servedCert = rawCerts[0]
+			return nil
+		}
+		tiller.GetTillerVersion(t, tillerKubectlOptions, pod.Name, tlsConfig, tillerVersion)
+		assert.Equal(t, serverKeyPair.Certificate.Raw, servedCert, "Tiller Pod %s serves the old cert", pod.Name)
+	}
+	return serverKeyPair.Certificate
+}
+
+// getTillerCertExpiryReport returns the expiry report of the Tiller credentials of the given Tiller namespace, leaving
+// out the certificates of the other tests that run in the same cluster.
+func getTillerCertExpiryReport(
+	t *testing.T,
+	tillerNamespace string,
+	now time.Time,
+) []certrotation.CertificateExpiry {
+	report := []certrotation.CertificateExpiry{}
+	for _, expiry := range certrotation.GetExpiryReport(t, getTestCluster(t).KubectlOptions(""), now) {
+		if expiry.TillerNamespace == tillerNamespace {
+			report = append(report, expiry)
+		}
+	}
+	return report
+}
@@ -6,14 +6,19 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/kubeconfig"
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/gruntwork-io/terratest/modules/random"
-	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/gruntwork-io/terratest/modules/retry"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
 	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 func TestK8STillerKubergrunt(t *testing.T) {
@@ -31,6 +36,10 @@ func TestK8STillerKubergrunt(t *testing.T) {
 	// Create a directory path that won't conflict
 	workingDir := filepath.Join(".", "stages", t.Name())
 
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
 	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
 		k8sTillerTerraformModulePath := test_structure.CopyTerraformFolderToTemp(t, "..", "examples/k8s-tiller-kubergrunt-minikube")
 		logger.Logf(t, "path to test folder %s\n", k8sTillerTerraformModulePath)
@@ -47,14 +56,46 @@ func TestK8STillerKubergrunt(t *testing.T) {
 		uniqueID := random.UniqueId()
 		testServiceAccountName := fmt.Sprintf("%s-test-account", strings.ToLower(uniqueID))
 		testServiceAccountNamespace := fmt.Sprintf("%s-test-account-namespace", strings.ToLower(uniqueID))
-		tmpConfigPath := k8s.CopyHomeKubeConfigToTemp(t)
-		kubectlOptions := k8s.NewKubectlOptions("", tmpConfigPath, "")
+		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
+		testCluster := getTestCluster(t)
+		kubectlOptions := testCluster.KubectlOptions("")
 
 		k8s.CreateNamespace(t, kubectlOptions, testServiceAccountNamespace)
 		kubectlOptions.Namespace = testServiceAccountNamespace
 		k8s.CreateServiceAccount(t, kubectlOptions, testServiceAccountName)
-		token := k8s.GetServiceAccountAuthToken(t, kubectlOptions, testServiceAccountName)
-		err := k8s.AddConfigContextForServiceAccountE(t, kubectlOptions, testServiceAccountName, testServiceAccountName, token)
+
+		// Write a standalone kubeconfig for the ServiceAccount, instead of adding a context to a copy of the shared
+		// kubeconfig. The token controller links the token Secret to the ServiceAccount asynchronously, so we retry
+		// until it shows up. We read the token from the Secret, as the TokenRequest API is not enabled on all the
+		// clusters we test against.
+		serviceAccountKubeConfigPath := filepath.Join(k8sTillerTerraformModulePath, "service-account-kubeconfig")
+		var config *clientcmdapi.Config
+		_, err := retry.DoWithRetryE(
+			t,
+			fmt.Sprintf("Generate kubeconfig for ServiceAccount %s", testServiceAccountName),
+			30,
+			2*time.Second,
+			func() (string, error) {
+				var err error
+				config, err = kubeconfig.GenerateForServiceAccountE(
+					t,
+					kubectlOptions,
+					kubeconfig.Options{
+						ServiceAccountName: testServiceAccountName,
+						Namespace:          testServiceAccountNamespace,
+						Server:             testCluster.Server(t),
+						TokenSource:        kubeconfig.TokenSourceSecret,
+					},
+				)
+				if err != nil {
+					return "", err
+				}
+				return "Generated kubeconfig", nil
+			},
+		)
+		if err == nil {
+			err = clientcmd.WriteToFile(*config, serviceAccountKubeConfigPath)
+		}
 		// We do the error check and namespace deletion manually here, because we can't defer it within the test stage.
 		if err != nil {
 			k8s.DeleteNamespace(t, kubectlOptions, testServiceAccountNamespace)
@@ -62,7 +103,7 @@ func TestK8STillerKubergrunt(t *testing.T) {
 		}
 
 		test_structure.SaveString(t, workingDir, "uniqueID", uniqueID)
-		test_structure.SaveString(t, workingDir, "tmpKubectlConfigPath", tmpConfigPath)
+		test_structure.SaveString(t, workingDir, "serviceAccountKubeConfigPath", serviceAccountKubeConfigPath)
 		test_structure.SaveString(t, workingDir, "testServiceAccountName", testServiceAccountName)
 		test_structure.SaveString(t, workingDir, "testServiceAccountNamespace", testServiceAccountNamespace)
 	})
@@ -74,7 +115,7 @@ func TestK8STillerKubergrunt(t *testing.T) {
 		testServiceAccountNamespace := test_structure.LoadString(t, workingDir, "testServiceAccountNamespace")
 		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
 
-		k8sTillerTerratestOptions := createExampleK8STillerKubergruntTerraformOptions(t, k8sTillerTerraformModulePath, helmHome, uniqueID, testServiceAccountName, testServiceAccountNamespace)
+		k8sTillerTerratestOptions := createExampleK8STillerKubergruntTerraformOptions(t, k8sTillerTerraformModulePath, getTestCluster(t), helmHome, uniqueID, testServiceAccountName, testServiceAccountNamespace)
 
 		test_structure.SaveTerraformOptions(t, workingDir, k8sTillerTerratestOptions)
 	})
@@ -84,7 +125,7 @@ func TestK8STillerKubergrunt(t *testing.T) {
 		terraform.Destroy(t, k8sTillerTerratestOptions)
 
 		testServiceAccountNamespace := test_structure.LoadString(t, workingDir, "testServiceAccountNamespace")
-		kubectlOptions := k8s.NewKubectlOptions("", "", "")
+		kubectlOptions := getTestCluster(t).KubectlOptions("")
 		k8s.DeleteNamespace(t, kubectlOptions, testServiceAccountNamespace)
 	})
 
@@ -97,27 +138,19 @@ func TestK8STillerKubergrunt(t *testing.T) {
 		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
 		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
 		resourceNamespace := k8sTillerTerratestOptions.Vars["resource_namespace"].(string)
-		tmpConfigPath := test_structure.LoadString(t, workingDir, "tmpKubectlConfigPath")
+		serviceAccountKubeConfigPath := test_structure.LoadString(t, workingDir, "serviceAccountKubeConfigPath")
 		testServiceAccountName := test_structure.LoadString(t, workingDir, "testServiceAccountName")
-		kubectlOptions := k8s.NewKubectlOptions(testServiceAccountName, tmpConfigPath, resourceNamespace)
+		kubectlOptions := k8s.NewKubectlOptions(testServiceAccountName, serviceAccountKubeConfigPath, resourceNamespace)
 
-		runHelm(
-			t,
-			kubectlOptions,
-			helmHome,
-			"install",
-			"stable/kubernetes-dashboard",
-			"--wait",
-		)
+		validateLocalChartInstalls(t, kubectlOptions, helmHome)
 	})
 
 	test_structure.RunTestStage(t, "validate_upgrade", func() {
 		// Make sure the upgrade command mentioned in the docs actually works
 		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
-		tmpConfigPath := test_structure.LoadString(t, workingDir, "tmpKubectlConfigPath")
-		kubectlOptions := k8s.NewKubectlOptions("", tmpConfigPath, "")
+		kubectlOptions := getTestCluster(t).KubectlOptions("")
 
-		runHelm(
+		helmhome.RunHelm(
 			t,
 			kubectlOptions,
 			helmHome,
@@ -127,29 +160,3 @@ func TestK8STillerKubergrunt(t *testing.T) {
 		)
 	})
 }
-
-func runHelm(t *testing.T, options *k8s.KubectlOptions, helmHome string, args ...string) {
-	helmArgs := []string{"helm"}
-	if options.ContextName != "" {
-		helmArgs = append(helmArgs, "--kube-context", options.ContextName)
-	}
-	if options.ConfigPath != "" {
-		helmArgs = append(helmArgs, "--kubeconfig", options.ConfigPath)
-	}
-	if options.Namespace != "" {
-		helmArgs = append(helmArgs, "--namespace", options.Namespace)
-	}
-	helmArgs = append(helmArgs, args...)
-	helmCmd := strings.Join(helmArgs, " ")
-
-	// TODO: make this test platform independent
-	helmEnvPath := filepath.Join(helmHome, "env")
-	cmd := shell.Command{
-		Command: "sh",
-		Args: []string{
-			"-c",
-			fmt.Sprintf(". %s && %s", helmEnvPath, helmCmd),
-		},
-	}
-	shell.RunCommand(t, cmd)
-}
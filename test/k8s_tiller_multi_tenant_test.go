@@ -0,0 +1,230 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The names of the two independent stacks that TestK8STillerMultiTenantIsolation deploys from the root module.
+var multiTenantStacks = []string{"stack-a", "stack-b"}
+
+// This test deploys two independent stacks from the root module, each with its own Tiller, Tiller namespace, and
+// resource namespace, and verifies that neither stack can touch the other: the helm client certs of one stack are
+// rejected by the Tiller of the other, and each Tiller is forbidden by RBAC from deploying into the resource namespace of
+// the other.
+func TestK8STillerMultiTenantIsolation(t *testing.T) {
+	t.Parallel()
+
+	// Uncomment any of the following to skip that section during the test
+	// os.Setenv("SKIP_create_test_copy_of_examples", "true")
+	// os.Setenv("SKIP_create_terratest_options", "true")
+	// os.Setenv("SKIP_terraform_apply", "true")
+	// os.Setenv("SKIP_setup_helm_client", "true")
+	// os.Setenv("SKIP_validate_tls_isolation", "true")
+	// os.Setenv("SKIP_validate_rbac_isolation", "true")
+	// os.Setenv("SKIP_cleanup", "true")
+
+	// Create a directory path that won't conflict. Each stack gets its own directory, so that the stages can save the
+	// terraform options of both.
+	workingDir := filepath.Join(".", "stages", t.Name())
+	stackWorkingDirs := map[string]string{}
+	for _, stack := range multiTenantStacks {
+		stackWorkingDirs[stack] = filepath.Join(workingDir, stack)
+	}
+
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	for _, stack := range multiTenantStacks {
+		defer checkForLeakedResourcesOfStages(t, leakSnapshot, stackWorkingDirs[stack])
+	}
+
+	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
+		for _, stack := range multiTenantStacks {
+			stackWorkingDir := stackWorkingDirs[stack]
+			uniqueID := random.UniqueId()
+			k8sTillerTerraformModulePath := test_structure.CopyTerraformFolderToTemp(t, "..", ".")
+			logger.Logf(t, "path to test folder of %s %s\n", stack, k8sTillerTerraformModulePath)
+			helmHome := filepath.Join(k8sTillerTerraformModulePath, ".helm")
+			// make sure to create the helm home directory
+			require.NoError(t, os.Mkdir(helmHome, 0700))
+
+			test_structure.SaveString(t, stackWorkingDir, "k8sTillerTerraformModulePath", k8sTillerTerraformModulePath)
+			test_structure.SaveString(t, stackWorkingDir, "helmHome", helmHome)
+			test_structure.SaveString(t, stackWorkingDir, "uniqueID", uniqueID)
+		}
+	})
+
+	test_structure.RunTestStage(t, "create_terratest_options", func() {
+		for _, stack := range multiTenantStacks {
+			stackWorkingDir := stackWorkingDirs[stack]
+			uniqueID := test_structure.LoadString(t, stackWorkingDir, "uniqueID")
+			helmHome := test_structure.LoadString(t, stackWorkingDir, "helmHome")
+			k8sTillerTerraformModulePath := test_structure.LoadString(t, stackWorkingDir, "k8sTillerTerraformModulePath")
+
+			k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(t, k8sTillerTerraformModulePath, getTestCluster(t), helmHome, uniqueID)
+
+			test_structure.SaveTerraformOptions(t, stackWorkingDir, k8sTillerTerratestOptions)
+		}
+	})
+
+	defer test_structure.RunTestStage(t, "cleanup", func() {
+		for _, stack := range multiTenantStacks {
+			k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, stackWorkingDirs[stack])
+			// Destroy both stacks, even if destroying the first one fails.
+			if _, err := terraform.DestroyE(t, k8sTillerTerratestOptions); err != nil {
+				t.Errorf("Error destroying %s: %s", stack, err)
+			}
+		}
+	})
+
+	test_structure.RunTestStage(t, "terraform_apply", func() {
+		for _, stack := range multiTenantStacks {
+			k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, stackWorkingDirs[stack])
+			terraform.InitAndApply(t, k8sTillerTerratestOptions)
+		}
+	})
+
+	test_structure.RunTestStage(t, "setup_helm_client", func() {
+		for _, stack := range multiTenantStacks {
+			stackWorkingDir := stackWorkingDirs[stack]
+			helmHome := test_structure.LoadString(t, stackWorkingDir, "helmHome")
+			k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, stackWorkingDir)
+			tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+			rootModuleVars := tfvars.RootModuleVars{}
+			tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+			tillerVersion := rootModuleVars.TillerVersion
+			rbacUser := rootModuleVars.GrantHelmClientRBACUser
+
+			// Wait for up to 5 minutes for Tiller to come up (60 tries, 5 seconds inbetween each trial)
+			tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+			tiller.WaitForTiller(t, tillerKubectlOptions, tiller.DefaultDeploymentName, tillerVersion, 60, 5*time.Second)
+			helmhome.ConfigureHelmHome(t, tillerKubectlOptions, helmHome, rbacUser)
+		}
+	})
+
+	test_structure.RunTestStage(t, "validate_tls_isolation", func() {
+		stackA := test_structure.LoadTerraformOptions(t, stackWorkingDirs[multiTenantStacks[0]])
+		stackB := test_structure.LoadTerraformOptions(t, stackWorkingDirs[multiTenantStacks[1]])
+
+		validateTillerRejectsClientCertsOfOtherStack(t, stackA, stackB)
+		validateTillerRejectsClientCertsOfOtherStack(t, stackB, stackA)
+	})
+
+	test_structure.RunTestStage(t, "validate_rbac_isolation", func() {
+		stackAWorkingDir := stackWorkingDirs[multiTenantStacks[0]]
+		stackBWorkingDir := stackWorkingDirs[multiTenantStacks[1]]
+		stackAHelmHome := test_structure.LoadString(t, stackAWorkingDir, "helmHome")
+		stackBHelmHome := test_structure.LoadString(t, stackBWorkingDir, "helmHome")
+		stackA := test_structure.LoadTerraformOptions(t, stackAWorkingDir)
+		stackB := test_structure.LoadTerraformOptions(t, stackBWorkingDir)
+
+		validateTillerCannotDeployToOtherStack(t, stackAHelmHome, stackA, stackB)
+		validateTillerCannotDeployToOtherStack(t, stackBHelmHome, stackB, stackA)
+	})
+}
+
+// validateTillerRejectsClientCertsOfOtherStack verifies that the Tiller of the target stack rejects connections that use
+// the helm client cert of the source stack, while it accepts its own helm client cert. The connection to the target
+// Tiller verifies the server against the target CA, so that only the client cert differs between the two connections.
+func validateTillerRejectsClientCertsOfOtherStack(
+	t *testing.T,
+	sourceTerratestOptions *terraform.Options,
+	targetTerratestOptions *terraform.Options,
+) {
+	_, sourceClientKeyPair := getTillerCAAndClientKeyPairs(t, sourceTerratestOptions)
+	targetCAKeyPair, targetClientKeyPair := getTillerCAAndClientKeyPairs(t, targetTerratestOptions)
+	targetTillerNamespace := terraform.OutputRequired(t, targetTerratestOptions, "tiller_namespace")
+	targetRootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, targetTerratestOptions.Vars, &targetRootModuleVars)
+	targetTillerVersion := targetRootModuleVars.TillerVersion
+	targetTillerKubectlOptions := getTestCluster(t).KubectlOptions(targetTillerNamespace)
+
+	pods := k8s.ListPods(t, targetTillerKubectlOptions, metav1.ListOptions{
+		LabelSelector: tiller.TillerPodLabelSelector(tiller.DefaultDeploymentName),
+	})
+	require.NotEmpty(t, pods)
+	for _, pod := range pods {
+		version := tiller.GetTillerVersion(
+			t,
+			targetTillerKubectlOptions,
+			pod.Name,
+			tiller.NewClientTLSConfig(targetClientKeyPair, targetCAKeyPair.Certificate),
+			targetTillerVersion,
+		)
+		assert.Equal(t, targetTillerVersion, version)
+
+		_, err := tiller.GetTillerVersionE(
+			t,
+			targetTillerKubectlOptions,
+			pod.Name,
+			tiller.NewClientTLSConfig(sourceClientKeyPair, targetCAKeyPair.Certificate),
+			targetTillerVersion,
+		)
+		require.Error(
+			t,
+			err,
+			"Tiller Pod %s in namespace %s accepted the helm client cert of another stack",
+			pod.Name,
+			targetTillerNamespace,
+		)
+		_, isTLSRejected := err.(tiller.TillerTLSRejectedError)
+		assert.True(
+			t,
+			isTLSRejected,
+			"Expected Tiller Pod %s in namespace %s to reject the TLS handshake, got %T: %s",
+			pod.Name,
+			targetTillerNamespace,
+			err,
+			err,
+		)
+	}
+}
+
+// validateTillerCannotDeployToOtherStack verifies that the Tiller of the source stack can install a chart into its own
+// resource namespace, but fails with an RBAC error when the same chart is aimed at the resource namespace of the target
+// stack.
+func validateTillerCannotDeployToOtherStack(
+	t *testing.T,
+	sourceHelmHome string,
+	sourceTerratestOptions *terraform.Options,
+	targetTerratestOptions *terraform.Options,
+) {
+	sourceResourceNamespace := terraform.OutputRequired(t, sourceTerratestOptions, "resource_namespace")
+	targetResourceNamespace := terraform.OutputRequired(t, targetTerratestOptions, "resource_namespace")
+	sourceKubectlOptions := getTestCluster(t).KubectlOptions(sourceResourceNamespace)
+	targetKubectlOptions := getTestCluster(t).KubectlOptions(targetResourceNamespace)
+
+	packageDir, err := ioutil.TempDir("", "helm-test-charts")
+	require.NoError(t, err)
+	defer os.RemoveAll(packageDir)
+
+	// Make sure the chart installs through the source Tiller, so that the failure below can only be due to the namespace.
+	releaseName := localChartReleaseName(deploymentChart)
+	defer deleteHelmRelease(t, sourceKubectlOptions, sourceHelmHome, releaseName)
+	installLocalChart(t, sourceKubectlOptions, sourceHelmHome, packageDir, deploymentChart, releaseName, "--wait")
+
+	otherReleaseName := localChartReleaseName(deploymentChart)
+	defer deleteHelmRelease(t, targetKubectlOptions, sourceHelmHome, otherReleaseName)
+	err = installLocalChartE(t, targetKubectlOptions, sourceHelmHome, packageDir, deploymentChart, otherReleaseName)
+	require.Error(t, err, "Expected install into namespace %s to fail", targetResourceNamespace)
+	commandFailedErr, isCommandFailedErr := err.(helmhome.CommandFailedError)
+	require.True(t, isCommandFailedErr, "Expected helmhome.CommandFailedError, got %T: %s", err, err)
+	// The Tiller ServiceAccount is only bound to roles in its own namespaces, so the API server forbids the request.
+	assert.Contains(t, commandFailedErr.Stderr, "forbidden")
+	assert.Contains(t, commandFailedErr.Stderr, targetResourceNamespace)
+}
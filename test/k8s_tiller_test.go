@@ -1,19 +1,35 @@
 package test
 
 import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/clientcerts"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
 	"github.com/gruntwork-io/terratest/modules/k8s"
 	"github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/shell"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// tillerHistoryMax is the number of revisions per release that TestK8STiller configures Tiller to keep.
+const tillerHistoryMax = 3
+
 // This test makes sure the root example can run without errors on a machine without kubergrunt
 func TestK8STillerNoKubergrunt(t *testing.T) {
 	t.Parallel()
@@ -31,6 +47,10 @@ func TestK8STillerNoKubergrunt(t *testing.T) {
 	// Create a directory path that won't conflict
 	workingDir := filepath.Join(".", "stages", t.Name())
 
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
 	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
 		uniqueID := random.UniqueId()
 		k8sTillerTerraformModulePath := test_structure.CopyTerraformFolderToTemp(t, "..", ".")
@@ -49,7 +69,7 @@ func TestK8STillerNoKubergrunt(t *testing.T) {
 		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
 		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
 
-		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(t, k8sTillerTerraformModulePath, helmHome, uniqueID)
+		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(t, k8sTillerTerraformModulePath, getTestCluster(t), helmHome, uniqueID)
 
 		test_structure.SaveTerraformOptions(t, workingDir, k8sTillerTerratestOptions)
 	})
@@ -73,13 +93,22 @@ func TestK8STiller(t *testing.T) {
 	// os.Setenv("SKIP_create_test_service_account", "true")
 	// os.Setenv("SKIP_create_terratest_options", "true")
 	// os.Setenv("SKIP_terraform_apply", "true")
+	// os.Setenv("SKIP_validate_tls_certs", "true")
+	// os.Setenv("SKIP_validate_deployment", "true")
 	// os.Setenv("SKIP_setup_helm_client", "true")
+	// os.Setenv("SKIP_validate_tiller_mtls", "true")
+	// os.Setenv("SKIP_validate_client_certs", "true")
 	// os.Setenv("SKIP_validate", "true")
+	// os.Setenv("SKIP_validate_release_history", "true")
 	// os.Setenv("SKIP_cleanup", "true")
 
 	// Create a directory path that won't conflict
 	workingDir := filepath.Join(".", "stages", t.Name())
 
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
 	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
 		uniqueID := random.UniqueId()
 		k8sTillerTerraformModulePath := test_structure.CopyTerraformFolderToTemp(t, "..", ".")
@@ -98,7 +127,14 @@ func TestK8STiller(t *testing.T) {
 		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
 		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
 
-		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(t, k8sTillerTerraformModulePath, helmHome, uniqueID)
+		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(
+			t,
+			k8sTillerTerraformModulePath,
+			getTestCluster(t),
+			helmHome,
+			uniqueID,
+			func(vars *tfvars.RootModuleVars) { vars.TillerHistoryMax = tfvars.Int(tillerHistoryMax) },
+		)
 
 		test_structure.SaveTerraformOptions(t, workingDir, k8sTillerTerratestOptions)
 	})
@@ -113,88 +149,337 @@ func TestK8STiller(t *testing.T) {
 		terraform.InitAndApply(t, k8sTillerTerratestOptions)
 	})
 
+	test_structure.RunTestStage(t, "validate_tls_certs", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		validateTillerTLSCerts(t, k8sTillerTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "validate_deployment", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		validateTillerDeployment(t, k8sTillerTerratestOptions)
+	})
+
 	test_structure.RunTestStage(t, "setup_helm_client", func() {
 		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
-		kubectlOptions := k8s.NewKubectlOptions("", "", "")
+		kubectlOptions := getTestCluster(t).KubectlOptions("")
 		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
 		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
-		resourceNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "resource_namespace")
-		tillerVersion := k8sTillerTerratestOptions.Vars["tiller_version"].(string)
+		rootModuleVars := tfvars.RootModuleVars{}
+		tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+		tillerVersion := rootModuleVars.TillerVersion
+		rbacUser := rootModuleVars.GrantHelmClientRBACUser
+
+		// Wait for up to 5 minutes for Tiller to come up (60 tries, 5 seconds inbetween each trial)
+		tillerKubectlOptions := k8s.NewKubectlOptions(kubectlOptions.ContextName, kubectlOptions.ConfigPath, tillerNamespace)
+		tiller.WaitForTiller(t, tillerKubectlOptions, tiller.DefaultDeploymentName, tillerVersion, 60, 5*time.Second)
+		helmhome.ConfigureHelmHome(t, tillerKubectlOptions, helmHome, rbacUser)
+	})
 
-		runKubergruntWait(t, kubectlOptions, tillerNamespace, tillerVersion)
-		runKubergruntConfigure(t, kubectlOptions, helmHome, tillerNamespace, resourceNamespace)
+	test_structure.RunTestStage(t, "validate_tiller_mtls", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		validateTillerMTLS(t, k8sTillerTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "validate_client_certs", func() {
+		uniqueID := test_structure.LoadString(t, workingDir, "uniqueID")
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		validateClientCertGrant(t, k8sTillerTerratestOptions, uniqueID)
 	})
 
 	test_structure.RunTestStage(t, "validate", func() {
 		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
 		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
 		resourceNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "resource_namespace")
-		kubectlOptions := k8s.NewKubectlOptions("", "", resourceNamespace)
+		kubectlOptions := getTestCluster(t).KubectlOptions(resourceNamespace)
+
+		validateLocalChartInstalls(t, kubectlOptions, helmHome)
+	})
 
-		runHelm(
+	test_structure.RunTestStage(t, "validate_release_history", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+		resourceNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "resource_namespace")
+
+		validateReleaseHistoryMax(
 			t,
-			kubectlOptions,
+			getTestCluster(t).KubectlOptions(resourceNamespace),
+			getTestCluster(t).KubectlOptions(tillerNamespace),
 			helmHome,
-			"install",
-			"stable/kubernetes-dashboard",
-			"--wait",
+			tillerHistoryMax,
 		)
 	})
 }
 
-func runKubergruntConfigure(
+// validateTillerDeployment verifies that the Tiller Deployment deployed by the root module follows the hardened
+// configuration of the k8s-tiller module: Secret storage, TLS verification, localhost listening, and read only mounts.
+func validateTillerDeployment(t *testing.T, k8sTillerTerratestOptions *terraform.Options) {
+	tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+	rootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+
+	tiller.CheckDeploymentConformance(
+		t,
+		getTestCluster(t).KubectlOptions(tillerNamespace),
+		tiller.DefaultDeploymentName,
+		tiller.ConformanceExpectations{
+			ServiceAccountName: rootModuleVars.ServiceAccountName,
+			ListenLocalhost:    true,
+			TLSSecretName:      fmt.Sprintf("%s-namespace-tiller-certs", tillerNamespace),
+		},
+	)
+}
+
+// getTillerCAAndClientKeyPairs returns the Tiller CA and the helm client TLS certificate key pairs that the root module
+// generated for the helm client RBAC user.
+func getTillerCAAndClientKeyPairs(
 	t *testing.T,
-	options *k8s.KubectlOptions,
-	helmHome string,
-	tillerNamespace string,
-	resourceNamespace string,
-) {
-	kubergruntArgs := []string{
-		"helm",
-		"configure",
-		"--helm-home", helmHome,
-		"--tiller-namespace", tillerNamespace,
-		"--resource-namespace", resourceNamespace,
-		"--rbac-user", "minikube",
+	k8sTillerTerratestOptions *terraform.Options,
+) (*tlscerts.KeyPair, *tlscerts.KeyPair) {
+	tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+	rootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+
+	caKeyPair := tlscerts.GetKeyPair(
+		t,
+		getTestCluster(t).KubectlOptions("kube-system"),
+		fmt.Sprintf("%s-namespace-tiller-ca-certs", tillerNamespace),
+		tlscerts.DefaultCAFilenameBase,
+		"",
+	)
+	clientKeyPair := tlscerts.GetKeyPair(
+		t,
+		getTestCluster(t).KubectlOptions(tillerNamespace),
+		helmhome.ClientCertSecretName(rootModuleVars.GrantHelmClientRBACUser),
+		tlscerts.DefaultClientFilenameBase,
+		tlscerts.DefaultCAFilenameBase,
+	)
+	return caKeyPair, clientKeyPair
+}
+
+// The defaults of the TLS input variables of the root module and the TLS cert modules it calls.
+const (
+	defaultTLSValidityPeriodHours = 87660
+	defaultPrivateKeyAlgorithm    = "ECDSA"
+	defaultPrivateKeyECDSACurve   = "P256"
+	defaultPrivateKeyRSABits      = 2048
+)
+
+// tillerTLSExpectations returns the expectations for the CA, Tiller server, and helm client certificates that the root
+// module generates for the given input variables, using the module defaults for the variables that are not set.
+func tillerTLSExpectations(
+	rootModuleVars tfvars.RootModuleVars,
+) (tlscerts.Expectations, tlscerts.Expectations, tlscerts.Expectations) {
+	keyExpectations := tlscerts.Expectations{
+		ValidityPeriodHours:  defaultTLSValidityPeriodHours,
+		PrivateKeyAlgorithm:  rootModuleVars.PrivateKeyAlgorithm,
+		PrivateKeyECDSACurve: rootModuleVars.PrivateKeyECDSACurve,
 	}
-	if options.ContextName != "" {
-		kubergruntArgs = append(kubergruntArgs, "--kubectl-context-name", options.ContextName)
+	if keyExpectations.PrivateKeyAlgorithm == "" {
+		keyExpectations.PrivateKeyAlgorithm = defaultPrivateKeyAlgorithm
 	}
-	if options.ConfigPath != "" {
-		kubergruntArgs = append(kubergruntArgs, "--kubeconfig", options.ConfigPath)
+	if keyExpectations.PrivateKeyAlgorithm == "RSA" {
+		keyExpectations.PrivateKeyECDSACurve = ""
+		keyExpectations.PrivateKeyRSABits = rootModuleVars.PrivateKeyRSABits
+		if keyExpectations.PrivateKeyRSABits == 0 {
+			keyExpectations.PrivateKeyRSABits = defaultPrivateKeyRSABits
+		}
+	} else if keyExpectations.PrivateKeyECDSACurve == "" {
+		keyExpectations.PrivateKeyECDSACurve = defaultPrivateKeyECDSACurve
 	}
 
-	cmd := shell.Command{
-		Command: "kubergrunt",
-		Args:    kubergruntArgs,
+	tlsSubject := rootModuleVars.TLSSubject.ToMap()
+
+	// The CA uses the same subject as the Tiller server, with CA appended to the common name.
+	caExpectations := keyExpectations
+	caExpectations.Subject = map[string]string{}
+	for key, value := range tlsSubject {
+		caExpectations.Subject[key] = value
+	}
+	caExpectations.Subject["common_name"] = tlsSubject["common_name"] + " CA"
+	caExpectations.AllowedUses = []string{
+		"cert_signing",
+		"key_encipherment",
+		"digital_signature",
+		"server_auth",
+		"client_auth",
 	}
-	shell.RunCommand(t, cmd)
+	caExpectations.IsCA = true
+
+	// Tiller listens on localhost, so both the server and client certs are issued for 127.0.0.1.
+	serverExpectations := keyExpectations
+	serverExpectations.Subject = tlsSubject
+	serverExpectations.AllowedUses = []string{"key_encipherment", "digital_signature", "server_auth"}
+	serverExpectations.IPAddresses = []string{"127.0.0.1"}
+
+	clientExpectations := keyExpectations
+	clientExpectations.Subject = rootModuleVars.ClientTLSSubject.ToMap()
+	clientExpectations.AllowedUses = []string{"key_encipherment", "digital_signature", "client_auth"}
+	clientExpectations.IPAddresses = []string{"127.0.0.1"}
+
+	return caExpectations, serverExpectations, clientExpectations
 }
 
-func runKubergruntWait(
-	t *testing.T,
-	options *k8s.KubectlOptions,
-	tillerNamespace string,
-	tillerVersion string,
-) {
-	kubergruntArgs := []string{
-		"helm",
-		"wait-for-tiller",
-		"--tiller-namespace", tillerNamespace,
-		"--expected-tiller-version", tillerVersion,
-	}
-	if options.ContextName != "" {
-		kubergruntArgs = append(kubergruntArgs, "--kubectl-context-name", options.ContextName)
+// validateTillerTLSCerts verifies that the CA, Tiller server, and helm client certificates generated by the root module
+// chain together and match the inputs.
+func validateTillerTLSCerts(t *testing.T, k8sTillerTerratestOptions *terraform.Options) {
+	tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+	rootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+	caExpectations, serverExpectations, clientExpectations := tillerTLSExpectations(rootModuleVars)
+
+	caKeyPair, clientKeyPair := getTillerCAAndClientKeyPairs(t, k8sTillerTerratestOptions)
+	serverKeyPair := tlscerts.GetKeyPair(
+		t,
+		getTestCluster(t).KubectlOptions(tillerNamespace),
+		fmt.Sprintf("%s-namespace-tiller-certs", tillerNamespace),
+		tlscerts.DefaultServerFilenameBase,
+		tlscerts.DefaultCAFilenameBase,
+	)
+
+	tlscerts.VerifyKeyPair(t, caKeyPair, caExpectations)
+	tlscerts.VerifyKeyPair(t, serverKeyPair, serverExpectations)
+	tlscerts.VerifyChain(t, caKeyPair, serverKeyPair)
+	tlscerts.VerifyKeyPair(t, clientKeyPair, clientExpectations)
+	tlscerts.VerifyChain(t, caKeyPair, clientKeyPair)
+}
+
+// validateTillerMTLS calls the Tiller gRPC API directly to verify that Tiller serves the expected version to a client
+// authenticating with the generated client certificate, and rejects clients that don't present a valid certificate
+// signed by the Tiller CA.
+func validateTillerMTLS(t *testing.T, k8sTillerTerratestOptions *terraform.Options) {
+	tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+	rootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+	tillerVersion := rootModuleVars.TillerVersion
+	rbacUser := rootModuleVars.GrantHelmClientRBACUser
+	tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+	caKeyPair, clientKeyPair := getTillerCAAndClientKeyPairs(t, k8sTillerTerratestOptions)
+
+	now := time.Now()
+	clientCertOptions := tlscerts.CertificateOptions{
+		Subject:     pkix.Name{CommonName: rbacUser},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(time.Hour),
+		KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
-	if options.ConfigPath != "" {
-		kubergruntArgs = append(kubergruntArgs, "--kubeconfig", options.ConfigPath)
+	otherCAKeyPair, err := tlscerts.GenerateKeyPairE(
+		"other-ca",
+		tlscerts.CertificateOptions{
+			Subject:   pkix.Name{CommonName: "other CA"},
+			NotBefore: now.Add(-time.Hour),
+			NotAfter:  now.Add(time.Hour),
+			IsCA:      true,
+			KeyUsage:  x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		},
+		nil,
+	)
+	require.NoError(t, err)
+	otherCAClientKeyPair, err := tlscerts.GenerateKeyPairE("other-ca-client", clientCertOptions, otherCAKeyPair)
+	require.NoError(t, err)
+	// The expired certificate is signed by the real Tiller CA, so that the only thing wrong with it is the validity.
+	expiredCertOptions := clientCertOptions
+	expiredCertOptions.NotBefore = now.Add(-2 * time.Hour)
+	expiredCertOptions.NotAfter = now.Add(-time.Hour)
+	expiredClientKeyPair, err := tlscerts.GenerateKeyPairE("expired-client", expiredCertOptions, caKeyPair)
+	require.NoError(t, err)
+
+	pods := k8s.ListPods(t, tillerKubectlOptions, metav1.ListOptions{
+		LabelSelector: tiller.TillerPodLabelSelector(tiller.DefaultDeploymentName),
+	})
+	require.NotEmpty(t, pods)
+	for _, pod := range pods {
+		version := tiller.GetTillerVersion(
+			t,
+			tillerKubectlOptions,
+			pod.Name,
+			tiller.NewClientTLSConfig(clientKeyPair, caKeyPair.Certificate),
+			tillerVersion,
+		)
+		assert.Equal(t, tillerVersion, version)
+
+		rejectedClientKeyPairs := map[string]*tlscerts.KeyPair{
+			"no client cert":                  nil,
+			"client cert from a different CA": otherCAClientKeyPair,
+			"expired client cert":             expiredClientKeyPair,
+		}
+		for description, rejectedClientKeyPair := range rejectedClientKeyPairs {
+			_, err := tiller.GetTillerVersionE(
+				t,
+				tillerKubectlOptions,
+				pod.Name,
+				tiller.NewClientTLSConfig(rejectedClientKeyPair, caKeyPair.Certificate),
+				tillerVersion,
+			)
+			require.Error(t, err, "Tiller Pod %s accepted a connection with %s", pod.Name, description)
+			_, isTLSRejected := err.(tiller.TillerTLSRejectedError)
+			assert.True(
+				t,
+				isTLSRejected,
+				"Expected Tiller Pod %s to reject the TLS handshake with %s, got %T: %s",
+				pod.Name,
+				description,
+				err,
+				err,
+			)
+		}
 	}
+}
 
-	cmd := shell.Command{
-		Command: "kubergrunt",
-		Args:    kubergruntArgs,
+// validateClientCertGrant issues a client cert for a second RBAC entity with the clientcerts package, verifies that
+// Tiller accepts it, and then revokes the grant and verifies that the Secret holding the client cert is removed.
+func validateClientCertGrant(t *testing.T, k8sTillerTerratestOptions *terraform.Options, uniqueID string) {
+	tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+	rootModuleVars := tfvars.RootModuleVars{}
+	tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+	tillerVersion := rootModuleVars.TillerVersion
+	tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+	caKeyPair, _ := getTillerCAAndClientKeyPairs(t, k8sTillerTerratestOptions)
+
+	entity := clientcerts.RBACEntity{
+		Kind: clientcerts.UserEntity,
+		ID:   fmt.Sprintf("%s-second-user", strings.ToLower(uniqueID)),
+	}
+	grant := clientcerts.IssueClientCert(
+		t,
+		tillerKubectlOptions,
+		entity,
+		clientcerts.IssueOptions{CASecretName: fmt.Sprintf("%s-namespace-tiller-ca-certs", tillerNamespace)},
+	)
+	// Revoke the grant if the test fails before it gets to the revocation, so that the Secret is not left behind.
+	revoked := false
+	defer func() {
+		if !revoked {
+			clientcerts.RevokeGrant(t, tillerKubectlOptions, entity)
+		}
+	}()
+	assert.Contains(t, clientcerts.ListGrants(t, tillerKubectlOptions), *grant)
+
+	clientKeyPair := tlscerts.GetKeyPair(
+		t,
+		tillerKubectlOptions,
+		grant.SecretName,
+		tlscerts.DefaultClientFilenameBase,
+		tlscerts.DefaultCAFilenameBase,
+	)
+	pods := tiller.ListTillerPods(t, tillerKubectlOptions, tiller.DefaultDeploymentName)
+	require.NotEmpty(t, pods)
+	for _, pod := range pods {
+		version := tiller.GetTillerVersion(
+			t,
+			tillerKubectlOptions,
+			pod.Name,
+			tiller.NewClientTLSConfig(clientKeyPair, caKeyPair.Certificate),
+			tillerVersion,
+		)
+		assert.Equal(t, tillerVersion, version)
 	}
-	shell.RunCommand(t, cmd)
+
+	clientcerts.RevokeGrant(t, tillerKubectlOptions, entity)
+	revoked = true
+	_, err := k8s.GetSecretE(t, tillerKubectlOptions, grant.SecretName)
+	assert.True(t, errors.IsNotFound(err), "Expected Secret %s to be deleted, got %v", grant.SecretName, err)
 }
 
 func kubergruntInstalled(t *testing.T) bool {
@@ -0,0 +1,230 @@
+package test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helmhome"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/releases"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tiller"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// The helm client only talks to a Tiller of the same minor version or newer, so the upgrade stays within the minor
+	// version of the other Tiller tests.
+	tillerUpgradeFromVersion = "v2.12.0"
+	tillerUpgradeToVersion   = "v2.12.3"
+
+	// The root module deploys a single Tiller replica, which the rolling update must keep available at all times.
+	tillerUpgradeMinAvailable = 1
+)
+
+// This test deploys the root module with an older Tiller version, installs a release, and then upgrades Tiller by
+// reapplying Terraform with a newer tiller_version. It verifies that Tiller stays available while the Deployment rolls,
+// and that the release and its history in the Secret storage survive the upgrade.
+func TestK8STillerUpgrade(t *testing.T) {
+	t.Parallel()
+
+	// Uncomment any of the following to skip that section during the test
+	// os.Setenv("SKIP_create_test_copy_of_examples", "true")
+	// os.Setenv("SKIP_create_terratest_options", "true")
+	// os.Setenv("SKIP_terraform_apply", "true")
+	// os.Setenv("SKIP_setup_helm_client", "true")
+	// os.Setenv("SKIP_install_release", "true")
+	// os.Setenv("SKIP_upgrade_tiller", "true")
+	// os.Setenv("SKIP_validate_release_kept", "true")
+	// os.Setenv("SKIP_cleanup", "true")
+
+	// Create a directory path that won't conflict
+	workingDir := filepath.Join(".", "stages", t.Name())
+
+	// Check for resources that the test left behind in the cluster, once the cleanup stage is done.
+	leakSnapshot := snapshotClusterResources(t)
+	defer checkForLeakedResourcesOfStages(t, leakSnapshot, workingDir)
+
+	test_structure.RunTestStage(t, "create_test_copy_of_examples", func() {
+		uniqueID := random.UniqueId()
+		k8sTillerTerraformModulePath := test_structure.CopyTerraformFolderToTemp(t, "..", ".")
+		logger.Logf(t, "path to test folder %s\n", k8sTillerTerraformModulePath)
+		helmHome := filepath.Join(k8sTillerTerraformModulePath, ".helm")
+		// make sure to create the helm home directory
+		require.NoError(t, os.Mkdir(helmHome, 0700))
+
+		test_structure.SaveString(t, workingDir, "k8sTillerTerraformModulePath", k8sTillerTerraformModulePath)
+		test_structure.SaveString(t, workingDir, "helmHome", helmHome)
+		test_structure.SaveString(t, workingDir, "uniqueID", uniqueID)
+	})
+
+	test_structure.RunTestStage(t, "create_terratest_options", func() {
+		uniqueID := test_structure.LoadString(t, workingDir, "uniqueID")
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
+
+		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(
+			t,
+			k8sTillerTerraformModulePath,
+			getTestCluster(t),
+			helmHome,
+			uniqueID,
+			func(vars *tfvars.RootModuleVars) { vars.TillerVersion = tillerUpgradeFromVersion },
+		)
+
+		test_structure.SaveTerraformOptions(t, workingDir, k8sTillerTerratestOptions)
+	})
+
+	defer test_structure.RunTestStage(t, "cleanup", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		// The release is only there if the install_release stage ran.
+		if test_structure.IsTestDataPresent(t, test_structure.FormatTestDataPath(workingDir, "releaseName.json")) {
+			helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+			releaseName := test_structure.LoadString(t, workingDir, "releaseName")
+			deleteHelmRelease(t, getTestCluster(t).KubectlOptions(""), helmHome, releaseName)
+		}
+		terraform.Destroy(t, k8sTillerTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "terraform_apply", func() {
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		terraform.InitAndApply(t, k8sTillerTerratestOptions)
+	})
+
+	test_structure.RunTestStage(t, "setup_helm_client", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+		rootModuleVars := tfvars.RootModuleVars{}
+		tfvars.FromVars(t, k8sTillerTerratestOptions.Vars, &rootModuleVars)
+		rbacUser := rootModuleVars.GrantHelmClientRBACUser
+
+		// Wait for up to 5 minutes for Tiller to come up (60 tries, 5 seconds inbetween each trial)
+		tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+		tiller.WaitForTiller(t, tillerKubectlOptions, tiller.DefaultDeploymentName, tillerUpgradeFromVersion, 60, 5*time.Second)
+		helmhome.ConfigureHelmHome(t, tillerKubectlOptions, helmHome, rbacUser)
+	})
+
+	test_structure.RunTestStage(t, "install_release", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+		resourceNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "resource_namespace")
+
+		packageDir, err := ioutil.TempDir("", "helm-test-charts")
+		require.NoError(t, err)
+		defer os.RemoveAll(packageDir)
+
+		releaseName := localChartReleaseName(deploymentChart)
+		// Save the release name first, so that the cleanup stage deletes the release even if the install fails midway.
+		test_structure.SaveString(t, workingDir, "releaseName", releaseName)
+		installLocalChart(
+			t,
+			getTestCluster(t).KubectlOptions(resourceNamespace),
+			helmHome,
+			packageDir,
+			deploymentChart,
+			releaseName,
+			"--wait",
+		)
+
+		records := getReleaseRecords(t, getTestCluster(t).KubectlOptions(tillerNamespace), releaseName)
+		require.NotEmpty(t, records)
+		test_structure.SaveTestData(t, test_structure.FormatTestDataPath(workingDir, "releaseRecords.json"), records)
+	})
+
+	test_structure.RunTestStage(t, "upgrade_tiller", func() {
+		uniqueID := test_structure.LoadString(t, workingDir, "uniqueID")
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		k8sTillerTerraformModulePath := test_structure.LoadString(t, workingDir, "k8sTillerTerraformModulePath")
+		k8sTillerTerratestOptions := createExampleK8STillerTerraformOptions(
+			t,
+			k8sTillerTerraformModulePath,
+			getTestCluster(t),
+			helmHome,
+			uniqueID,
+			func(vars *tfvars.RootModuleVars) { vars.TillerVersion = tillerUpgradeToVersion },
+		)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+		tillerKubectlOptions := getTestCluster(t).KubectlOptions(tillerNamespace)
+
+		// Save the new version before applying, so that the stages can be rerun against the upgraded Tiller.
+		test_structure.SaveTerraformOptions(t, workingDir, k8sTillerTerratestOptions)
+
+		// Tiller only counts as available if it answers the helm client over mTLS. The client version is compatible with
+		// both the old and the new Tiller, as they have the same minor version.
+		caKeyPair, clientKeyPair := getTillerCAAndClientKeyPairs(t, k8sTillerTerratestOptions)
+		monitor := tiller.StartAvailabilityMonitor(
+			t,
+			tillerKubectlOptions,
+			tiller.DefaultDeploymentName,
+			tiller.NewClientTLSConfig(clientKeyPair, caKeyPair.Certificate),
+			tillerUpgradeFromVersion,
+			time.Second,
+		)
+		// The monitor must be stopped before failing the test, so that it doesn't keep polling in the background.
+		_, err := terraform.ApplyE(t, k8sTillerTerratestOptions)
+		if err == nil {
+			// Wait for up to 5 minutes for the new version to roll out (60 tries, 5 seconds inbetween each trial)
+			err = tiller.WaitForTillerE(
+				t,
+				tillerKubectlOptions,
+				tiller.DefaultDeploymentName,
+				tillerUpgradeToVersion,
+				60,
+				5*time.Second,
+			)
+		}
+		samples := monitor.Stop()
+		require.NoError(t, err)
+
+		tiller.CheckNoDowntime(t, tiller.DefaultDeploymentName, samples, tillerUpgradeMinAvailable)
+	})
+
+	test_structure.RunTestStage(t, "validate_release_kept", func() {
+		helmHome := test_structure.LoadString(t, workingDir, "helmHome")
+		releaseName := test_structure.LoadString(t, workingDir, "releaseName")
+		recordsBeforeUpgrade := []releases.Record{}
+		test_structure.LoadTestData(
+			t,
+			test_structure.FormatTestDataPath(workingDir, "releaseRecords.json"),
+			&recordsBeforeUpgrade,
+		)
+		k8sTillerTerratestOptions := test_structure.LoadTerraformOptions(t, workingDir)
+		tillerNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "tiller_namespace")
+		resourceNamespace := terraform.OutputRequired(t, k8sTillerTerratestOptions, "resource_namespace")
+
+		// The release records are Secrets in the Tiller namespace, which the upgrade must not touch. Compare the decoded
+		// records, so that a record that was rewritten with a different version, status, or manifest is caught too.
+		recordsAfterUpgrade := getReleaseRecords(t, getTestCluster(t).KubectlOptions(tillerNamespace), releaseName)
+		assert.Equal(t, recordsBeforeUpgrade, recordsAfterUpgrade)
+
+		// The upgraded Tiller must read the existing records, through the same helm home as before the upgrade.
+		resourceKubectlOptions := getTestCluster(t).KubectlOptions(resourceNamespace)
+		listResult := helmhome.RunHelm(t, resourceKubectlOptions, helmHome, "list", "--short")
+		assert.Contains(t, strings.Fields(listResult.Stdout), releaseName)
+		historyResult := helmhome.RunHelm(t, resourceKubectlOptions, helmHome, "history", releaseName)
+		assert.Contains(t, historyResult.Stdout, "DEPLOYED")
+	})
+}
+
+// getReleaseRecords returns the decoded records of the revisions of the given release, which Tiller stores as Secrets
+// in the namespace of the kubectl options when it runs with --storage=secret. The records are sorted by version.
+func getReleaseRecords(t *testing.T, options *k8s.KubectlOptions, releaseName string) []releases.Record {
+	records := []releases.Record{}
+	for _, record := range releases.ListRecords(t, options) {
+		if record.Name == releaseName {
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,79 @@
+package kubeconfig
+
+import (
+	"fmt"
+)
+
+// MissingOptionError is returned when one of the required options to generate a kubeconfig is not set.
+type MissingOptionError struct {
+	Options Options
+}
+
+func (err MissingOptionError) Error() string {
+	return fmt.Sprintf(
+		"The ServiceAccount name (%q), namespace (%q), and server (%q) are required to generate a kubeconfig",
+		err.Options.ServiceAccountName,
+		err.Options.Namespace,
+		err.Options.Server,
+	)
+}
+
+// UnknownTokenSourceError is returned when the token source is not one of the known token sources.
+type UnknownTokenSourceError struct {
+	TokenSource TokenSource
+}
+
+func (err UnknownTokenSourceError) Error() string {
+	return fmt.Sprintf(
+		"Unknown token source %q, expected one of %s, %s, or %s",
+		err.TokenSource,
+		TokenSourceAuto,
+		TokenSourceSecret,
+		TokenSourceTokenRequest,
+	)
+}
+
+// TokenSecretNotFoundError is returned when the ServiceAccount has no token Secret.
+type TokenSecretNotFoundError struct {
+	Namespace string
+	Name      string
+}
+
+func (err TokenSecretNotFoundError) Error() string {
+	return fmt.Sprintf(
+		"ServiceAccount %s in namespace %s has no token Secret. "+
+			"Use the %s token source on clusters that don't create token Secrets.",
+		err.Name,
+		err.Namespace,
+		TokenSourceTokenRequest,
+	)
+}
+
+// NotServiceAccountTokenSecretError is returned when the token Secret is not a token Secret of the ServiceAccount.
+type NotServiceAccountTokenSecretError struct {
+	Namespace          string
+	SecretName         string
+	ServiceAccountName string
+}
+
+func (err NotServiceAccountTokenSecretError) Error() string {
+	return fmt.Sprintf(
+		"Secret %s in namespace %s is not a token Secret of ServiceAccount %s",
+		err.SecretName,
+		err.Namespace,
+		err.ServiceAccountName,
+	)
+}
+
+// ClusterCANotFoundError is returned when the CA cert of the cluster could not be found.
+type ClusterCANotFoundError struct {
+	Namespace string
+}
+
+func (err ClusterCANotFoundError) Error() string {
+	return fmt.Sprintf(
+		"Found no cluster CA cert in the %s ConfigMap in namespace %s. Pass the CA cert of the cluster explicitly.",
+		RootCAConfigMapName,
+		err.Namespace,
+	)
+}
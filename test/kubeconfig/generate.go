@@ -0,0 +1,291 @@
+package kubeconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// TokenSource is where the token of the ServiceAccount comes from.
+type TokenSource string
+
+const (
+	// TokenSourceAuto uses the token Secret of the ServiceAccount if it has one, and a bound token from the
+	// TokenRequest API otherwise. Clusters on Kubernetes 1.24 and newer no longer create token Secrets for
+	// ServiceAccounts, and the token_secret_name output of the k8s-service-account module is empty on them.
+	TokenSourceAuto TokenSource = "auto"
+
+	// TokenSourceSecret uses the long lived token in the token Secret of the ServiceAccount.
+	TokenSourceSecret TokenSource = "secret"
+
+	// TokenSourceTokenRequest requests a bound token that expires through the TokenRequest API.
+	TokenSourceTokenRequest TokenSource = "token-request"
+)
+
+const (
+	// ServiceAccountNameAnnotation is the annotation on a token Secret with the name of the ServiceAccount it belongs
+	// to.
+	ServiceAccountNameAnnotation = "kubernetes.io/service-account.name"
+
+	// RootCAConfigMapName is the name of the ConfigMap that newer clusters publish the cluster CA in, in every
+	// namespace.
+	RootCAConfigMapName = "kube-root-ca.crt"
+
+	// DefaultClusterName is the name of the cluster in the generated kubeconfig when Options.ClusterName is not set.
+	DefaultClusterName = "kubernetes"
+
+	// DefaultTokenExpiration is the lifetime of the tokens from the TokenRequest API when Options.TokenExpiration is not
+	// set.
+	DefaultTokenExpiration = time.Hour
+
+	// The keys of the token Secret and the root CA ConfigMap.
+	tokenKey = "token"
+	caKey    = "ca.crt"
+)
+
+// Options are the options for generating a kubeconfig for a ServiceAccount.
+type Options struct {
+	// The name and namespace of the ServiceAccount. Required.
+	ServiceAccountName string
+	Namespace          string
+
+	// Server is the URL of the Kubernetes API server that the kubeconfig points to. Required.
+	Server string
+
+	// ClusterName is the name of the cluster in the kubeconfig. Defaults to DefaultClusterName.
+	ClusterName string
+
+	// ContextName is the name of the context and user in the kubeconfig. Defaults to the name of the ServiceAccount.
+	ContextName string
+
+	// TokenSource is where the token comes from. Defaults to TokenSourceAuto.
+	TokenSource TokenSource
+
+	// TokenSecretName is the name of the token Secret to use, e.g. the token_secret_name output of the
+	// k8s-service-account module. Defaults to the first token Secret of the ServiceAccount.
+	TokenSecretName string
+
+	// TokenExpiration is the requested lifetime of tokens from the TokenRequest API. The API server may issue a token
+	// with a shorter lifetime. Defaults to DefaultTokenExpiration.
+	TokenExpiration time.Duration
+
+	// CAData is the PEM encoded CA cert of the cluster. Defaults to the ca.crt of the token Secret, or to the
+	// kube-root-ca.crt ConfigMap in the namespace of the ServiceAccount for tokens from the TokenRequest API.
+	CAData []byte
+}
+
+// GenerateForServiceAccount generates a standalone kubeconfig that authenticates as the ServiceAccount, using the
+// cluster of the provided KubectlOptions to look up the token. This will fail the test if there is an error.
+func GenerateForServiceAccount(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	serviceAccountOptions Options,
+) *clientcmdapi.Config {
+	config, err := GenerateForServiceAccountE(t, options, serviceAccountOptions)
+	require.NoError(t, err)
+	return config
+}
+
+// GenerateForServiceAccountE generates a standalone kubeconfig that authenticates as the ServiceAccount, using the
+// cluster of the provided KubectlOptions to look up the token.
+func GenerateForServiceAccountE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	serviceAccountOptions Options,
+) (*clientcmdapi.Config, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return GenerateForServiceAccountFromClientE(clientset, serviceAccountOptions)
+}
+
+// WriteForServiceAccount generates a standalone kubeconfig that authenticates as the ServiceAccount, and writes it to
+// destPath. Returns the name of the context in the kubeconfig. This will fail the test if there is an error.
+func WriteForServiceAccount(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	serviceAccountOptions Options,
+	destPath string,
+) string {
+	config := GenerateForServiceAccount(t, options, serviceAccountOptions)
+	require.NoError(t, clientcmd.WriteToFile(*config, destPath))
+	return config.CurrentContext
+}
+
+// GenerateForServiceAccountFromClientE generates a standalone kubeconfig that authenticates as the ServiceAccount,
+// using the given client to look up the token. The kubeconfig has a single cluster, user, and context, with the token
+// and CA cert embedded, so it doesn't depend on any files or on the kubeconfig of the client. The namespace of the
+// context is the namespace of the ServiceAccount.
+func GenerateForServiceAccountFromClientE(
+	clientset kubernetes.Interface,
+	options Options,
+) (*clientcmdapi.Config, error) {
+	if options.ServiceAccountName == "" || options.Namespace == "" || options.Server == "" {
+		return nil, MissingOptionError{Options: options}
+	}
+
+	token, caData, err := getTokenE(clientset, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.CAData) > 0 {
+		caData = options.CAData
+	}
+	if len(caData) == 0 {
+		caData, err = getRootCAE(clientset, options.Namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clusterName := options.ClusterName
+	if clusterName == "" {
+		clusterName = DefaultClusterName
+	}
+	contextName := options.ContextName
+	if contextName == "" {
+		contextName = options.ServiceAccountName
+	}
+
+	config := clientcmdapi.NewConfig()
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = options.Server
+	cluster.CertificateAuthorityData = caData
+	config.Clusters[clusterName] = cluster
+	authInfo := clientcmdapi.NewAuthInfo()
+	authInfo.Token = token
+	config.AuthInfos[contextName] = authInfo
+	context := clientcmdapi.NewContext()
+	context.Cluster = clusterName
+	context.AuthInfo = contextName
+	context.Namespace = options.Namespace
+	config.Contexts[contextName] = context
+	config.CurrentContext = contextName
+	return config, nil
+}
+
+// getTokenE returns the token of the ServiceAccount from the configured source, along with the CA cert from the token
+// Secret if the token came from one.
+func getTokenE(clientset kubernetes.Interface, options Options) (string, []byte, error) {
+	serviceAccount, err := clientset.CoreV1().ServiceAccounts(options.Namespace).Get(
+		options.ServiceAccountName,
+		metav1.GetOptions{},
+	)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch options.TokenSource {
+	case TokenSourceAuto, "":
+		if options.TokenSecretName != "" || len(serviceAccount.Secrets) > 0 {
+			return getSecretTokenE(clientset, serviceAccount, options.TokenSecretName)
+		}
+		token, err := requestTokenE(clientset, serviceAccount, options.TokenExpiration)
+		return token, nil, err
+	case TokenSourceSecret:
+		return getSecretTokenE(clientset, serviceAccount, options.TokenSecretName)
+	case TokenSourceTokenRequest:
+		token, err := requestTokenE(clientset, serviceAccount, options.TokenExpiration)
+		return token, nil, err
+	}
+	return "", nil, UnknownTokenSourceError{TokenSource: options.TokenSource}
+}
+
+// getSecretTokenE returns the token and CA cert from the named token Secret of the ServiceAccount, or from the first
+// token Secret of the ServiceAccount if no name is given.
+func getSecretTokenE(
+	clientset kubernetes.Interface,
+	serviceAccount *corev1.ServiceAccount,
+	secretName string,
+) (string, []byte, error) {
+	secrets := clientset.CoreV1().Secrets(serviceAccount.Namespace)
+	if secretName != "" {
+		secret, err := secrets.Get(secretName, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		return tokenFromSecretE(serviceAccount, secret)
+	}
+
+	// The ServiceAccount may also list image pull Secrets or other mountable Secrets, so look for a token Secret.
+	for _, reference := range serviceAccount.Secrets {
+		secret, err := secrets.Get(reference.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, err
+		}
+		if secret.Type == corev1.SecretTypeServiceAccountToken {
+			return tokenFromSecretE(serviceAccount, secret)
+		}
+	}
+	return "", nil, TokenSecretNotFoundError{Namespace: serviceAccount.Namespace, Name: serviceAccount.Name}
+}
+
+// tokenFromSecretE returns the token and CA cert of the token Secret, after checking that it belongs to the
+// ServiceAccount.
+func tokenFromSecretE(serviceAccount *corev1.ServiceAccount, secret *corev1.Secret) (string, []byte, error) {
+	if secret.Type != corev1.SecretTypeServiceAccountToken ||
+		secret.Annotations[ServiceAccountNameAnnotation] != serviceAccount.Name {
+		return "", nil, NotServiceAccountTokenSecretError{
+			Namespace:          secret.Namespace,
+			SecretName:         secret.Name,
+			ServiceAccountName: serviceAccount.Name,
+		}
+	}
+	// The token controller fills in the token asynchronously after the Secret is created.
+	token := secret.Data[tokenKey]
+	if len(token) == 0 {
+		return "", nil, k8s.ServiceAccountTokenNotAvailable{Name: serviceAccount.Name}
+	}
+	return string(token), secret.Data[caKey], nil
+}
+
+// requestTokenE requests a bound token for the ServiceAccount through the TokenRequest API.
+func requestTokenE(
+	clientset kubernetes.Interface,
+	serviceAccount *corev1.ServiceAccount,
+	expiration time.Duration,
+) (string, error) {
+	if expiration == 0 {
+		expiration = DefaultTokenExpiration
+	}
+	expirationSeconds := int64(expiration.Seconds())
+	tokenRequest, err := clientset.CoreV1().ServiceAccounts(serviceAccount.Namespace).CreateToken(
+		serviceAccount.Name,
+		&authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expirationSeconds},
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if tokenRequest.Status.Token == "" {
+		return "", k8s.ServiceAccountTokenNotAvailable{Name: serviceAccount.Name}
+	}
+	return tokenRequest.Status.Token, nil
+}
+
+// getRootCAE returns the cluster CA cert from the root CA ConfigMap in the given namespace.
+func getRootCAE(clientset kubernetes.Interface, namespace string) ([]byte, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(RootCAConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, ClusterCANotFoundError{Namespace: namespace}
+	}
+	if err != nil {
+		return nil, err
+	}
+	caData := configMap.Data[caKey]
+	if caData == "" {
+		return nil, ClusterCANotFoundError{Namespace: namespace}
+	}
+	return []byte(caData), nil
+}
@@ -0,0 +1,249 @@
+package kubeconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNamespace          = "resources"
+	testServiceAccountName = "deployer"
+	testServer             = "https://127.0.0.1:6443"
+	testSecretCA           = "secret ca"
+	testRootCA             = "root ca"
+)
+
+func TestGenerateForServiceAccountFromSecret(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(
+		newServiceAccount("deployer-dockercfg", "deployer-token-abcde"),
+		newSecret("deployer-dockercfg", corev1.SecretTypeDockercfg, "", nil),
+		newSecret("deployer-token-abcde", corev1.SecretTypeServiceAccountToken, testServiceAccountName, []byte("token")),
+		newRootCAConfigMap(),
+	)
+
+	for _, tokenSource := range []TokenSource{"", TokenSourceAuto, TokenSourceSecret} {
+		config, err := GenerateForServiceAccountFromClientE(
+			clientset,
+			Options{
+				ServiceAccountName: testServiceAccountName,
+				Namespace:          testNamespace,
+				Server:             testServer,
+				TokenSource:        tokenSource,
+			},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, testServiceAccountName, config.CurrentContext)
+		require.Equal(t, 1, len(config.Contexts))
+		require.Equal(t, 1, len(config.AuthInfos))
+		require.Equal(t, 1, len(config.Clusters))
+		context := config.Contexts[testServiceAccountName]
+		require.NotNil(t, context)
+		assert.Equal(t, DefaultClusterName, context.Cluster)
+		assert.Equal(t, testServiceAccountName, context.AuthInfo)
+		assert.Equal(t, testNamespace, context.Namespace)
+		assert.Equal(t, "token", config.AuthInfos[testServiceAccountName].Token)
+		cluster := config.Clusters[DefaultClusterName]
+		assert.Equal(t, testServer, cluster.Server)
+		// The CA cert in the token Secret takes precedence over the root CA ConfigMap.
+		assert.Equal(t, []byte(testSecretCA), cluster.CertificateAuthorityData)
+	}
+}
+
+func TestGenerateForServiceAccountFromNamedSecret(t *testing.T) {
+	t.Parallel()
+
+	// The token Secret created by the k8s-service-account module is not listed on the ServiceAccount until the token
+	// controller catches up.
+	clientset := fake.NewSimpleClientset(
+		newServiceAccount(),
+		newSecret("deployer-token", corev1.SecretTypeServiceAccountToken, testServiceAccountName, []byte("token")),
+	)
+	config, err := GenerateForServiceAccountFromClientE(
+		clientset,
+		Options{
+			ServiceAccountName: testServiceAccountName,
+			Namespace:          testNamespace,
+			Server:             testServer,
+			ClusterName:        "kind-test",
+			ContextName:        "ci",
+			TokenSecretName:    "deployer-token",
+			CAData:             []byte("override ca"),
+		},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "ci", config.CurrentContext)
+	context := config.Contexts["ci"]
+	require.NotNil(t, context)
+	assert.Equal(t, "kind-test", context.Cluster)
+	assert.Equal(t, "ci", context.AuthInfo)
+	assert.Equal(t, "token", config.AuthInfos["ci"].Token)
+	assert.Equal(t, []byte("override ca"), config.Clusters["kind-test"].CertificateAuthorityData)
+}
+
+func TestGenerateForServiceAccountFromTokenRequest(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name               string
+		tokenSource        TokenSource
+		tokenExpiration    time.Duration
+		expectedExpiration int64
+	}{
+		// Without a token Secret, the auto token source falls back to the TokenRequest API.
+		{"auto", TokenSourceAuto, 0, 3600},
+		{"token-request", TokenSourceTokenRequest, 10 * time.Minute, 600},
+	}
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			clientset := fake.NewSimpleClientset(newServiceAccount(), newRootCAConfigMap())
+			var requestedExpiration int64
+			clientset.PrependReactor(
+				"create",
+				"serviceaccounts",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					createAction := action.(k8stesting.CreateAction)
+					if createAction.GetSubresource() != "token" {
+						return false, nil, nil
+					}
+					tokenRequest := createAction.GetObject().(*authenticationv1.TokenRequest)
+					requestedExpiration = *tokenRequest.Spec.ExpirationSeconds
+					tokenRequest.Status.Token = "bound token"
+					return true, tokenRequest, nil
+				},
+			)
+
+			config, err := GenerateForServiceAccountFromClientE(
+				clientset,
+				Options{
+					ServiceAccountName: testServiceAccountName,
+					Namespace:          testNamespace,
+					Server:             testServer,
+					TokenSource:        testCase.tokenSource,
+					TokenExpiration:    testCase.tokenExpiration,
+				},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedExpiration, requestedExpiration)
+			assert.Equal(t, "bound token", config.AuthInfos[testServiceAccountName].Token)
+			assert.Equal(t, []byte(testRootCA), config.Clusters[DefaultClusterName].CertificateAuthorityData)
+		})
+	}
+}
+
+func TestGenerateForServiceAccountErrors(t *testing.T) {
+	t.Parallel()
+
+	options := Options{ServiceAccountName: testServiceAccountName, Namespace: testNamespace, Server: testServer}
+
+	_, err := GenerateForServiceAccountFromClientE(fake.NewSimpleClientset(), Options{})
+	_, isMissingOptionErr := err.(MissingOptionError)
+	assert.True(t, isMissingOptionErr, "Expected MissingOptionError, got %T: %s", err, err)
+
+	unknownOptions := options
+	unknownOptions.TokenSource = "kubelet"
+	_, err = GenerateForServiceAccountFromClientE(fake.NewSimpleClientset(newServiceAccount()), unknownOptions)
+	_, isUnknownTokenSourceErr := err.(UnknownTokenSourceError)
+	assert.True(t, isUnknownTokenSourceErr, "Expected UnknownTokenSourceError, got %T: %s", err, err)
+
+	secretOptions := options
+	secretOptions.TokenSource = TokenSourceSecret
+	_, err = GenerateForServiceAccountFromClientE(fake.NewSimpleClientset(newServiceAccount()), secretOptions)
+	_, isTokenSecretNotFoundErr := err.(TokenSecretNotFoundError)
+	assert.True(t, isTokenSecretNotFoundErr, "Expected TokenSecretNotFoundError, got %T: %s", err, err)
+
+	// A token Secret of another ServiceAccount must not be used.
+	otherSecretOptions := options
+	otherSecretOptions.TokenSecretName = "admin-token"
+	_, err = GenerateForServiceAccountFromClientE(
+		fake.NewSimpleClientset(
+			newServiceAccount(),
+			newSecret("admin-token", corev1.SecretTypeServiceAccountToken, "admin", []byte("token")),
+		),
+		otherSecretOptions,
+	)
+	_, isNotServiceAccountTokenSecretErr := err.(NotServiceAccountTokenSecretError)
+	assert.True(
+		t,
+		isNotServiceAccountTokenSecretErr,
+		"Expected NotServiceAccountTokenSecretError, got %T: %s",
+		err,
+		err,
+	)
+
+	// The token controller has not filled in the token yet.
+	_, err = GenerateForServiceAccountFromClientE(
+		fake.NewSimpleClientset(
+			newServiceAccount("deployer-token"),
+			newSecret("deployer-token", corev1.SecretTypeServiceAccountToken, testServiceAccountName, nil),
+		),
+		options,
+	)
+	_, isTokenNotAvailableErr := err.(k8s.ServiceAccountTokenNotAvailable)
+	assert.True(t, isTokenNotAvailableErr, "Expected ServiceAccountTokenNotAvailable, got %T: %s", err, err)
+
+	// Older clusters don't publish the root CA ConfigMap, so the CA cert must be passed in for bound tokens.
+	clientset := fake.NewSimpleClientset(newServiceAccount())
+	clientset.PrependReactor(
+		"create",
+		"serviceaccounts",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			tokenRequest := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+			tokenRequest.Status.Token = "bound token"
+			return true, tokenRequest, nil
+		},
+	)
+	_, err = GenerateForServiceAccountFromClientE(clientset, options)
+	_, isClusterCANotFoundErr := err.(ClusterCANotFoundError)
+	assert.True(t, isClusterCANotFoundErr, "Expected ClusterCANotFoundError, got %T: %s", err, err)
+}
+
+func newServiceAccount(secretNames ...string) *corev1.ServiceAccount {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: testServiceAccountName},
+	}
+	for _, secretName := range secretNames {
+		serviceAccount.Secrets = append(serviceAccount.Secrets, corev1.ObjectReference{Name: secretName})
+	}
+	return serviceAccount
+}
+
+func newSecret(name string, secretType corev1.SecretType, serviceAccountName string, token []byte) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name},
+		Type:       secretType,
+		Data:       map[string][]byte{},
+	}
+	if serviceAccountName != "" {
+		secret.Annotations = map[string]string{ServiceAccountNameAnnotation: serviceAccountName}
+	}
+	if token != nil {
+		secret.Data[tokenKey] = token
+		secret.Data[caKey] = []byte(testSecretCA)
+	}
+	return secret
+}
+
+func newRootCAConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: RootCAConfigMapName},
+		Data:       map[string]string{caKey: testRootCA},
+	}
+}
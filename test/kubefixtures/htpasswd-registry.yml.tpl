@@ -0,0 +1,48 @@
+---
+# A Docker registry that only serves authenticated clients, so that pulling an image from it only works with an image
+# pull secret. It runs on the host network, because the container runtime on the node pulls images from localhost over
+# plain HTTP, but does not trust self-signed certificates for any other host.
+# The registry is a pull-through cache of Docker Hub, so that the test doesn't need to push an image to it first.
+apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .PodName }}
+  namespace: {{ .Namespace }}
+spec:
+  hostNetwork: true
+  initContainers:
+  # The registry image no longer ships the htpasswd binary, so we generate the bcrypt password file with the one from
+  # the Apache httpd image.
+  - name: htpasswd
+    image: httpd:2.4
+    command:
+    - sh
+    - -c
+    - htpasswd -Bbn {{ .RegistryUsername }} {{ .RegistryPassword }} > /auth/htpasswd
+    volumeMounts:
+    - name: auth
+      mountPath: /auth
+  containers:
+  - name: registry
+    image: registry:2
+    env:
+    - name: REGISTRY_HTTP_ADDR
+      value: "0.0.0.0:{{ .RegistryPort }}"
+    - name: REGISTRY_AUTH
+      value: htpasswd
+    - name: REGISTRY_AUTH_HTPASSWD_REALM
+      value: terratest
+    - name: REGISTRY_AUTH_HTPASSWD_PATH
+      value: /auth/htpasswd
+    - name: REGISTRY_PROXY_REMOTEURL
+      value: https://registry-1.docker.io
+    # The registry answers 401 to unauthenticated requests, so we can only check that it accepts connections.
+    readinessProbe:
+      tcpSocket:
+        port: {{ .RegistryPort }}
+    volumeMounts:
+    - name: auth
+      mountPath: /auth
+  volumes:
+  - name: auth
+    emptyDir: {}
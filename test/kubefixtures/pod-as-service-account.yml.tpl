@@ -0,0 +1,18 @@
+---
+# A Pod that runs as the service account, with no volumes or image pull secrets of its own, so that everything it gets
+# from the service account is added by the ServiceAccount admission controller.
+apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .PodName }}
+  namespace: {{ .Namespace }}
+spec:
+  serviceAccountName: {{ .ServiceAccountName }}
+  containers:
+  - name: main
+    image: {{ .Image }}
+    # Always pull the image, so that a Pod without credentials can't start from an image that another Pod on the same
+    # node already pulled with the image pull secret.
+    imagePullPolicy: Always
+    # Keep the container running so that we can check the mounted token with `kubectl exec`.
+    command: ["sleep", "9999999"]
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/leaks"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+)
+
+// Set this environment variable to delete the resources that a test leaves behind in the cluster, instead of only
+// reporting them.
+const deleteLeakedResourcesEnvVar = "DELETE_LEAKED_RESOURCES"
+
+// snapshotClusterResources takes a snapshot of the cluster resources at the start of a test, to compare against in
+// checkForLeakedResources.
+func snapshotClusterResources(t *testing.T) *leaks.Snapshot {
+	return leaks.TakeSnapshot(t, getTestCluster(t).KubectlOptions(""))
+}
+
+// checkForLeakedResources fails the test if it left behind resources tagged with its unique ID. Defer this before the
+// cleanup of the test, so that it runs after the cleanup, including when the test panics. When the cleanup stage is
+// skipped with SKIP_cleanup, the resources are left behind on purpose, so they are only logged.
+func checkForLeakedResources(t *testing.T, before *leaks.Snapshot, uniqueID string) {
+	kubectlOptions := getTestCluster(t).KubectlOptions("")
+	if os.Getenv("SKIP_cleanup") != "" {
+		after := leaks.TakeSnapshot(t, kubectlOptions)
+		for _, resource := range after.FindLeaks(before, uniqueID) {
+			logger.Logf(t, "Leaving %s in the cluster, because the cleanup stage is skipped", resource)
+		}
+		return
+	}
+
+	deleteLeaks := os.Getenv(deleteLeakedResourcesEnvVar) != ""
+	// We use assert instead of require, because this may run while the test is panicking.
+	assert.NoError(t, leaks.CheckForLeaksE(t, kubectlOptions, before, uniqueID, deleteLeaks))
+}
+
+// checkForLeakedResourcesOfStages is checkForLeakedResources for tests that save their unique ID in the working
+// directory of the test stages. If the unique ID was never saved, the test didn't get far enough to create anything.
+func checkForLeakedResourcesOfStages(t *testing.T, before *leaks.Snapshot, workingDir string) {
+	if !test_structure.IsTestDataPresent(t, test_structure.FormatTestDataPath(workingDir, "uniqueID.json")) {
+		return
+	}
+	checkForLeakedResources(t, before, test_structure.LoadString(t, workingDir, "uniqueID"))
+}
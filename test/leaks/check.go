@@ -0,0 +1,47 @@
+package leaks
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+)
+
+// CheckForLeaks takes a new snapshot of the cluster and fails the test if it finds resources tagged with the unique ID
+// of the test that are not in the before snapshot. When deleteLeaks is true, the leaked resources are deleted before
+// failing the test.
+func CheckForLeaks(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	before *Snapshot,
+	uniqueID string,
+	deleteLeaks bool,
+) {
+	require.NoError(t, CheckForLeaksE(t, options, before, uniqueID, deleteLeaks))
+}
+
+// CheckForLeaksE takes a new snapshot of the cluster and returns a LeakedResourcesError if it finds resources tagged
+// with the unique ID of the test that are not in the before snapshot. When deleteLeaks is true, the leaked resources
+// are deleted before returning the error, so that they don't pile up in a cluster that is reused across test runs.
+func CheckForLeaksE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	before *Snapshot,
+	uniqueID string,
+	deleteLeaks bool,
+) error {
+	after, err := TakeSnapshotE(t, options)
+	if err != nil {
+		return err
+	}
+	leaked := after.FindLeaks(before, uniqueID)
+	if len(leaked) == 0 {
+		return nil
+	}
+	if deleteLeaks {
+		if err := DeleteResourcesE(t, options, leaked); err != nil {
+			return err
+		}
+	}
+	return LeakedResourcesError{UniqueID: uniqueID, Resources: leaked}
+}
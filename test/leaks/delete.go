@@ -0,0 +1,55 @@
+package leaks
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DeleteResources deletes the given resources from the cluster. This will fail the test if there is an error.
+func DeleteResources(t *testing.T, options *k8s.KubectlOptions, resources []Resource) {
+	require.NoError(t, DeleteResourcesE(t, options, resources))
+}
+
+// DeleteResourcesE deletes the given resources from the cluster.
+func DeleteResourcesE(t *testing.T, options *k8s.KubectlOptions, resources []Resource) error {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return err
+	}
+	for _, resource := range resources {
+		logger.Logf(t, "Deleting leaked %s", resource)
+		if err := DeleteResourceFromClientE(clientset, resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteResourceFromClientE deletes the given resource using the given client. Resources that are already gone are
+// ignored.
+func DeleteResourceFromClientE(clientset kubernetes.Interface, resource Resource) error {
+	deleteOptions := &metav1.DeleteOptions{}
+	var err error
+	switch resource.Kind {
+	case NamespaceKind:
+		err = clientset.CoreV1().Namespaces().Delete(resource.Name, deleteOptions)
+	case ClusterRoleKind:
+		err = clientset.RbacV1().ClusterRoles().Delete(resource.Name, deleteOptions)
+	case ClusterRoleBindingKind:
+		err = clientset.RbacV1().ClusterRoleBindings().Delete(resource.Name, deleteOptions)
+	case SecretKind:
+		err = clientset.CoreV1().Secrets(resource.Namespace).Delete(resource.Name, deleteOptions)
+	default:
+		return UnknownResourceKindError{Resource: resource}
+	}
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package leaks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownResourceKindError is returned when asked to delete a resource of a kind that is not tracked in a Snapshot.
+type UnknownResourceKindError struct {
+	Resource Resource
+}
+
+func (err UnknownResourceKindError) Error() string {
+	return fmt.Sprintf("Can not delete %s: unknown kind %s", err.Resource, err.Resource.Kind)
+}
+
+// LeakedResourcesError is returned when resources tagged with the unique ID of a test are left in the cluster after the
+// test.
+type LeakedResourcesError struct {
+	UniqueID  string
+	Resources []Resource
+}
+
+func (err LeakedResourcesError) Error() string {
+	lines := []string{}
+	for _, resource := range err.Resources {
+		lines = append(lines, resource.String())
+	}
+	return fmt.Sprintf(
+		"Found %d resources for test ID %s left in the cluster:\n%s",
+		len(err.Resources),
+		err.UniqueID,
+		strings.Join(lines, "\n"),
+	)
+}
@@ -0,0 +1,172 @@
+package leaks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The kinds of resources that are tracked in a Snapshot.
+const (
+	NamespaceKind          = "Namespace"
+	ClusterRoleKind        = "ClusterRole"
+	ClusterRoleBindingKind = "ClusterRoleBinding"
+	SecretKind             = "Secret"
+)
+
+// TillerLabelPrefix is the prefix of the labels that mark Secrets as Tiller credentials (e.g.
+// gruntwork.io/tiller-namespace). Only Secrets with one of these labels are tracked in a Snapshot.
+const TillerLabelPrefix = "gruntwork.io/tiller-"
+
+// Resource identifies a Kubernetes resource tracked in a Snapshot. Namespace is empty for cluster scoped resources.
+type Resource struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (resource Resource) String() string {
+	if resource.Namespace == "" {
+		return fmt.Sprintf("%s %s", resource.Kind, resource.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", resource.Kind, resource.Namespace, resource.Name)
+}
+
+// Snapshot is the set of tracked resources in the cluster at a point in time, with the labels of each resource.
+// Resources that are being deleted (e.g. Namespaces in the Terminating phase) are left out, because they will go away
+// on their own.
+type Snapshot struct {
+	Resources map[Resource]map[string]string
+}
+
+// TakeSnapshot lists the Namespaces, ClusterRoles, ClusterRoleBindings, and Tiller credentials Secrets in the cluster.
+// This will fail the test if there is an error.
+func TakeSnapshot(t *testing.T, options *k8s.KubectlOptions) *Snapshot {
+	snapshot, err := TakeSnapshotE(t, options)
+	require.NoError(t, err)
+	return snapshot
+}
+
+// TakeSnapshotE lists the Namespaces, ClusterRoles, ClusterRoleBindings, and Tiller credentials Secrets in the cluster.
+func TakeSnapshotE(t *testing.T, options *k8s.KubectlOptions) (*Snapshot, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return TakeSnapshotFromClientE(clientset)
+}
+
+// TakeSnapshotFromClientE lists the Namespaces, ClusterRoles, ClusterRoleBindings, and Tiller credentials Secrets using
+// the given client.
+func TakeSnapshotFromClientE(clientset kubernetes.Interface) (*Snapshot, error) {
+	snapshot := &Snapshot{Resources: map[Resource]map[string]string{}}
+	add := func(kind string, meta metav1.ObjectMeta) {
+		if meta.DeletionTimestamp != nil {
+			return
+		}
+		snapshot.Resources[Resource{Kind: kind, Namespace: meta.Namespace, Name: meta.Name}] = meta.Labels
+	}
+
+	namespaces, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, namespace := range namespaces.Items {
+		add(NamespaceKind, namespace.ObjectMeta)
+	}
+
+	clusterRoles, err := clientset.RbacV1().ClusterRoles().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, clusterRole := range clusterRoles.Items {
+		add(ClusterRoleKind, clusterRole.ObjectMeta)
+	}
+
+	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, clusterRoleBinding := range clusterRoleBindings.Items {
+		add(ClusterRoleBindingKind, clusterRoleBinding.ObjectMeta)
+	}
+
+	// Label selectors can't match on a key prefix, so we list all the Secrets and filter them here.
+	secrets, err := clientset.CoreV1().Secrets(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		if hasTillerLabel(secret.Labels) {
+			add(SecretKind, secret.ObjectMeta)
+		}
+	}
+
+	return snapshot, nil
+}
+
+func hasTillerLabel(labels map[string]string) bool {
+	for key := range labels {
+		if strings.HasPrefix(key, TillerLabelPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewResources returns the resources in this snapshot that are not in the before snapshot, sorted by kind, namespace,
+// and name.
+func (snapshot *Snapshot) NewResources(before *Snapshot) []Resource {
+	resources := []Resource{}
+	for resource := range snapshot.Resources {
+		if _, existedBefore := before.Resources[resource]; !existedBefore {
+			resources = append(resources, resource)
+		}
+	}
+	sortResources(resources)
+	return resources
+}
+
+// FindLeaks returns the resources in this snapshot that are not in the before snapshot, and that are tagged with the
+// given unique ID of a test: the ID is part of the name or namespace of the resource, or of one of its label values
+// (e.g. the gruntwork.io/tiller-namespace label of the client Secrets that kubergrunt creates in kube-system).
+func (snapshot *Snapshot) FindLeaks(before *Snapshot, uniqueID string) []Resource {
+	uniqueID = strings.ToLower(uniqueID)
+	leaks := []Resource{}
+	for _, resource := range snapshot.NewResources(before) {
+		if isTaggedWith(resource, snapshot.Resources[resource], uniqueID) {
+			leaks = append(leaks, resource)
+		}
+	}
+	return leaks
+}
+
+func isTaggedWith(resource Resource, labels map[string]string, uniqueID string) bool {
+	if strings.Contains(resource.Name, uniqueID) || strings.Contains(resource.Namespace, uniqueID) {
+		return true
+	}
+	for _, value := range labels {
+		if strings.Contains(strings.ToLower(value), uniqueID) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortResources(resources []Resource) {
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Kind != resources[j].Kind {
+			return resources[i].Kind < resources[j].Kind
+		}
+		if resources[i].Namespace != resources[j].Namespace {
+			return resources[i].Namespace < resources[j].Namespace
+		}
+		return resources[i].Name < resources[j].Name
+	})
+}
@@ -0,0 +1,98 @@
+package leaks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testUniqueID = "Ab1Cd2"
+
+func TestFindLeaks(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "existing-namespace-tiller-ca-certs",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"gruntwork.io/tiller-namespace": "existing"},
+		}},
+	)
+	before, err := TakeSnapshotFromClientE(clientset)
+	require.NoError(t, err)
+	assert.Len(t, before.Resources, 2)
+
+	now := metav1.Now()
+	objects := []interface{}{
+		// Leaks: tagged with the unique ID by name or by label.
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ab1cd2-tiller"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "ab1cd2-tiller-access"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "tiller-client-0123456789abcdef-certs",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"gruntwork.io/tiller-namespace": "ab1cd2-tiller"},
+		}},
+		// Not leaks: being deleted, from another test, or not a Tiller credentials Secret.
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ab1cd2-resources", DeletionTimestamp: &now}},
+		&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "zz9yy8-tiller-access"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "ab1cd2-unlabelled", Namespace: "kube-system"}},
+	}
+	for _, object := range objects {
+		var err error
+		switch typed := object.(type) {
+		case *corev1.Namespace:
+			_, err = clientset.CoreV1().Namespaces().Create(typed)
+		case *corev1.Secret:
+			_, err = clientset.CoreV1().Secrets(typed.Namespace).Create(typed)
+		case *rbacv1.ClusterRole:
+			_, err = clientset.RbacV1().ClusterRoles().Create(typed)
+		case *rbacv1.ClusterRoleBinding:
+			_, err = clientset.RbacV1().ClusterRoleBindings().Create(typed)
+		}
+		require.NoError(t, err)
+	}
+
+	after, err := TakeSnapshotFromClientE(clientset)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]Resource{
+			{Kind: ClusterRoleKind, Name: "ab1cd2-tiller-access"},
+			{Kind: ClusterRoleBindingKind, Name: "zz9yy8-tiller-access"},
+			{Kind: NamespaceKind, Name: "ab1cd2-tiller"},
+			{Kind: SecretKind, Namespace: "kube-system", Name: "tiller-client-0123456789abcdef-certs"},
+		},
+		after.NewResources(before),
+	)
+
+	leaked := after.FindLeaks(before, testUniqueID)
+	assert.Equal(
+		t,
+		[]Resource{
+			{Kind: ClusterRoleKind, Name: "ab1cd2-tiller-access"},
+			{Kind: NamespaceKind, Name: "ab1cd2-tiller"},
+			{Kind: SecretKind, Namespace: "kube-system", Name: "tiller-client-0123456789abcdef-certs"},
+		},
+		leaked,
+	)
+
+	for _, resource := range leaked {
+		require.NoError(t, DeleteResourceFromClientE(clientset, resource))
+	}
+	// Deleting a resource that is already gone is not an error.
+	require.NoError(t, DeleteResourceFromClientE(clientset, leaked[0]))
+
+	cleaned, err := TakeSnapshotFromClientE(clientset)
+	require.NoError(t, err)
+	assert.Empty(t, cleaned.FindLeaks(before, testUniqueID))
+
+	err = DeleteResourceFromClientE(clientset, Resource{Kind: "Pod", Name: "foo"})
+	_, isUnknownKindErr := err.(UnknownResourceKindError)
+	assert.True(t, isUnknownKindErr)
+}
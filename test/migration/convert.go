@@ -0,0 +1,170 @@
+package migration
+
+import (
+	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/releases"
+)
+
+// helm3Statuses maps the Tiller release statuses to the Helm 3 release statuses.
+var helm3Statuses = map[string]string{
+	"UNKNOWN":          helm3.StatusUnknown,
+	"DEPLOYED":         helm3.StatusDeployed,
+	"DELETED":          helm3.StatusUninstalled,
+	"SUPERSEDED":       helm3.StatusSuperseded,
+	"FAILED":           helm3.StatusFailed,
+	"DELETING":         helm3.StatusUninstalling,
+	"PENDING_INSTALL":  helm3.StatusPendingInstall,
+	"PENDING_UPGRADE":  helm3.StatusPendingUpgrade,
+	"PENDING_ROLLBACK": helm3.StatusPendingRollback,
+}
+
+// helm3HookEvents maps the Tiller hook events to the Helm 3 hook events. Helm 3 has no crd-install hook and only a
+// single test hook, so the CRD_INSTALL and RELEASE_TEST_FAILURE events can't be migrated.
+var helm3HookEvents = map[string]string{
+	"PRE_INSTALL":          "pre-install",
+	"POST_INSTALL":         "post-install",
+	"PRE_DELETE":           "pre-delete",
+	"POST_DELETE":          "post-delete",
+	"PRE_UPGRADE":          "pre-upgrade",
+	"POST_UPGRADE":         "post-upgrade",
+	"PRE_ROLLBACK":         "pre-rollback",
+	"POST_ROLLBACK":        "post-rollback",
+	"RELEASE_TEST_SUCCESS": "test",
+}
+
+// helm3HookDeletePolicies maps the Tiller hook delete policies to the Helm 3 hook delete policies.
+var helm3HookDeletePolicies = map[string]string{
+	"SUCCEEDED":            "hook-succeeded",
+	"FAILED":               "hook-failed",
+	"BEFORE_HOOK_CREATION": "before-hook-creation",
+}
+
+// ConvertE converts a revision of a release from the record that Tiller stored to the Helm 3 release format. The
+// release keeps its name, version, and namespace, and the raw YAML values of the release and the chart are parsed
+// into the maps that Helm 3 stores. The subcharts of the chart are not converted, as Helm 3 does not store them in the
+// release either.
+func ConvertE(record releases.Record) (*helm3.Release, error) {
+	config, err := parseValuesE(record, record.Config)
+	if err != nil {
+		return nil, err
+	}
+	chart, err := convertChartE(record)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := convertHooksE(record)
+	if err != nil {
+		return nil, err
+	}
+
+	status, hasStatus := helm3Statuses[record.Status]
+	if !hasStatus {
+		status = helm3.StatusUnknown
+	}
+	return &helm3.Release{
+		Name: record.Name,
+		Info: &helm3.Info{
+			FirstDeployed: helm3.Time{Time: record.FirstDeployed},
+			LastDeployed:  helm3.Time{Time: record.LastDeployed},
+			Deleted:       helm3.Time{Time: record.Deleted},
+			Description:   record.Description,
+			Status:        status,
+			Notes:         record.Notes,
+		},
+		Chart:     chart,
+		Config:    config,
+		Manifest:  record.Manifest,
+		Hooks:     hooks,
+		Version:   int(record.Version),
+		Namespace: record.Namespace,
+	}, nil
+}
+
+func convertChartE(record releases.Record) (*helm3.Chart, error) {
+	values, err := parseValuesE(record, record.Chart.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := record.Chart.Metadata
+	chart := &helm3.Chart{
+		Metadata: &helm3.Metadata{
+			Name:        metadata.Name,
+			Home:        metadata.Home,
+			Sources:     metadata.Sources,
+			Version:     metadata.Version,
+			Description: metadata.Description,
+			Keywords:    metadata.Keywords,
+			Icon:        metadata.Icon,
+			APIVersion:  metadata.APIVersion,
+			Condition:   metadata.Condition,
+			Tags:        metadata.Tags,
+			AppVersion:  metadata.AppVersion,
+			Deprecated:  metadata.Deprecated,
+			Annotations: metadata.Annotations,
+			KubeVersion: metadata.KubeVersion,
+		},
+		Values: values,
+	}
+	for _, maintainer := range metadata.Maintainers {
+		chart.Metadata.Maintainers = append(chart.Metadata.Maintainers, &helm3.Maintainer{
+			Name:  maintainer.Name,
+			Email: maintainer.Email,
+			URL:   maintainer.URL,
+		})
+	}
+	for _, template := range record.Chart.Templates {
+		chart.Templates = append(chart.Templates, &helm3.File{Name: template.Name, Data: template.Data})
+	}
+	for _, file := range record.Chart.Files {
+		chart.Files = append(chart.Files, &helm3.File{Name: file.Name, Data: file.Data})
+	}
+	return chart, nil
+}
+
+func convertHooksE(record releases.Record) ([]*helm3.Hook, error) {
+	hooks := []*helm3.Hook{}
+	for _, hook := range record.Hooks {
+		helm3Hook := &helm3.Hook{
+			Name:     hook.Name,
+			Kind:     hook.Kind,
+			Path:     hook.Path,
+			Manifest: hook.Manifest,
+			Weight:   int(hook.Weight),
+		}
+		for _, event := range hook.Events {
+			helm3Event, hasEvent := helm3HookEvents[event]
+			if !hasEvent {
+				return nil, UnsupportedHookEventError{
+					ReleaseName: record.Name,
+					Version:     record.Version,
+					HookName:    hook.Name,
+					Event:       event,
+				}
+			}
+			helm3Hook.Events = append(helm3Hook.Events, helm3Event)
+		}
+		for _, policy := range hook.DeletePolicies {
+			helm3Hook.DeletePolicies = append(helm3Hook.DeletePolicies, helm3HookDeletePolicies[policy])
+		}
+		hooks = append(hooks, helm3Hook)
+	}
+	if len(hooks) == 0 {
+		return nil, nil
+	}
+	return hooks, nil
+}
+
+// parseValuesE parses the raw YAML values of a release or chart into the map that Helm 3 stores. Empty values are
+// returned as nil, which Helm 3 leaves out of the release.
+func parseValuesE(record releases.Record, raw string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(raw), &values); err != nil {
+		return nil, InvalidValuesError{ReleaseName: record.Name, Version: record.Version, Underlying: err}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
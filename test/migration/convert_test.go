@@ -0,0 +1,154 @@
+package migration
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/releases"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	// recordedReleaseSecretsPath holds release Secrets in the format of Tiller v2.12. See the comment at the top of the
+	// file for the releases it contains.
+	recordedReleaseSecretsPath = "../kubefixtures/helm2-release-secrets.yml"
+
+	testTillerNamespace = "tiller-ns"
+)
+
+// newRecordedClientset returns a fake clientset seeded with the recorded Tiller release Secrets.
+func newRecordedClientset(t *testing.T) *fake.Clientset {
+	data, err := ioutil.ReadFile(recordedReleaseSecretsPath)
+	require.NoError(t, err)
+	secrets := corev1.SecretList{}
+	require.NoError(t, yaml.Unmarshal(data, &secrets))
+	return fake.NewSimpleClientset(&secrets)
+}
+
+// getRecordedRecord returns the recorded Tiller record of the given revision of the named release.
+func getRecordedRecord(t *testing.T, clientset *fake.Clientset, releaseName string, version int32) releases.Record {
+	records, err := releases.ListRecordsFromClientE(clientset, testTillerNamespace)
+	require.NoError(t, err)
+	for _, record := range records {
+		if record.Name == releaseName && record.Version == version {
+			return record
+		}
+	}
+	require.FailNow(t, "Release not found", "No record of revision %d of release %s", version, releaseName)
+	return releases.Record{}
+}
+
+func TestConvertKeepsReleaseContents(t *testing.T) {
+	t.Parallel()
+
+	record := getRecordedRecord(t, newRecordedClientset(t), "web", 3)
+	release, err := ConvertE(record)
+	require.NoError(t, err)
+
+	assert.Equal(t, "web", release.Name)
+	assert.Equal(t, "resources", release.Namespace)
+	assert.Equal(t, 3, release.Version)
+	assert.Equal(
+		t,
+		&helm3.Info{
+			FirstDeployed: helm3.Time{Time: time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)},
+			LastDeployed:  helm3.Time{Time: time.Date(2019, 3, 1, 14, 0, 0, 0, time.UTC)},
+			Description:   "Upgrade complete",
+			Status:        helm3.StatusDeployed,
+			Notes:         "Release web is running.\n",
+		},
+		release.Info,
+	)
+	assert.Equal(t, record.Manifest, release.Manifest)
+	assert.Equal(
+		t,
+		map[string]interface{}{"replicaCount": float64(3), "image": map[string]interface{}{"tag": "3.0"}},
+		release.Config,
+	)
+	assert.Equal(
+		t,
+		[]*helm3.Hook{{
+			Name:           "web-pre-install",
+			Kind:           "ConfigMap",
+			Path:           "deployment/templates/hook.yaml",
+			Manifest:       record.Hooks[0].Manifest,
+			Events:         []string{"pre-install", "pre-upgrade"},
+			Weight:         -5,
+			DeletePolicies: []string{"before-hook-creation"},
+		}},
+		release.Hooks,
+	)
+
+	assert.Equal(
+		t,
+		&helm3.Metadata{
+			Name:        "deployment",
+			Version:     "0.1.0",
+			Description: "A Deployment for the migration tests",
+			Keywords:    []string{"test"},
+			Maintainers: []*helm3.Maintainer{{Name: "Gruntwork", Email: "info@gruntwork.io"}},
+			APIVersion:  "v1",
+			AppVersion:  "3.1",
+			Annotations: map[string]string{"gruntwork.io/test": "true"},
+		},
+		release.Chart.Metadata,
+	)
+	require.Equal(t, len(record.Chart.Templates), len(release.Chart.Templates))
+	for i, template := range record.Chart.Templates {
+		assert.Equal(t, template.Name, release.Chart.Templates[i].Name)
+		assert.Equal(t, template.Data, release.Chart.Templates[i].Data)
+	}
+	assert.Equal(
+		t,
+		map[string]interface{}{"replicaCount": float64(1), "image": map[string]interface{}{"tag": "3.1"}},
+		release.Chart.Values,
+	)
+	assert.Equal(t, []*helm3.File{{Name: "README.md", Data: []byte("# deployment\n")}}, release.Chart.Files)
+}
+
+func TestConvertMapsStatusesAndEmptyValues(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+
+	// The first revision of web sets no values, so Helm 3 leaves them out.
+	release, err := ConvertE(getRecordedRecord(t, clientset, "web", 1))
+	require.NoError(t, err)
+	assert.Equal(t, helm3.StatusSuperseded, release.Info.Status)
+	assert.Nil(t, release.Config)
+
+	release, err = ConvertE(getRecordedRecord(t, clientset, "api", 1))
+	require.NoError(t, err)
+	assert.Equal(t, helm3.StatusFailed, release.Info.Status)
+	assert.Equal(t, "other-resources", release.Namespace)
+
+	record := getRecordedRecord(t, clientset, "web", 1)
+	record.Status = "DELETED"
+	release, err = ConvertE(record)
+	require.NoError(t, err)
+	assert.Equal(t, helm3.StatusUninstalled, release.Info.Status)
+}
+
+func TestConvertRejectsUnsupportedRecords(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+
+	record := getRecordedRecord(t, clientset, "web", 3)
+	record.Hooks[0].Events = []string{"CRD_INSTALL"}
+	_, err := ConvertE(record)
+	_, isUnsupportedEventErr := err.(UnsupportedHookEventError)
+	assert.True(t, isUnsupportedEventErr, "Expected UnsupportedHookEventError, got %T: %s", err, err)
+
+	record = getRecordedRecord(t, clientset, "web", 3)
+	record.Config = "replicaCount: [1"
+	_, err = ConvertE(record)
+	_, isInvalidValuesErr := err.(InvalidValuesError)
+	assert.True(t, isInvalidValuesErr, "Expected InvalidValuesError, got %T: %s", err, err)
+}
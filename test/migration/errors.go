@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnsupportedHookEventError is returned when a hook of a release has an event that Helm 3 does not support.
+type UnsupportedHookEventError struct {
+	ReleaseName string
+	Version     int32
+	HookName    string
+	Event       string
+}
+
+func (err UnsupportedHookEventError) Error() string {
+	return fmt.Sprintf(
+		"Hook %s of revision %d of release %s has the event %s, which Helm 3 does not support",
+		err.HookName,
+		err.Version,
+		err.ReleaseName,
+		err.Event,
+	)
+}
+
+// InvalidValuesError is returned when the values of a release or its chart are not valid YAML.
+type InvalidValuesError struct {
+	ReleaseName string
+	Version     int32
+	Underlying  error
+}
+
+func (err InvalidValuesError) Error() string {
+	return fmt.Sprintf(
+		"Error parsing the values of revision %d of release %s: %s",
+		err.Version,
+		err.ReleaseName,
+		err.Underlying,
+	)
+}
+
+// ConflictingReleasesError is returned when there already are Helm 3 release Secrets that differ from the migrated
+// releases. Nothing is migrated in that case.
+type ConflictingReleasesError struct {
+	Conflicts []Change
+}
+
+func (err ConflictingReleasesError) Error() string {
+	secrets := []string{}
+	for _, change := range err.Conflicts {
+		secrets = append(secrets, change.Target())
+	}
+	return fmt.Sprintf(
+		"The Helm 3 release Secrets %s already exist and differ from the migrated releases. Run a dry run for the diff.",
+		strings.Join(secrets, ", "),
+	)
+}
+
+// RoundTripMismatchError is returned when the Helm 3 releases don't match the Tiller release records they were
+// migrated from.
+type RoundTripMismatchError struct {
+	Mismatches []string
+}
+
+func (err RoundTripMismatchError) Error() string {
+	return fmt.Sprintf(
+		"Found %d mismatches between the Helm 3 releases and the Tiller release records:\n%s",
+		len(err.Mismatches),
+		strings.Join(err.Mismatches, "\n"),
+	)
+}
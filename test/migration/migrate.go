@@ -0,0 +1,228 @@
+package migration
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/releases"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Action is what the migration does with a revision of a release.
+type Action string
+
+const (
+	// ActionCreate means that there is no Helm 3 release Secret for the revision yet, so the migration creates it.
+	ActionCreate Action = "create"
+
+	// ActionUnchanged means that the Helm 3 release Secret for the revision already matches the migrated release, e.g.
+	// because the migration ran before.
+	ActionUnchanged Action = "unchanged"
+
+	// ActionConflict means that the Helm 3 release Secret for the revision differs from the migrated release, e.g.
+	// because the release was upgraded with Helm 3 in the meantime. The migration never overwrites these.
+	ActionConflict Action = "conflict"
+)
+
+// Options are the options of a migration.
+type Options struct {
+	// DryRun only plans the migration and computes the diffs, without creating any Secrets.
+	DryRun bool
+
+	// ReleaseNames limits the migration to the named releases. All the releases of the Tiller are migrated if empty.
+	ReleaseNames []string
+}
+
+// Change is the planned migration of a revision of a release, from the Secret in the Tiller namespace to the Helm 3
+// release Secret in the namespace of the release.
+type Change struct {
+	ReleaseName      string
+	Version          int
+	SourceNamespace  string
+	SourceSecretName string
+	TargetNamespace  string
+	TargetSecretName string
+	Action           Action
+
+	// Diff is a unified diff from the existing Helm 3 release, or from nothing if there is none, to the migrated
+	// release, both rendered as YAML. Empty if the action is ActionUnchanged.
+	Diff string
+}
+
+// Source returns the namespace and name of the Tiller release Secret of the change.
+func (change Change) Source() string {
+	return fmt.Sprintf("%s/%s", change.SourceNamespace, change.SourceSecretName)
+}
+
+// Target returns the namespace and name of the Helm 3 release Secret of the change.
+func (change Change) Target() string {
+	return fmt.Sprintf("%s/%s", change.TargetNamespace, change.TargetSecretName)
+}
+
+// Migrate migrates the releases that Tiller stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace, to the Helm 3 storage format. See MigrateFromClientE for the details. This will fail
+// the test if there is an error.
+func Migrate(t *testing.T, options *k8s.KubectlOptions, migrateOptions Options) []Change {
+	changes, err := MigrateE(t, options, migrateOptions)
+	require.NoError(t, err)
+	return changes
+}
+
+// MigrateE migrates the releases that Tiller stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace, to the Helm 3 storage format. See MigrateFromClientE for the details.
+func MigrateE(t *testing.T, options *k8s.KubectlOptions, migrateOptions Options) ([]Change, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return MigrateFromClientE(clientset, options.Namespace, migrateOptions)
+}
+
+// MigrateFromClientE migrates every revision of the releases that Tiller stored as Secrets in the given namespace to
+// a Helm 3 release Secret in the namespace of the release, using the given client, and returns the change for each
+// revision, sorted by release name and version. With DryRun set, this only returns the changes. Otherwise, this creates
+// the missing Helm 3 release Secrets, unless any of the existing ones conflict with the migrated releases, in which
+// case nothing is created and this returns a ConflictingReleasesError. The Tiller release Secrets are left as is, so
+// that the releases can still be managed with Helm 2 until the migration is verified.
+func MigrateFromClientE(clientset kubernetes.Interface, tillerNamespace string, options Options) ([]Change, error) {
+	records, err := releases.ListRecordsFromClientE(clientset, tillerNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	newSecrets := []*corev1.Secret{}
+	conflicts := []Change{}
+	for _, record := range filterRecords(records, options.ReleaseNames) {
+		change, secret, err := planChangeE(clientset, tillerNamespace, record)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+		switch change.Action {
+		case ActionCreate:
+			newSecrets = append(newSecrets, secret)
+		case ActionConflict:
+			conflicts = append(conflicts, change)
+		}
+	}
+	if options.DryRun {
+		return changes, nil
+	}
+	if len(conflicts) > 0 {
+		return changes, ConflictingReleasesError{Conflicts: conflicts}
+	}
+
+	for _, secret := range newSecrets {
+		if _, err := clientset.CoreV1().Secrets(secret.Namespace).Create(secret); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// planChangeE converts the release record and compares the result with the existing Helm 3 release Secret, if any.
+// Returns the change along with the Helm 3 release Secret of the migrated release.
+func planChangeE(
+	clientset kubernetes.Interface,
+	tillerNamespace string,
+	record releases.Record,
+) (Change, *corev1.Secret, error) {
+	release, err := ConvertE(record)
+	if err != nil {
+		return Change{}, nil, err
+	}
+	secret, err := helm3.NewReleaseSecretE(release)
+	if err != nil {
+		return Change{}, nil, err
+	}
+	change := Change{
+		ReleaseName:      release.Name,
+		Version:          release.Version,
+		SourceNamespace:  tillerNamespace,
+		SourceSecretName: record.SecretName,
+		TargetNamespace:  secret.Namespace,
+		TargetSecretName: secret.Name,
+	}
+
+	var existingRelease *helm3.Release
+	existingSecret, err := clientset.CoreV1().Secrets(secret.Namespace).Get(secret.Name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		change.Action = ActionCreate
+	case err != nil:
+		return Change{}, nil, err
+	default:
+		existingRelease, err = helm3.DecodeReleaseSecretE(existingSecret)
+		if err != nil {
+			return Change{}, nil, err
+		}
+	}
+
+	change.Diff, err = diffReleasesE(existingRelease, release, change)
+	if err != nil {
+		return Change{}, nil, err
+	}
+	if change.Action == "" {
+		if change.Diff == "" {
+			change.Action = ActionUnchanged
+		} else {
+			change.Action = ActionConflict
+		}
+	}
+	return change, secret, nil
+}
+
+// diffReleasesE returns a unified diff from the existing Helm 3 release, which is nil if there is none, to the
+// migrated release. Returns an empty string if they are the same.
+func diffReleasesE(existing *helm3.Release, migrated *helm3.Release, change Change) (string, error) {
+	existingYAML := ""
+	fromFile := "/dev/null"
+	if existing != nil {
+		rendered, err := yaml.Marshal(existing)
+		if err != nil {
+			return "", err
+		}
+		existingYAML = string(rendered)
+		fromFile = change.Target()
+	}
+	migratedYAML, err := yaml.Marshal(migrated)
+	if err != nil {
+		return "", err
+	}
+	if existingYAML == string(migratedYAML) {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(existingYAML),
+		B:        difflib.SplitLines(string(migratedYAML)),
+		FromFile: fromFile,
+		ToFile:   fmt.Sprintf("%s (migrated from %s)", change.Target(), change.Source()),
+		Context:  3,
+	})
+}
+
+// filterRecords returns the records of the named releases, or all the records if no names are given.
+func filterRecords(records []releases.Record, releaseNames []string) []releases.Record {
+	if len(releaseNames) == 0 {
+		return records
+	}
+	filtered := []releases.Record{}
+	for _, record := range records {
+		for _, releaseName := range releaseNames {
+			if record.Name == releaseName {
+				filtered = append(filtered, record)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,125 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMigrateDryRunOnlyPlans(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+	changes, err := MigrateFromClientE(clientset, testTillerNamespace, Options{DryRun: true})
+	require.NoError(t, err)
+
+	targets := []string{}
+	for _, change := range changes {
+		assert.Equal(t, ActionCreate, change.Action, change.Target())
+		assert.Contains(t, change.Diff, "--- /dev/null")
+		targets = append(targets, change.Target())
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"other-resources/sh.helm.release.v1.api.v1",
+			"resources/sh.helm.release.v1.web.v1",
+			"resources/sh.helm.release.v1.web.v2",
+			"resources/sh.helm.release.v1.web.v3",
+		},
+		targets,
+	)
+	assert.Equal(t, "tiller-ns/web.v3", changes[3].Source())
+	assert.Contains(t, changes[3].Diff, "+  replicaCount: 3")
+
+	// Nothing was created.
+	secrets, err := clientset.CoreV1().Secrets(metav1.NamespaceAll).List(
+		metav1.ListOptions{LabelSelector: helm3.OwnerLabel + "=" + helm3.OwnerLabelValue},
+	)
+	require.NoError(t, err)
+	assert.Empty(t, secrets.Items)
+}
+
+func TestMigrateCreatesReleaseSecretsOnce(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+	changes, err := MigrateFromClientE(clientset, testTillerNamespace, Options{ReleaseNames: []string{"web"}})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(changes))
+
+	secrets, err := helm3.ListReleaseSecretsFromClientE(clientset, "web")
+	require.NoError(t, err)
+	helm3.CheckReleaseSecretsInNamespace(t, secrets, "web", "resources")
+	statuses := []string{}
+	for _, secret := range secrets {
+		statuses = append(statuses, secret.Status)
+	}
+	assert.Equal(t, []string{"superseded", "superseded", "deployed"}, statuses)
+	require.NoError(t, VerifyFromClientE(clientset, testTillerNamespace, []string{"web"}))
+
+	// The api release was left out.
+	secrets, err = helm3.ListReleaseSecretsFromClientE(clientset, "api")
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+
+	// Migrating again is a no-op for the releases that were migrated before.
+	changes, err = MigrateFromClientE(clientset, testTillerNamespace, Options{})
+	require.NoError(t, err)
+	actions := map[string]Action{}
+	for _, change := range changes {
+		actions[change.Target()] = change.Action
+		if change.Action == ActionUnchanged {
+			assert.Empty(t, change.Diff)
+		}
+	}
+	assert.Equal(
+		t,
+		map[string]Action{
+			"other-resources/sh.helm.release.v1.api.v1": ActionCreate,
+			"resources/sh.helm.release.v1.web.v1":       ActionUnchanged,
+			"resources/sh.helm.release.v1.web.v2":       ActionUnchanged,
+			"resources/sh.helm.release.v1.web.v3":       ActionUnchanged,
+		},
+		actions,
+	)
+	require.NoError(t, VerifyFromClientE(clientset, testTillerNamespace, nil))
+}
+
+func TestMigrateRefusesToOverwriteConflictingReleases(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+
+	// The web release was upgraded with Helm 3 before the migration, with different values in the same revision.
+	release, err := ConvertE(getRecordedRecord(t, clientset, "web", 3))
+	require.NoError(t, err)
+	release.Config["replicaCount"] = float64(5)
+	secret, err := helm3.NewReleaseSecretE(release)
+	require.NoError(t, err)
+	_, err = clientset.CoreV1().Secrets(secret.Namespace).Create(secret)
+	require.NoError(t, err)
+
+	changes, err := MigrateFromClientE(clientset, testTillerNamespace, Options{DryRun: true})
+	require.NoError(t, err)
+	conflict := changes[3]
+	assert.Equal(t, ActionConflict, conflict.Action)
+	assert.Contains(t, conflict.Diff, "--- resources/sh.helm.release.v1.web.v3\n")
+	assert.Contains(t, conflict.Diff, "-  replicaCount: 5\n+  replicaCount: 3\n")
+
+	_, err = MigrateFromClientE(clientset, testTillerNamespace, Options{})
+	conflictsErr, isConflictsErr := err.(ConflictingReleasesError)
+	require.True(t, isConflictsErr, "Expected ConflictingReleasesError, got %T: %s", err, err)
+	assert.Equal(t, []Change{conflict}, conflictsErr.Conflicts)
+
+	// Nothing was migrated, and the conflicting release was left as is.
+	secrets, err := helm3.ListReleaseSecretsFromClientE(clientset, "web")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(secrets))
+	existing, err := clientset.CoreV1().Secrets(secret.Namespace).Get(secret.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, secret.Data, existing.Data)
+}
@@ -0,0 +1,192 @@
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/releases"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Verify checks that the releases that Tiller stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace, were migrated to Helm 3 faithfully. See VerifyFromClientE for the details. This will
+// fail the test if there is an error or a mismatch.
+func Verify(t *testing.T, options *k8s.KubectlOptions, releaseNames []string) {
+	require.NoError(t, VerifyE(t, options, releaseNames))
+}
+
+// VerifyE checks that the releases that Tiller stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace, were migrated to Helm 3 faithfully. See VerifyFromClientE for the details.
+func VerifyE(t *testing.T, options *k8s.KubectlOptions, releaseNames []string) error {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return err
+	}
+	return VerifyFromClientE(clientset, options.Namespace, releaseNames)
+}
+
+// VerifyFromClientE checks that the named releases that Tiller stored as Secrets in the given namespace, or all of
+// them if no names are given, were migrated to Helm 3 faithfully, using the given client. For each revision, the Helm 3
+// release Secret must exist in the namespace of the release with the type and labels that Helm 3 sets, and the decoded
+// release must have the same manifest, values, chart (metadata, templates, values, and files), hooks, and deployment
+// info as the Tiller record. The revision history of each release must also be the same: Helm 3 must have exactly the
+// revisions that Tiller has. Returns a RoundTripMismatchError that lists every mismatch.
+func VerifyFromClientE(clientset kubernetes.Interface, tillerNamespace string, releaseNames []string) error {
+	records, err := releases.ListRecordsFromClientE(clientset, tillerNamespace)
+	if err != nil {
+		return err
+	}
+	records = filterRecords(records, releaseNames)
+
+	mismatches := []string{}
+	tillerVersions := map[string]map[int]bool{}
+	releaseNamespaces := map[string]string{}
+	for _, record := range records {
+		if tillerVersions[record.Name] == nil {
+			tillerVersions[record.Name] = map[int]bool{}
+		}
+		tillerVersions[record.Name][int(record.Version)] = true
+		releaseNamespaces[record.Name] = record.Namespace
+
+		recordMismatches, err := verifyRecordE(clientset, record)
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, recordMismatches...)
+	}
+
+	for releaseName, versions := range tillerVersions {
+		secrets, err := helm3.ListReleaseSecretsFromClientE(clientset, releaseName)
+		if err != nil {
+			return err
+		}
+		for _, secret := range secrets {
+			if secret.Namespace == releaseNamespaces[releaseName] && !versions[secret.Version] {
+				mismatches = append(
+					mismatches,
+					fmt.Sprintf("%s: revision %d of release %s is not in the Tiller history", secret, secret.Version, releaseName),
+				)
+			}
+		}
+	}
+
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return RoundTripMismatchError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// verifyRecordE compares the Helm 3 release Secret of a revision with the Tiller record, and returns the mismatches.
+func verifyRecordE(clientset kubernetes.Interface, record releases.Record) ([]string, error) {
+	expected, err := ConvertE(record)
+	if err != nil {
+		return nil, err
+	}
+	expectedSecret, err := helm3.NewReleaseSecretE(expected)
+	if err != nil {
+		return nil, err
+	}
+	target := fmt.Sprintf("%s/%s", expectedSecret.Namespace, expectedSecret.Name)
+
+	secret, err := clientset.CoreV1().Secrets(expectedSecret.Namespace).Get(expectedSecret.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return []string{fmt.Sprintf("%s: missing, expected a migration of %s", target, record.SecretName)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := []string{}
+	if secret.Type != helm3.ReleaseSecretType {
+		mismatches = append(
+			mismatches,
+			fmt.Sprintf("%s: type is %s, expected %s", target, secret.Type, helm3.ReleaseSecretType),
+		)
+	}
+	for key, value := range expectedSecret.Labels {
+		if secret.Labels[key] != value {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("%s: label %s is %q, expected %q", target, key, secret.Labels[key], value),
+			)
+		}
+	}
+	actual, err := helm3.DecodeReleaseSecretE(secret)
+	if err != nil {
+		return append(mismatches, fmt.Sprintf("%s: %s", target, err)), nil
+	}
+
+	fieldMismatches, err := compareReleasesE(expected, actual)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fieldMismatches {
+		mismatches = append(mismatches, fmt.Sprintf("%s: %s differs from %s", target, field, record.SecretName))
+	}
+	return mismatches, nil
+}
+
+// compareReleasesE returns the names of the fields that differ between the two releases. The fields are compared in
+// their JSON encoding, so that e.g. the location of times doesn't count as a difference. Empty maps and slices are
+// the same as missing ones, as Helm 3 writes some of them out and leaves others out.
+func compareReleasesE(expected *helm3.Release, actual *helm3.Release) ([]string, error) {
+	expectedChart := expected.Chart
+	if expectedChart == nil {
+		expectedChart = &helm3.Chart{}
+	}
+	actualChart := actual.Chart
+	if actualChart == nil {
+		actualChart = &helm3.Chart{}
+	}
+	fields := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+	}{
+		{"name", expected.Name, actual.Name},
+		{"namespace", expected.Namespace, actual.Namespace},
+		{"version", strconv.Itoa(expected.Version), strconv.Itoa(actual.Version)},
+		{"info", expected.Info, actual.Info},
+		{"manifest", expected.Manifest, actual.Manifest},
+		{"config", expected.Config, actual.Config},
+		{"hooks", expected.Hooks, actual.Hooks},
+		{"chart metadata", expectedChart.Metadata, actualChart.Metadata},
+		{"chart templates", expectedChart.Templates, actualChart.Templates},
+		{"chart values", expectedChart.Values, actualChart.Values},
+		{"chart files", expectedChart.Files, actualChart.Files},
+	}
+
+	mismatches := []string{}
+	for _, field := range fields {
+		expectedJSON, err := json.Marshal(field.expected)
+		if err != nil {
+			return nil, err
+		}
+		actualJSON, err := json.Marshal(field.actual)
+		if err != nil {
+			return nil, err
+		}
+		if normalizeEmptyJSON(expectedJSON) != normalizeEmptyJSON(actualJSON) {
+			mismatches = append(mismatches, field.name)
+		}
+	}
+	return mismatches, nil
+}
+
+// normalizeEmptyJSON returns the JSON encoding as a string, with empty objects and arrays as null.
+func normalizeEmptyJSON(data []byte) string {
+	switch string(data) {
+	case "{}", "[]":
+		return "null"
+	}
+	return string(data)
+}
@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/helm3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVerifyReportsEveryMismatch(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+	_, err := MigrateFromClientE(clientset, testTillerNamespace, Options{})
+	require.NoError(t, err)
+	require.NoError(t, VerifyFromClientE(clientset, testTillerNamespace, nil))
+	secrets := clientset.CoreV1().Secrets("resources")
+
+	// Tamper with the manifest and the chart of the latest revision of web.
+	secret, err := secrets.Get(helm3.ReleaseSecretName("web", 3), metav1.GetOptions{})
+	require.NoError(t, err)
+	release, err := helm3.DecodeReleaseSecretE(secret)
+	require.NoError(t, err)
+	release.Manifest = "---\n"
+	release.Chart.Metadata.AppVersion = "3.2"
+	tampered, err := helm3.NewReleaseSecretE(release)
+	require.NoError(t, err)
+	_, err = secrets.Update(tampered)
+	require.NoError(t, err)
+
+	// Drop the first revision from the history, and add a revision that Tiller never had.
+	require.NoError(t, secrets.Delete(helm3.ReleaseSecretName("web", 1), &metav1.DeleteOptions{}))
+	release.Version = 4
+	extra, err := helm3.NewReleaseSecretE(release)
+	require.NoError(t, err)
+	_, err = secrets.Create(extra)
+	require.NoError(t, err)
+
+	err = VerifyFromClientE(clientset, testTillerNamespace, nil)
+	mismatchErr, isMismatchErr := err.(RoundTripMismatchError)
+	require.True(t, isMismatchErr, "Expected RoundTripMismatchError, got %T: %s", err, err)
+	assert.Equal(
+		t,
+		[]string{
+			"resources/sh.helm.release.v1.web.v1: missing, expected a migration of web.v1",
+			"resources/sh.helm.release.v1.web.v3: chart metadata differs from web.v3",
+			"resources/sh.helm.release.v1.web.v3: manifest differs from web.v3",
+			"resources/sh.helm.release.v1.web.v4: revision 4 of release web is not in the Tiller history",
+		},
+		mismatchErr.Mismatches,
+	)
+
+	// The api release was migrated faithfully.
+	assert.NoError(t, VerifyFromClientE(clientset, testTillerNamespace, []string{"api"}))
+}
+
+func TestVerifyChecksSecretTypeAndLabels(t *testing.T) {
+	t.Parallel()
+
+	clientset := newRecordedClientset(t)
+	_, err := MigrateFromClientE(clientset, testTillerNamespace, Options{ReleaseNames: []string{"api"}})
+	require.NoError(t, err)
+
+	secrets := clientset.CoreV1().Secrets("other-resources")
+	secret, err := secrets.Get(helm3.ReleaseSecretName("api", 1), metav1.GetOptions{})
+	require.NoError(t, err)
+	secret.Type = "Opaque"
+	secret.Labels[helm3.StatusLabel] = helm3.StatusDeployed
+	_, err = secrets.Update(secret)
+	require.NoError(t, err)
+
+	err = VerifyFromClientE(clientset, testTillerNamespace, []string{"api"})
+	mismatchErr, isMismatchErr := err.(RoundTripMismatchError)
+	require.True(t, isMismatchErr, "Expected RoundTripMismatchError, got %T: %s", err, err)
+	assert.Equal(
+		t,
+		[]string{
+			`other-resources/sh.helm.release.v1.api.v1: label status is "deployed", expected "failed"`,
+			"other-resources/sh.helm.release.v1.api.v1: type is Opaque, expected helm.sh/release.v1",
+		},
+		mismatchErr.Mismatches,
+	)
+}
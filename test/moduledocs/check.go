@@ -0,0 +1,72 @@
+package moduledocs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// CheckModuleDocs checks that the README of the module in the given directory documents exactly the variables and
+// outputs of the module, and that they all have a description. This will fail the test if there is an error.
+func CheckModuleDocs(t *testing.T, moduleDir string) {
+	require.NoError(t, CheckModuleDocsE(moduleDir))
+}
+
+// CheckModuleDocsE checks that the README of the module in the given directory documents exactly the variables and
+// outputs of the module, and that they all have a description. All the problems are returned in a single
+// ModuleDocsMismatchesError.
+func CheckModuleDocsE(moduleDir string) error {
+	module, err := ParseModuleE(moduleDir)
+	if err != nil {
+		return err
+	}
+	readme, err := ParseReadmeE(filepath.Join(moduleDir, "README.md"))
+	if err != nil {
+		return err
+	}
+
+	mismatches := []string{}
+	mismatches = append(mismatches, compareEntries(module.Variables, readme.Inputs, "variable", InputsHeading)...)
+	mismatches = append(mismatches, compareEntries(module.Outputs, readme.Outputs, "output", OutputsHeading)...)
+
+	if len(mismatches) > 0 {
+		return ModuleDocsMismatchesError{ModuleDir: moduleDir, Mismatches: mismatches}
+	}
+	return nil
+}
+
+func compareEntries(blocks []Block, readmeEntries []string, blockType string, heading string) []string {
+	mismatches := []string{}
+
+	documented := map[string]bool{}
+	for _, name := range readmeEntries {
+		if documented[name] {
+			mismatches = append(mismatches, fmt.Sprintf("%s is documented more than once under %s", name, heading))
+		}
+		documented[name] = true
+	}
+
+	declared := map[string]bool{}
+	for _, block := range blocks {
+		declared[block.Name] = true
+		if strings.TrimSpace(block.Description) == "" {
+			mismatches = append(mismatches, fmt.Sprintf("%s %s has an empty description", blockType, block.Name))
+		}
+		if !documented[block.Name] {
+			mismatches = append(mismatches, fmt.Sprintf("%s %s is not documented under %s", blockType, block.Name, heading))
+		}
+	}
+
+	for _, name := range readmeEntries {
+		if !declared[name] {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("%s is documented under %s, but there is no %s with that name", name, heading, blockType),
+			)
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,108 @@
+package moduledocs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleReadmesMatchModules(t *testing.T) {
+	t.Parallel()
+
+	moduleDirs, err := filepath.Glob(filepath.Join("..", "..", "modules", "*"))
+	require.NoError(t, err)
+	require.NotEmpty(t, moduleDirs)
+
+	for _, moduleDir := range moduleDirs {
+		// Capture range variable so that it doesn't change across the parallel subtests
+		moduleDir := moduleDir
+		t.Run(filepath.Base(moduleDir), func(t *testing.T) {
+			t.Parallel()
+			CheckModuleDocs(t, moduleDir)
+		})
+	}
+}
+
+const testVariablesTF = `
+variable "documented" {
+  description = "A variable that is documented."
+  type        = string
+}
+
+variable "undocumented" {
+  description = <<EOF
+A variable that is not documented.
+EOF
+  type = list(string)
+}
+
+variable "no_description" {
+  type    = map(string)
+  default = {}
+}
+`
+
+const testOutputsTF = `
+output "documented" {
+  description = "An output that is documented."
+  value       = var.documented
+}
+
+output "empty_description" {
+  description = ""
+  value       = var.undocumented
+}
+`
+
+const testReadme = "# Test module\n\n" +
+	"| Name | Description |\n| ---- | ----------- |\n| `not_in_a_section` | Ignored. |\n\n" +
+	"## Inputs\n\n" +
+	"| Name | Description |\n| ---- | ----------- |\n" +
+	"| `documented` | A variable that is documented. |\n" +
+	"| `no_description` | A variable without a description. |\n" +
+	"| `removed` | A variable that no longer exists. |\n\n" +
+	"## Outputs\n\n" +
+	"| Name | Description |\n| ---- | ----------- |\n" +
+	"| `documented` | An output that is documented. |\n" +
+	"| `empty_description` | An output with an empty description. |\n" +
+	"| `documented` | An output that is documented twice. |\n"
+
+func TestCheckModuleDocsMismatches(t *testing.T) {
+	t.Parallel()
+
+	moduleDir, err := ioutil.TempDir("", "moduledocs")
+	require.NoError(t, err)
+	defer os.RemoveAll(moduleDir)
+
+	files := map[string]string{
+		"variables.tf": testVariablesTF,
+		"outputs.tf":   testOutputsTF,
+		"README.md":    testReadme,
+	}
+	for name, contents := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(moduleDir, name), []byte(contents), 0644))
+	}
+
+	module := ParseModule(t, moduleDir)
+	assert.Equal(t, "A variable that is not documented.\n", module.Variables[2].Description)
+
+	err = CheckModuleDocsE(moduleDir)
+	require.Error(t, err)
+	mismatchErr, isMismatchErr := err.(ModuleDocsMismatchesError)
+	require.True(t, isMismatchErr)
+	assert.Equal(
+		t,
+		[]string{
+			"variable no_description has an empty description",
+			"variable undocumented is not documented under ## Inputs",
+			"removed is documented under ## Inputs, but there is no variable with that name",
+			"documented is documented more than once under ## Outputs",
+			"output empty_description has an empty description",
+		},
+		mismatchErr.Mismatches,
+	)
+}
@@ -0,0 +1,32 @@
+package moduledocs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NonStringDescriptionError is returned when the description of a variable or output is not a constant string.
+type NonStringDescriptionError struct {
+	Type     string
+	Name     string
+	Filename string
+}
+
+func (err NonStringDescriptionError) Error() string {
+	return fmt.Sprintf("The description of %s %s in %s is not a string", err.Type, err.Name, err.Filename)
+}
+
+// ModuleDocsMismatchesError is returned when the README of a module does not match the variables and outputs of the
+// module.
+type ModuleDocsMismatchesError struct {
+	ModuleDir  string
+	Mismatches []string
+}
+
+func (err ModuleDocsMismatchesError) Error() string {
+	return fmt.Sprintf(
+		"The README of the module in %s does not match the module:\n%s",
+		err.ModuleDir,
+		strings.Join(err.Mismatches, "\n"),
+	)
+}
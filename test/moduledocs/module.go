@@ -0,0 +1,113 @@
+package moduledocs
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Block is a variable or output block declared in a Terraform module.
+type Block struct {
+	Type        string
+	Name        string
+	Description string
+	Filename    string
+}
+
+// Module is the interface of a Terraform module, as declared in its .tf files.
+type Module struct {
+	Dir       string
+	Variables []Block
+	Outputs   []Block
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+	},
+}
+
+var descriptionSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "description"},
+	},
+}
+
+// ParseModule parses the variables and outputs declared in the .tf files of the module in the given directory. This
+// will fail the test if there is an error.
+func ParseModule(t *testing.T, moduleDir string) Module {
+	module, err := ParseModuleE(moduleDir)
+	require.NoError(t, err)
+	return module
+}
+
+// ParseModuleE parses the variables and outputs declared in the .tf files of the module in the given directory. The
+// blocks are sorted by name.
+func ParseModuleE(moduleDir string) (Module, error) {
+	module := Module{Dir: moduleDir, Variables: []Block{}, Outputs: []Block{}}
+
+	paths, err := filepath.Glob(filepath.Join(moduleDir, "*.tf"))
+	if err != nil {
+		return module, err
+	}
+	sort.Strings(paths)
+
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return module, diags
+		}
+		content, _, diags := file.Body.PartialContent(moduleSchema)
+		if diags.HasErrors() {
+			return module, diags
+		}
+		for _, hclBlock := range content.Blocks {
+			block, err := parseBlock(hclBlock, path)
+			if err != nil {
+				return module, err
+			}
+			if block.Type == "variable" {
+				module.Variables = append(module.Variables, block)
+			} else {
+				module.Outputs = append(module.Outputs, block)
+			}
+		}
+	}
+
+	sortBlocks(module.Variables)
+	sortBlocks(module.Outputs)
+	return module, nil
+}
+
+func parseBlock(hclBlock *hcl.Block, path string) (Block, error) {
+	block := Block{Type: hclBlock.Type, Name: hclBlock.Labels[0], Filename: path}
+
+	content, _, diags := hclBlock.Body.PartialContent(descriptionSchema)
+	if diags.HasErrors() {
+		return block, diags
+	}
+	attribute, hasDescription := content.Attributes["description"]
+	if !hasDescription {
+		return block, nil
+	}
+	value, diags := attribute.Expr.Value(nil)
+	if diags.HasErrors() {
+		return block, diags
+	}
+	if value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return block, NonStringDescriptionError{Type: block.Type, Name: block.Name, Filename: path}
+	}
+	block.Description = value.AsString()
+	return block, nil
+}
+
+func sortBlocks(blocks []Block) {
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Name < blocks[j].Name })
+}
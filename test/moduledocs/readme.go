@@ -0,0 +1,57 @@
+package moduledocs
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// InputsHeading is the heading of the README section that documents the variables of the module.
+	InputsHeading = "## Inputs"
+
+	// OutputsHeading is the heading of the README section that documents the outputs of the module.
+	OutputsHeading = "## Outputs"
+)
+
+// Each entry is a table row with the name in code quotes in the first column, e.g. "| `namespace` | The name ... |".
+var readmeEntryRe = regexp.MustCompile("^\\|\\s*`([^`]+)`\\s*\\|")
+
+// Readme is the inputs and outputs documented in the README of a module.
+type Readme struct {
+	Path    string
+	Inputs  []string
+	Outputs []string
+}
+
+// ParseReadmeE parses the names of the inputs and outputs documented in the tables under the Inputs and Outputs
+// headings of the README.
+func ParseReadmeE(path string) (Readme, error) {
+	readme := Readme{Path: path, Inputs: []string{}, Outputs: []string{}}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return readme, err
+	}
+	defer file.Close()
+
+	var section *[]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == InputsHeading:
+			section = &readme.Inputs
+		case line == OutputsHeading:
+			section = &readme.Outputs
+		case strings.HasPrefix(line, "#"):
+			section = nil
+		case section != nil:
+			if match := readmeEntryRe.FindStringSubmatch(line); match != nil {
+				*section = append(*section, match[1])
+			}
+		}
+	}
+	return readme, scanner.Err()
+}
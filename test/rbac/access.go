@@ -0,0 +1,243 @@
+package rbac
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	authv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// AccessExpectation describes a single action on the Kubernetes API, and whether or not the subject under test is
+// expected to be allowed to perform it. Group is the API group of the resource, where the core group is the empty
+// string.
+type AccessExpectation struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Name        string
+	Namespace   string
+	Allowed     bool
+}
+
+// String returns a human readable representation of the action, in a form similar to `kubectl auth can-i`.
+func (expectation AccessExpectation) String() string {
+	resource := expectation.Resource
+	if expectation.Group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, expectation.Group)
+	}
+	if expectation.Subresource != "" {
+		resource = fmt.Sprintf("%s/%s", resource, expectation.Subresource)
+	}
+	if expectation.Name != "" {
+		resource = fmt.Sprintf("%s/%s", resource, expectation.Name)
+	}
+	namespace := expectation.Namespace
+	if namespace == "" {
+		namespace = "(cluster)"
+	}
+	return fmt.Sprintf("%s %s in namespace %s", expectation.Verb, resource, namespace)
+}
+
+// ResourceAttributes returns the action as the resource attributes of an access review.
+func (expectation AccessExpectation) ResourceAttributes() *authv1.ResourceAttributes {
+	return &authv1.ResourceAttributes{
+		Verb:        expectation.Verb,
+		Group:       expectation.Group,
+		Resource:    expectation.Resource,
+		Subresource: expectation.Subresource,
+		Name:        expectation.Name,
+		Namespace:   expectation.Namespace,
+	}
+}
+
+// SelfSubjectAccessReview returns a SelfSubjectAccessReview object for the action, with the type metadata set so that
+// it can be serialized and posted directly to the API (e.g from within a Pod).
+func (expectation AccessExpectation) SelfSubjectAccessReview() *authv1.SelfSubjectAccessReview {
+	return &authv1.SelfSubjectAccessReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "authorization.k8s.io/v1",
+			Kind:       "SelfSubjectAccessReview",
+		},
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: expectation.ResourceAttributes(),
+		},
+	}
+}
+
+// AccessMismatch records an expectation that did not match the result of the access review.
+type AccessMismatch struct {
+	Expectation AccessExpectation
+	Reason      string
+}
+
+// String returns a human readable description of the mismatch.
+func (mismatch AccessMismatch) String() string {
+	expected := "allowed"
+	actual := "denied"
+	if !mismatch.Expectation.Allowed {
+		expected, actual = actual, expected
+	}
+	msg := fmt.Sprintf("%s: expected %s, but was %s", mismatch.Expectation, expected, actual)
+	if mismatch.Reason != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, mismatch.Reason)
+	}
+	return msg
+}
+
+// AccessCheckFunc is a function that determines whether or not the subject under test can perform the action described
+// by the expectation. It returns the reason reported by the authorizer along with the decision.
+type AccessCheckFunc func(expectation AccessExpectation) (allowed bool, reason string, err error)
+
+// ServiceAccountUserName returns the user name that Kubernetes assigns to the ServiceAccount.
+func ServiceAccountUserName(namespace string, serviceAccountName string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName)
+}
+
+// ServiceAccountGroups returns the groups that Kubernetes assigns to ServiceAccounts in the given namespace.
+func ServiceAccountGroups(namespace string) []string {
+	return []string{
+		"system:serviceaccounts",
+		fmt.Sprintf("system:serviceaccounts:%s", namespace),
+		"system:authenticated",
+	}
+}
+
+// AssertServiceAccountAccess checks all the expectations for the given ServiceAccount by impersonating it and running
+// a SelfSubjectAccessReview for each one. This will fail the test with a single error listing every mismatch. Note
+// that the credentials in the kubectl options must be allowed to impersonate ServiceAccounts.
+func AssertServiceAccountAccess(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	namespace string,
+	serviceAccountName string,
+	expectations []AccessExpectation,
+) {
+	require.NoError(t, AssertServiceAccountAccessE(t, options, namespace, serviceAccountName, expectations))
+}
+
+// AssertServiceAccountAccessE checks all the expectations for the given ServiceAccount by impersonating it and running
+// a SelfSubjectAccessReview for each one. Returns an AccessMismatchesError listing every mismatch.
+func AssertServiceAccountAccessE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	namespace string,
+	serviceAccountName string,
+	expectations []AccessExpectation,
+) error {
+	userName := ServiceAccountUserName(namespace, serviceAccountName)
+	clientset, err := GetImpersonatingClientsetE(t, options, userName, ServiceAccountGroups(namespace))
+	if err != nil {
+		return err
+	}
+	return CheckAccessE(userName, expectations, SelfSubjectAccessCheck(clientset))
+}
+
+// AssertSubjectAccess checks all the expectations for the given user and groups by running a SubjectAccessReview for
+// each one. Unlike AssertServiceAccountAccess, this does not require impersonation rights, but the credentials in the
+// kubectl options must be allowed to create SubjectAccessReviews. This will fail the test with a single error listing
+// every mismatch.
+func AssertSubjectAccess(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	userName string,
+	groups []string,
+	expectations []AccessExpectation,
+) {
+	require.NoError(t, AssertSubjectAccessE(t, options, userName, groups, expectations))
+}
+
+// AssertSubjectAccessE checks all the expectations for the given user and groups by running a SubjectAccessReview for
+// each one. Returns an AccessMismatchesError listing every mismatch.
+func AssertSubjectAccessE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	userName string,
+	groups []string,
+	expectations []AccessExpectation,
+) error {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return err
+	}
+	return CheckAccessE(userName, expectations, SubjectAccessCheck(clientset, userName, groups))
+}
+
+// CheckAccessE runs the access check for every expectation, and returns an AccessMismatchesError listing all the
+// expectations that did not match. Unlike a failed assertion, this does not stop at the first mismatch. The subject is
+// only used in the error message.
+func CheckAccessE(subject string, expectations []AccessExpectation, checkAccess AccessCheckFunc) error {
+	mismatches := []AccessMismatch{}
+	for _, expectation := range expectations {
+		allowed, reason, err := checkAccess(expectation)
+		if err != nil {
+			return err
+		}
+		if allowed != expectation.Allowed {
+			mismatches = append(mismatches, AccessMismatch{Expectation: expectation, Reason: reason})
+		}
+	}
+	if len(mismatches) > 0 {
+		return AccessMismatchesError{Subject: subject, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// SelfSubjectAccessCheck returns an AccessCheckFunc that runs a SelfSubjectAccessReview with the given client, checking
+// the access of whoever the client authenticates as.
+func SelfSubjectAccessCheck(clientset kubernetes.Interface) AccessCheckFunc {
+	return func(expectation AccessExpectation) (bool, string, error) {
+		review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(expectation.SelfSubjectAccessReview())
+		if err != nil {
+			return false, "", err
+		}
+		return review.Status.Allowed, review.Status.Reason, nil
+	}
+}
+
+// SubjectAccessCheck returns an AccessCheckFunc that runs a SubjectAccessReview with the given client, checking the
+// access of the given user and groups.
+func SubjectAccessCheck(clientset kubernetes.Interface, userName string, groups []string) AccessCheckFunc {
+	return func(expectation AccessExpectation) (bool, string, error) {
+		review := &authv1.SubjectAccessReview{
+			Spec: authv1.SubjectAccessReviewSpec{
+				ResourceAttributes: expectation.ResourceAttributes(),
+				User:               userName,
+				Groups:             groups,
+			},
+		}
+		review, err := clientset.AuthorizationV1().SubjectAccessReviews().Create(review)
+		if err != nil {
+			return false, "", err
+		}
+		return review.Status.Allowed, review.Status.Reason, nil
+	}
+}
+
+// GetImpersonatingClientsetE returns a Kubernetes API client that uses the credentials in the kubectl options to
+// impersonate the given user and groups.
+func GetImpersonatingClientsetE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	userName string,
+	groups []string,
+) (*kubernetes.Clientset, error) {
+	kubeConfigPath, err := options.GetConfigPath(t)
+	if err != nil {
+		return nil, err
+	}
+	config, err := k8s.LoadApiClientConfigE(kubeConfigPath, options.ContextName)
+	if err != nil {
+		return nil, err
+	}
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: userName,
+		Groups:   groups,
+	}
+	return kubernetes.NewForConfig(config)
+}
@@ -0,0 +1,109 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "rbac-test"
+
+// newFakeAuthorizingClientset returns a fake clientset that answers access reviews by allowing any action in
+// testNamespace, and only the read verbs everywhere else. The last reviewed spec is stored in lastSpec.
+func newFakeAuthorizingClientset(lastSpec *authv1.SubjectAccessReviewSpec) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	authorize := func(attributes *authv1.ResourceAttributes) authv1.SubjectAccessReviewStatus {
+		if attributes.Namespace == testNamespace {
+			return authv1.SubjectAccessReviewStatus{Allowed: true, Reason: "test namespace"}
+		}
+		switch attributes.Verb {
+		case "get", "list", "watch":
+			return authv1.SubjectAccessReviewStatus{Allowed: true, Reason: "read only"}
+		}
+		return authv1.SubjectAccessReviewStatus{Allowed: false}
+	}
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authv1.SelfSubjectAccessReview)
+		review.Status = authorize(review.Spec.ResourceAttributes)
+		return true, review, nil
+	})
+	clientset.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authv1.SubjectAccessReview)
+		*lastSpec = review.Spec
+		review.Status = authorize(review.Spec.ResourceAttributes)
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestCheckAccessWithSelfSubjectAccessReviewPasses(t *testing.T) {
+	t.Parallel()
+
+	clientset := newFakeAuthorizingClientset(&authv1.SubjectAccessReviewSpec{})
+	expectations := []AccessExpectation{
+		{Verb: "create", Resource: "pods", Namespace: testNamespace, Allowed: true},
+		{Verb: "list", Resource: "pods", Namespace: "default", Allowed: true},
+		{Verb: "create", Resource: "pods", Namespace: "default", Allowed: false},
+	}
+	assert.NoError(t, CheckAccessE("me", expectations, SelfSubjectAccessCheck(clientset)))
+}
+
+func TestCheckAccessReportsEveryMismatch(t *testing.T) {
+	t.Parallel()
+
+	clientset := newFakeAuthorizingClientset(&authv1.SubjectAccessReviewSpec{})
+	expectations := []AccessExpectation{
+		{Verb: "create", Resource: "pods", Namespace: testNamespace, Allowed: false},
+		{Verb: "list", Resource: "pods", Namespace: "default", Allowed: true},
+		{Verb: "delete", Group: "apps", Resource: "deployments", Namespace: "default", Allowed: true},
+	}
+	err := CheckAccessE("me", expectations, SelfSubjectAccessCheck(clientset))
+	require.Error(t, err)
+	mismatchesErr, isMismatchesErr := err.(AccessMismatchesError)
+	require.True(t, isMismatchesErr, "Expected AccessMismatchesError, got %T: %s", err, err)
+	require.Equal(t, 2, len(mismatchesErr.Mismatches))
+	assert.Equal(t, expectations[0], mismatchesErr.Mismatches[0].Expectation)
+	assert.Equal(t, "test namespace", mismatchesErr.Mismatches[0].Reason)
+	assert.Equal(t, expectations[2], mismatchesErr.Mismatches[1].Expectation)
+	assert.Contains(t, err.Error(), "create pods in namespace rbac-test: expected denied, but was allowed")
+	assert.Contains(t, err.Error(), "delete deployments.apps in namespace default: expected allowed, but was denied")
+}
+
+func TestSubjectAccessCheckSendsUserAndGroups(t *testing.T) {
+	t.Parallel()
+
+	lastSpec := authv1.SubjectAccessReviewSpec{}
+	clientset := newFakeAuthorizingClientset(&lastSpec)
+	userName := ServiceAccountUserName(testNamespace, "deployer")
+	groups := ServiceAccountGroups(testNamespace)
+	expectations := []AccessExpectation{
+		{Verb: "get", Resource: "secrets", Name: "foo", Namespace: "kube-system", Allowed: true},
+	}
+	require.NoError(t, CheckAccessE(userName, expectations, SubjectAccessCheck(clientset, userName, groups)))
+	assert.Equal(t, "system:serviceaccount:rbac-test:deployer", lastSpec.User)
+	assert.Equal(t, groups, lastSpec.Groups)
+	assert.Equal(t, "foo", lastSpec.ResourceAttributes.Name)
+}
+
+func TestAccessExpectationString(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		expectation AccessExpectation
+		expected    string
+	}{
+		{AccessExpectation{Verb: "list", Resource: "pods", Namespace: "foo"}, "list pods in namespace foo"},
+		{
+			AccessExpectation{Verb: "create", Group: "apps", Resource: "deployments", Subresource: "scale", Name: "tiller"},
+			"create deployments.apps/scale/tiller in namespace (cluster)",
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, testCase.expectation.String())
+	}
+}
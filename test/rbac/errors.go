@@ -0,0 +1,44 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccessMismatchesError is returned when the result of one or more access reviews do not match the expectation.
+type AccessMismatchesError struct {
+	Subject    string
+	Mismatches []AccessMismatch
+}
+
+func (err AccessMismatchesError) Error() string {
+	lines := []string{}
+	for _, mismatch := range err.Mismatches {
+		lines = append(lines, "\t"+mismatch.String())
+	}
+	return fmt.Sprintf(
+		"%d access review(s) for %s did not match the expectation:\n%s",
+		len(err.Mismatches),
+		err.Subject,
+		strings.Join(lines, "\n"),
+	)
+}
+
+// ConflictingExpectationError is returned when an expectation matrix both allows and denies the same verb on a
+// resource.
+type ConflictingExpectationError struct {
+	Role     string
+	Group    string
+	Resource string
+	Verb     string
+}
+
+func (err ConflictingExpectationError) Error() string {
+	return fmt.Sprintf(
+		"Expectations for role %s both allow and deny verb %s on resource %s in group %q",
+		err.Role,
+		err.Verb,
+		err.Resource,
+		err.Group,
+	)
+}
@@ -0,0 +1,143 @@
+package rbac
+
+import (
+	"io/ioutil"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// ExpectationMatrix is the expected permissions of a set of roles, keyed by a name for the role (e.g the name of the
+// terraform output that contains the role name). It can be loaded from YAML or JSON.
+type ExpectationMatrix map[string][]ResourceExpectation
+
+// ResourceExpectation lists the verbs that a role must allow and must deny on a resource of an API group. The core API
+// group is the empty string.
+type ResourceExpectation struct {
+	Group    string   `json:"group"`
+	Resource string   `json:"resource"`
+	Allow    []string `json:"allow"`
+	Deny     []string `json:"deny"`
+}
+
+// LoadExpectationMatrixE loads an ExpectationMatrix from the YAML or JSON file at the given path, and validates that no
+// verb is both allowed and denied on the same resource.
+func LoadExpectationMatrixE(path string) (ExpectationMatrix, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExpectationMatrixE(data)
+}
+
+// ParseExpectationMatrixE parses an ExpectationMatrix from YAML or JSON, and validates that no verb is both allowed and
+// denied on the same resource.
+func ParseExpectationMatrixE(data []byte) (ExpectationMatrix, error) {
+	var matrix ExpectationMatrix
+	if err := yaml.Unmarshal(data, &matrix); err != nil {
+		return nil, err
+	}
+	for role, resourceExpectations := range matrix {
+		for _, resourceExpectation := range resourceExpectations {
+			allowed := map[string]bool{}
+			for _, verb := range resourceExpectation.Allow {
+				allowed[verb] = true
+			}
+			for _, verb := range resourceExpectation.Deny {
+				if allowed[verb] {
+					return nil, ConflictingExpectationError{
+						Role:     role,
+						Group:    resourceExpectation.Group,
+						Resource: resourceExpectation.Resource,
+						Verb:     verb,
+					}
+				}
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// Roles returns the names of the roles in the matrix, in sorted order.
+func (matrix ExpectationMatrix) Roles() []string {
+	roles := []string{}
+	for role := range matrix {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// AccessExpectations expands the matrix entries for the given role into a list of access expectations in the given
+// namespace, one per verb.
+func (matrix ExpectationMatrix) AccessExpectations(role string, namespace string) []AccessExpectation {
+	expectations := []AccessExpectation{}
+	for _, resourceExpectation := range matrix[role] {
+		for _, verb := range resourceExpectation.Allow {
+			expectations = append(expectations, resourceExpectation.accessExpectation(verb, namespace, true))
+		}
+		for _, verb := range resourceExpectation.Deny {
+			expectations = append(expectations, resourceExpectation.accessExpectation(verb, namespace, false))
+		}
+	}
+	return expectations
+}
+
+// DeniedAccessExpectations expands the matrix entries for the given role into a list of access expectations in the
+// given namespace, where every verb is expected to be denied. This is useful for checking that a namespaced role does
+// not grant any permissions outside of its namespace.
+func (matrix ExpectationMatrix) DeniedAccessExpectations(role string, namespace string) []AccessExpectation {
+	expectations := []AccessExpectation{}
+	for _, expectation := range matrix.AccessExpectations(role, namespace) {
+		expectation.Allowed = false
+		expectations = append(expectations, expectation)
+	}
+	return expectations
+}
+
+func (resourceExpectation ResourceExpectation) accessExpectation(
+	verb string,
+	namespace string,
+	allowed bool,
+) AccessExpectation {
+	return AccessExpectation{
+		Verb:      verb,
+		Group:     resourceExpectation.Group,
+		Resource:  resourceExpectation.Resource,
+		Namespace: namespace,
+		Allowed:   allowed,
+	}
+}
+
+// PolicyRulesAccessCheck returns an AccessCheckFunc that evaluates the access against the given RBAC rules locally,
+// using the same matching semantics as the Kubernetes RBAC authorizer for resource requests. This can be used to check
+// an expectation matrix against rules without a cluster (e.g rules read from a terraform plan). The namespace of the
+// expectation is ignored, as the rules are assumed to be bound in the namespace under test.
+func PolicyRulesAccessCheck(rules []rbacv1.PolicyRule) AccessCheckFunc {
+	return func(expectation AccessExpectation) (bool, string, error) {
+		resource := expectation.Resource
+		if expectation.Subresource != "" {
+			resource = resource + "/" + expectation.Subresource
+		}
+		for _, rule := range rules {
+			if matchesRule(rule.APIGroups, expectation.Group) &&
+				matchesRule(rule.Resources, resource) &&
+				matchesRule(rule.Verbs, expectation.Verb) &&
+				(len(rule.ResourceNames) == 0 || matchesRule(rule.ResourceNames, expectation.Name)) {
+				return true, "", nil
+			}
+		}
+		return false, "", nil
+	}
+}
+
+// matchesRule returns true if the list of values in an RBAC rule contains the requested value or the wildcard.
+func matchesRule(ruleValues []string, requested string) bool {
+	for _, value := range ruleValues {
+		if value == rbacv1.ResourceAll || value == requested {
+			return true
+		}
+	}
+	return false
+}
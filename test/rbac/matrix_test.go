@@ -0,0 +1,87 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+const namespaceRolesMatrixPath = "../kubefixtures/namespace-roles-rbac-matrix.yml"
+
+func TestLoadNamespaceRolesMatrix(t *testing.T) {
+	t.Parallel()
+
+	matrix, err := LoadExpectationMatrixE(namespaceRolesMatrixPath)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{
+			"rbac_access_all_role",
+			"rbac_access_read_only_role",
+			"rbac_tiller_metadata_access_role",
+			"rbac_tiller_resource_access_role",
+		},
+		matrix.Roles(),
+	)
+	for _, role := range matrix.Roles() {
+		for _, expectation := range matrix.DeniedAccessExpectations(role, "default") {
+			assert.False(t, expectation.Allowed)
+			assert.Equal(t, "default", expectation.Namespace)
+		}
+	}
+}
+
+func TestParseMatrixExpandsVerbs(t *testing.T) {
+	t.Parallel()
+
+	matrix, err := ParseExpectationMatrixE([]byte(`
+reader:
+  - group: apps
+    resource: deployments
+    allow: [get, list]
+    deny: [delete]
+`))
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]AccessExpectation{
+			{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "foo", Allowed: true},
+			{Verb: "list", Group: "apps", Resource: "deployments", Namespace: "foo", Allowed: true},
+			{Verb: "delete", Group: "apps", Resource: "deployments", Namespace: "foo", Allowed: false},
+		},
+		matrix.AccessExpectations("reader", "foo"),
+	)
+}
+
+func TestParseMatrixRejectsConflictingVerbs(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseExpectationMatrixE([]byte(`{"reader": [{"group": "", "resource": "pods", "allow": ["get"], "deny": ["get"]}]}`))
+	require.Error(t, err)
+	_, isConflictErr := err.(ConflictingExpectationError)
+	assert.True(t, isConflictErr, "Expected ConflictingExpectationError, got %T: %s", err, err)
+}
+
+func TestPolicyRulesAccessCheck(t *testing.T) {
+	t.Parallel()
+
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{"", "apps"}, Resources: []string{"secrets"}, Verbs: []string{"*"}},
+		{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"foo"}, Verbs: []string{"get"}},
+	}
+	expectations := []AccessExpectation{
+		{Verb: "delete", Resource: "secrets", Allowed: true},
+		{Verb: "create", Group: "apps", Resource: "secrets", Allowed: true},
+		{Verb: "get", Group: "batch", Resource: "secrets", Allowed: false},
+		{Verb: "get", Resource: "pods", Allowed: false},
+		{Verb: "get", Group: "policy", Resource: "poddisruptionbudgets", Allowed: true},
+		{Verb: "delete", Group: "policy", Resource: "poddisruptionbudgets", Allowed: false},
+		{Verb: "get", Resource: "configmaps", Name: "foo", Allowed: true},
+		{Verb: "get", Resource: "configmaps", Name: "bar", Allowed: false},
+		{Verb: "list", Resource: "configmaps", Allowed: false},
+	}
+	assert.NoError(t, CheckAccessE("rules", expectations, PolicyRulesAccessCheck(rules)))
+}
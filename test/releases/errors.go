@@ -0,0 +1,62 @@
+package releases
+
+import (
+	"fmt"
+)
+
+// MissingReleaseDataError is returned when a Secret that is labeled as a Tiller release record has no release data.
+type MissingReleaseDataError struct {
+	Namespace  string
+	SecretName string
+}
+
+func (err MissingReleaseDataError) Error() string {
+	return fmt.Sprintf(
+		"Secret %s in namespace %s has no %s data to decode the release record from",
+		err.SecretName,
+		err.Namespace,
+		ReleaseDataKey,
+	)
+}
+
+// InvalidReleaseRecordError is returned when the release data of a Secret can not be decoded.
+type InvalidReleaseRecordError struct {
+	Namespace  string
+	SecretName string
+	Underlying error
+}
+
+func (err InvalidReleaseRecordError) Error() string {
+	return fmt.Sprintf(
+		"Error decoding the release record in Secret %s in namespace %s: %s",
+		err.SecretName,
+		err.Namespace,
+		err.Underlying,
+	)
+}
+
+// ReleaseNotFoundError is returned when there are no records of a release.
+type ReleaseNotFoundError struct {
+	ReleaseName string
+}
+
+func (err ReleaseNotFoundError) Error() string {
+	return fmt.Sprintf("Found no records of release %s", err.ReleaseName)
+}
+
+// HistoryMaxExceededError is returned when Tiller kept more revisions of a release than the configured history max.
+type HistoryMaxExceededError struct {
+	ReleaseName string
+	HistoryMax  int
+	Versions    []int32
+}
+
+func (err HistoryMaxExceededError) Error() string {
+	return fmt.Sprintf(
+		"Found %d revisions %v of release %s, expected at most %d",
+		len(err.Versions),
+		err.Versions,
+		err.ReleaseName,
+		err.HistoryMax,
+	)
+}
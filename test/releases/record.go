@@ -0,0 +1,350 @@
+package releases
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// gzipMagic is the header of gzipped data. Tiller compresses the release records before storing them, but older
+// versions stored the plain protobuf, so records without the header are decoded as is.
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// DecodeRecordE decodes a release record in the format that Tiller stores in the release key of the Secret: a base64
+// encoded, gzipped, protobuf hapi.release.Release message.
+func DecodeRecordE(data []byte) (Release, error) {
+	record, err := DecodeFullRecordE(data)
+	if err != nil {
+		return Release{}, err
+	}
+	return record.Release, nil
+}
+
+// DecodeFullRecordE decodes a release record in the same format as DecodeRecordE, but keeps the rendered manifest,
+// the values, the hooks, and the chart of the release as well.
+func DecodeFullRecordE(data []byte) (Record, error) {
+	message, err := decodeRecordE(data)
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{
+		Release: Release{
+			Name:      message.Name,
+			Version:   message.Version,
+			Namespace: message.Namespace,
+			Status:    statusCodeNames[0],
+		},
+		Manifest: message.Manifest,
+	}
+	if message.Info != nil {
+		if message.Info.Status != nil {
+			if name, hasName := statusCodeNames[message.Info.Status.Code]; hasName {
+				record.Status = name
+			}
+			record.Notes = message.Info.Status.Notes
+		}
+		if record.FirstDeployed, err = timestampOrZeroE(message.Info.FirstDeployed); err != nil {
+			return Record{}, err
+		}
+		if record.LastDeployed, err = timestampOrZeroE(message.Info.LastDeployed); err != nil {
+			return Record{}, err
+		}
+		if record.Deleted, err = timestampOrZeroE(message.Info.Deleted); err != nil {
+			return Record{}, err
+		}
+		record.Description = message.Info.Description
+	}
+	if message.Config != nil {
+		record.Config = message.Config.Raw
+	}
+	for _, hook := range message.Hooks {
+		recordHook, err := newHookE(hook)
+		if err != nil {
+			return Record{}, err
+		}
+		record.Hooks = append(record.Hooks, recordHook)
+	}
+	if message.Chart != nil {
+		record.Chart = newChart(message.Chart)
+		record.ChartName = record.Chart.Metadata.Name
+		record.ChartVersion = record.Chart.Metadata.Version
+	}
+	return record, nil
+}
+
+// timestampOrZeroE converts the protobuf timestamp to a time, or returns the zero time if the timestamp is not set.
+func timestampOrZeroE(value *timestamp.Timestamp) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, nil
+	}
+	return ptypes.Timestamp(value)
+}
+
+func newHookE(message *hookMessage) (Hook, error) {
+	hook := Hook{
+		Name:     message.Name,
+		Kind:     message.Kind,
+		Path:     message.Path,
+		Manifest: message.Manifest,
+		Weight:   message.Weight,
+	}
+	for _, event := range message.Events {
+		hook.Events = append(hook.Events, hookEventNames[event])
+	}
+	for _, policy := range message.DeletePolicies {
+		hook.DeletePolicies = append(hook.DeletePolicies, hookDeletePolicyNames[policy])
+	}
+	lastRun, err := timestampOrZeroE(message.LastRun)
+	if err != nil {
+		return Hook{}, err
+	}
+	hook.LastRun = lastRun
+	return hook, nil
+}
+
+func newChart(message *chart) Chart {
+	recordChart := Chart{}
+	if message.Metadata != nil {
+		metadata := message.Metadata
+		recordChart.Metadata = ChartMetadata{
+			Name:        metadata.Name,
+			Home:        metadata.Home,
+			Sources:     metadata.Sources,
+			Version:     metadata.Version,
+			Description: metadata.Description,
+			Keywords:    metadata.Keywords,
+			Icon:        metadata.Icon,
+			APIVersion:  metadata.APIVersion,
+			Condition:   metadata.Condition,
+			Tags:        metadata.Tags,
+			AppVersion:  metadata.AppVersion,
+			Deprecated:  metadata.Deprecated,
+			Annotations: metadata.Annotations,
+			KubeVersion: metadata.KubeVersion,
+		}
+		for _, maintainer := range metadata.Maintainers {
+			recordChart.Metadata.Maintainers = append(recordChart.Metadata.Maintainers, Maintainer{
+				Name:  maintainer.Name,
+				Email: maintainer.Email,
+				URL:   maintainer.URL,
+			})
+		}
+	}
+	for _, template := range message.Templates {
+		recordChart.Templates = append(recordChart.Templates, File{Name: template.Name, Data: template.Data})
+	}
+	if message.Values != nil {
+		recordChart.Values = message.Values.Raw
+	}
+	// Tiller stores the other files of the chart as protobuf Any messages, with the path of the file as the type URL.
+	for _, file := range message.Files {
+		recordChart.Files = append(recordChart.Files, File{Name: file.TypeUrl, Data: file.Value})
+	}
+	return recordChart
+}
+
+// decodeRecordE decodes the base64 and gzip layers of a release record, and unmarshals the protobuf message.
+func decodeRecordE(data []byte) (*releaseMessage, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(decoded, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		decoded, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	message := &releaseMessage{}
+	if err := proto.Unmarshal(decoded, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// encodeRecordE encodes a release message in the same format as Tiller. This is the inverse of decodeRecordE, and is
+// used to build fixtures.
+func encodeRecordE(message *releaseMessage) ([]byte, error) {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(buffer.Bytes())), nil
+}
+
+// The following are the parts of the Tiller release messages (hapi.release.Release, hapi.release.Info,
+// hapi.release.Status, hapi.release.Hook, hapi.chart.Chart, hapi.chart.Config, hapi.chart.Template, and
+// hapi.chart.Metadata) that are needed to audit and migrate the releases. These are defined here so that we don't need
+// to depend on the whole of helm. The fields that are left out, such as the test suite results and the subcharts, are
+// skipped when decoding.
+
+type releaseMessage struct {
+	Name      string         `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Info      *infoMessage   `protobuf:"bytes,2,opt,name=info" json:"info,omitempty"`
+	Chart     *chart         `protobuf:"bytes,3,opt,name=chart" json:"chart,omitempty"`
+	Config    *config        `protobuf:"bytes,4,opt,name=config" json:"config,omitempty"`
+	Manifest  string         `protobuf:"bytes,5,opt,name=manifest" json:"manifest,omitempty"`
+	Hooks     []*hookMessage `protobuf:"bytes,6,rep,name=hooks" json:"hooks,omitempty"`
+	Version   int32          `protobuf:"varint,7,opt,name=version" json:"version,omitempty"`
+	Namespace string         `protobuf:"bytes,8,opt,name=namespace" json:"namespace,omitempty"`
+}
+
+func (m *releaseMessage) Reset()         { *m = releaseMessage{} }
+func (m *releaseMessage) String() string { return proto.CompactTextString(m) }
+func (*releaseMessage) ProtoMessage()    {}
+
+type infoMessage struct {
+	Status        *status              `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	FirstDeployed *timestamp.Timestamp `protobuf:"bytes,2,opt,name=first_deployed,json=firstDeployed" json:"first_deployed,omitempty"`
+	LastDeployed  *timestamp.Timestamp `protobuf:"bytes,3,opt,name=last_deployed,json=lastDeployed" json:"last_deployed,omitempty"`
+	Deleted       *timestamp.Timestamp `protobuf:"bytes,4,opt,name=deleted" json:"deleted,omitempty"`
+	Description   string               `protobuf:"bytes,5,opt,name=Description" json:"Description,omitempty"`
+}
+
+func (m *infoMessage) Reset()         { *m = infoMessage{} }
+func (m *infoMessage) String() string { return proto.CompactTextString(m) }
+func (*infoMessage) ProtoMessage()    {}
+
+type status struct {
+	Code  int32  `protobuf:"varint,1,opt,name=code" json:"code,omitempty"`
+	Notes string `protobuf:"bytes,4,opt,name=notes" json:"notes,omitempty"`
+}
+
+func (m *status) Reset()         { *m = status{} }
+func (m *status) String() string { return proto.CompactTextString(m) }
+func (*status) ProtoMessage()    {}
+
+type hookMessage struct {
+	Name           string               `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Kind           string               `protobuf:"bytes,2,opt,name=kind" json:"kind,omitempty"`
+	Path           string               `protobuf:"bytes,3,opt,name=path" json:"path,omitempty"`
+	Manifest       string               `protobuf:"bytes,4,opt,name=manifest" json:"manifest,omitempty"`
+	Events         []int32              `protobuf:"varint,5,rep,packed,name=events" json:"events,omitempty"`
+	LastRun        *timestamp.Timestamp `protobuf:"bytes,6,opt,name=last_run,json=lastRun" json:"last_run,omitempty"`
+	Weight         int32                `protobuf:"varint,7,opt,name=weight" json:"weight,omitempty"`
+	DeletePolicies []int32              `protobuf:"varint,8,rep,packed,name=delete_policies" json:"delete_policies,omitempty"`
+}
+
+func (m *hookMessage) Reset()         { *m = hookMessage{} }
+func (m *hookMessage) String() string { return proto.CompactTextString(m) }
+func (*hookMessage) ProtoMessage()    {}
+
+type chart struct {
+	Metadata  *chartMetadata `protobuf:"bytes,1,opt,name=metadata" json:"metadata,omitempty"`
+	Templates []*template    `protobuf:"bytes,2,rep,name=templates" json:"templates,omitempty"`
+	Values    *config        `protobuf:"bytes,4,opt,name=values" json:"values,omitempty"`
+	Files     []*any.Any     `protobuf:"bytes,5,rep,name=files" json:"files,omitempty"`
+}
+
+func (m *chart) Reset()         { *m = chart{} }
+func (m *chart) String() string { return proto.CompactTextString(m) }
+func (*chart) ProtoMessage()    {}
+
+type config struct {
+	Raw string `protobuf:"bytes,1,opt,name=raw" json:"raw,omitempty"`
+}
+
+func (m *config) Reset()         { *m = config{} }
+func (m *config) String() string { return proto.CompactTextString(m) }
+func (*config) ProtoMessage()    {}
+
+type template struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *template) Reset()         { *m = template{} }
+func (m *template) String() string { return proto.CompactTextString(m) }
+func (*template) ProtoMessage()    {}
+
+type chartMetadata struct {
+	Name        string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Home        string            `protobuf:"bytes,2,opt,name=home" json:"home,omitempty"`
+	Sources     []string          `protobuf:"bytes,3,rep,name=sources" json:"sources,omitempty"`
+	Version     string            `protobuf:"bytes,4,opt,name=version" json:"version,omitempty"`
+	Description string            `protobuf:"bytes,5,opt,name=description" json:"description,omitempty"`
+	Keywords    []string          `protobuf:"bytes,6,rep,name=keywords" json:"keywords,omitempty"`
+	Maintainers []*maintainer     `protobuf:"bytes,7,rep,name=maintainers" json:"maintainers,omitempty"`
+	Icon        string            `protobuf:"bytes,9,opt,name=icon" json:"icon,omitempty"`
+	APIVersion  string            `protobuf:"bytes,10,opt,name=apiVersion" json:"apiVersion,omitempty"`
+	Condition   string            `protobuf:"bytes,11,opt,name=condition" json:"condition,omitempty"`
+	Tags        string            `protobuf:"bytes,12,opt,name=tags" json:"tags,omitempty"`
+	AppVersion  string            `protobuf:"bytes,13,opt,name=appVersion" json:"appVersion,omitempty"`
+	Deprecated  bool              `protobuf:"varint,14,opt,name=deprecated" json:"deprecated,omitempty"`
+	Annotations map[string]string `protobuf:"bytes,16,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	KubeVersion string            `protobuf:"bytes,17,opt,name=kubeVersion" json:"kubeVersion,omitempty"`
+}
+
+func (m *chartMetadata) Reset()         { *m = chartMetadata{} }
+func (m *chartMetadata) String() string { return proto.CompactTextString(m) }
+func (*chartMetadata) ProtoMessage()    {}
+
+type maintainer struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Email string `protobuf:"bytes,2,opt,name=email" json:"email,omitempty"`
+	URL   string `protobuf:"bytes,3,opt,name=url" json:"url,omitempty"`
+}
+
+func (m *maintainer) Reset()         { *m = maintainer{} }
+func (m *maintainer) String() string { return proto.CompactTextString(m) }
+func (*maintainer) ProtoMessage()    {}
+
+// statusCodeNames maps the hapi.release.Status_Code enum to the names that helm shows.
+var statusCodeNames = map[int32]string{
+	0: "UNKNOWN",
+	1: "DEPLOYED",
+	2: "DELETED",
+	3: "SUPERSEDED",
+	4: "FAILED",
+	5: "DELETING",
+	6: "PENDING_INSTALL",
+	7: "PENDING_UPGRADE",
+	8: "PENDING_ROLLBACK",
+}
+
+// hookEventNames maps the hapi.release.Hook_Event enum to the names of the values.
+var hookEventNames = map[int32]string{
+	0:  "UNKNOWN",
+	1:  "PRE_INSTALL",
+	2:  "POST_INSTALL",
+	3:  "PRE_DELETE",
+	4:  "POST_DELETE",
+	5:  "PRE_UPGRADE",
+	6:  "POST_UPGRADE",
+	7:  "PRE_ROLLBACK",
+	8:  "POST_ROLLBACK",
+	9:  "RELEASE_TEST_SUCCESS",
+	10: "RELEASE_TEST_FAILURE",
+	11: "CRD_INSTALL",
+}
+
+// hookDeletePolicyNames maps the hapi.release.Hook_DeletePolicy enum to the names of the values.
+var hookDeletePolicyNames = map[int32]string{
+	0: "SUCCEEDED",
+	1: "FAILED",
+	2: "BEFORE_HOOK_CREATION",
+}
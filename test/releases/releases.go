@@ -0,0 +1,239 @@
+package releases
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// OwnerLabelSelector matches the Secrets (or ConfigMaps) that Tiller stores the release records in.
+	OwnerLabelSelector = "OWNER=TILLER"
+
+	// ReleaseDataKey is the key of the Secret data that holds the encoded release record.
+	ReleaseDataKey = "release"
+
+	// The statuses of a release revision that the tests care about. The latest revision of a release is DEPLOYED, and
+	// all the revisions before it are SUPERSEDED.
+	StatusDeployed   = "DEPLOYED"
+	StatusSuperseded = "SUPERSEDED"
+)
+
+// Release is a single revision of a helm release, decoded from the record that Tiller stores in a Secret.
+type Release struct {
+	SecretName   string
+	Name         string
+	Version      int32
+	Status       string
+	ChartName    string
+	ChartVersion string
+	Namespace    string
+	LastDeployed time.Time
+	Description  string
+}
+
+// Record is the full release record that Tiller stores for a revision of a release. On top of the summary in Release,
+// it has everything that is needed to rebuild the release elsewhere, such as in the Helm 3 storage format.
+type Record struct {
+	Release
+
+	FirstDeployed time.Time
+	Deleted       time.Time
+	Notes         string
+
+	// Config is the raw YAML of the values that were set on the release, on top of the values of the chart.
+	Config   string
+	Manifest string
+	Hooks    []Hook
+	Chart    Chart
+}
+
+// Hook is a hook of a release. The events and delete policies are the names of the Tiller enum values, e.g.
+// PRE_INSTALL and BEFORE_HOOK_CREATION.
+type Hook struct {
+	Name           string
+	Kind           string
+	Path           string
+	Manifest       string
+	Events         []string
+	LastRun        time.Time
+	Weight         int32
+	DeletePolicies []string
+}
+
+// Chart is the chart that a release was installed from, without its subcharts.
+type Chart struct {
+	Metadata  ChartMetadata
+	Templates []File
+
+	// Values is the raw YAML of the values.yaml of the chart.
+	Values string
+	Files  []File
+}
+
+// ChartMetadata is the contents of the Chart.yaml of a chart. The Tiller specific engine and tillerVersion fields are
+// left out.
+type ChartMetadata struct {
+	Name        string
+	Home        string
+	Sources     []string
+	Version     string
+	Description string
+	Keywords    []string
+	Maintainers []Maintainer
+	Icon        string
+	APIVersion  string
+	Condition   string
+	Tags        string
+	AppVersion  string
+	Deprecated  bool
+	Annotations map[string]string
+	KubeVersion string
+}
+
+// Maintainer is a maintainer of a chart.
+type Maintainer struct {
+	Name  string
+	Email string
+	URL   string
+}
+
+// File is a template or other file of a chart, with its path within the chart.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// ListReleases lists the release records stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace. The releases are sorted by name and version. This will fail the test if there is an
+// error.
+func ListReleases(t *testing.T, options *k8s.KubectlOptions) []Release {
+	releases, err := ListReleasesE(t, options)
+	require.NoError(t, err)
+	return releases
+}
+
+// ListReleasesE lists the release records stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace. The releases are sorted by name and version.
+func ListReleasesE(t *testing.T, options *k8s.KubectlOptions) ([]Release, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return ListReleasesFromClientE(clientset, options.Namespace)
+}
+
+// ListReleasesFromClientE lists the release records stored as Secrets in the given namespace using the given client.
+// The releases are sorted by name and version.
+func ListReleasesFromClientE(clientset kubernetes.Interface, namespace string) ([]Release, error) {
+	records, err := ListRecordsFromClientE(clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+	releases := []Release{}
+	for _, record := range records {
+		releases = append(releases, record.Release)
+	}
+	return releases, nil
+}
+
+// ListRecords lists the full release records stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace. The records are sorted by name and version. This will fail the test if there is an
+// error.
+func ListRecords(t *testing.T, options *k8s.KubectlOptions) []Record {
+	records, err := ListRecordsE(t, options)
+	require.NoError(t, err)
+	return records
+}
+
+// ListRecordsE lists the full release records stored as Secrets in the namespace of the provided KubectlOptions, which
+// should be the Tiller namespace. The records are sorted by name and version.
+func ListRecordsE(t *testing.T, options *k8s.KubectlOptions) ([]Record, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return ListRecordsFromClientE(clientset, options.Namespace)
+}
+
+// ListRecordsFromClientE lists the full release records stored as Secrets in the given namespace using the given
+// client. The records are sorted by name and version.
+func ListRecordsFromClientE(clientset kubernetes.Interface, namespace string) ([]Record, error) {
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(metav1.ListOptions{LabelSelector: OwnerLabelSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	records := []Record{}
+	for _, secret := range secrets.Items {
+		record, err := decodeSecretE(secret)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Version < records[j].Version
+	})
+	return records, nil
+}
+
+// decodeSecretE decodes the full release record stored in the given Secret.
+func decodeSecretE(secret corev1.Secret) (Record, error) {
+	data, hasData := secret.Data[ReleaseDataKey]
+	if !hasData {
+		return Record{}, MissingReleaseDataError{Namespace: secret.Namespace, SecretName: secret.Name}
+	}
+	record, err := DecodeFullRecordE(data)
+	if err != nil {
+		return Record{}, InvalidReleaseRecordError{Namespace: secret.Namespace, SecretName: secret.Name, Underlying: err}
+	}
+	record.SecretName = secret.Name
+	return record, nil
+}
+
+// FilterByName returns the revisions of the named release, keeping the order of the given releases.
+func FilterByName(releases []Release, releaseName string) []Release {
+	revisions := []Release{}
+	for _, release := range releases {
+		if release.Name == releaseName {
+			revisions = append(revisions, release)
+		}
+	}
+	return revisions
+}
+
+// CheckHistoryMax verifies that Tiller kept at most historyMax revisions of the named release, which matches the
+// tiller_history_max input of the k8s-tiller module. A historyMax of 0 means no limit. This will fail the test if the
+// release has no revisions or too many.
+func CheckHistoryMax(t *testing.T, releases []Release, releaseName string, historyMax int) {
+	require.NoError(t, CheckHistoryMaxE(releases, releaseName, historyMax))
+}
+
+// CheckHistoryMaxE verifies that Tiller kept at most historyMax revisions of the named release, which matches the
+// tiller_history_max input of the k8s-tiller module. A historyMax of 0 means no limit. Returns a ReleaseNotFoundError
+// if the release has no revisions, or a HistoryMaxExceededError if it has too many.
+func CheckHistoryMaxE(releases []Release, releaseName string, historyMax int) error {
+	revisions := FilterByName(releases, releaseName)
+	if len(revisions) == 0 {
+		return ReleaseNotFoundError{ReleaseName: releaseName}
+	}
+	if historyMax == 0 || len(revisions) <= historyMax {
+		return nil
+	}
+
+	versions := []int32{}
+	for _, revision := range revisions {
+		versions = append(versions, revision.Version)
+	}
+	return HistoryMaxExceededError{ReleaseName: releaseName, HistoryMax: historyMax, Versions: versions}
+}
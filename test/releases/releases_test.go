@@ -0,0 +1,198 @@
+package releases
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testNamespace = "tiller"
+
+// recordedReleaseSecretsPath holds release Secrets in the format of Tiller v2.12. See the comment at the top of the
+// file for the releases it contains.
+const recordedReleaseSecretsPath = "../kubefixtures/helm2-release-secrets.yml"
+
+// newTestReleaseSecret returns a Secret holding a release record, labeled the same way as Tiller does.
+func newTestReleaseSecret(t *testing.T, name string, version int32, statusCode int32, lastDeployed time.Time) *corev1.Secret {
+	lastDeployedTimestamp, err := ptypes.TimestampProto(lastDeployed)
+	require.NoError(t, err)
+	data, err := encodeRecordE(&releaseMessage{
+		Name: name,
+		Info: &infoMessage{
+			Status:       &status{Code: statusCode},
+			LastDeployed: lastDeployedTimestamp,
+			Description:  "Upgrade complete",
+		},
+		Chart:     &chart{Metadata: &chartMetadata{Name: "deployment", Version: "0.1.0"}},
+		Version:   version,
+		Namespace: "resources",
+	})
+	require.NoError(t, err)
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.v%d", name, version),
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				"NAME":    name,
+				"OWNER":   "TILLER",
+				"STATUS":  statusCodeNames[statusCode],
+				"VERSION": fmt.Sprint(version),
+			},
+		},
+		Data: map[string][]byte{ReleaseDataKey: data},
+	}
+}
+
+func TestListReleasesDecodesRecords(t *testing.T) {
+	t.Parallel()
+
+	lastDeployed := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	clientset := fake.NewSimpleClientset(
+		newTestReleaseSecret(t, "web", 3, 1, lastDeployed),
+		newTestReleaseSecret(t, "web", 2, 3, lastDeployed),
+		newTestReleaseSecret(t, "api", 1, 4, lastDeployed),
+		// Secrets without the Tiller owner label are not release records.
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tiller-certs", Namespace: testNamespace}},
+	)
+
+	releases, err := ListReleasesFromClientE(clientset, testNamespace)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(releases))
+	assert.Equal(
+		t,
+		Release{
+			SecretName:   "api.v1",
+			Name:         "api",
+			Version:      1,
+			Status:       "FAILED",
+			ChartName:    "deployment",
+			ChartVersion: "0.1.0",
+			Namespace:    "resources",
+			LastDeployed: lastDeployed,
+			Description:  "Upgrade complete",
+		},
+		releases[0],
+	)
+	assert.Equal(t, []int32{2, 3}, []int32{releases[1].Version, releases[2].Version})
+	assert.Equal(t, []string{StatusSuperseded, StatusDeployed}, []string{releases[1].Status, releases[2].Status})
+	assert.Equal(t, 2, len(FilterByName(releases, "web")))
+}
+
+func TestListReleasesReportsInvalidRecords(t *testing.T) {
+	t.Parallel()
+
+	missingData := newTestReleaseSecret(t, "web", 1, 1, time.Now())
+	missingData.Data = nil
+	_, err := ListReleasesFromClientE(fake.NewSimpleClientset(missingData), testNamespace)
+	_, isMissingDataErr := err.(MissingReleaseDataError)
+	assert.True(t, isMissingDataErr, "Expected MissingReleaseDataError, got %T: %s", err, err)
+
+	invalidData := newTestReleaseSecret(t, "web", 1, 1, time.Now())
+	invalidData.Data[ReleaseDataKey] = []byte("not base64!")
+	_, err = ListReleasesFromClientE(fake.NewSimpleClientset(invalidData), testNamespace)
+	invalidRecordErr, isInvalidRecordErr := err.(InvalidReleaseRecordError)
+	require.True(t, isInvalidRecordErr, "Expected InvalidReleaseRecordError, got %T: %s", err, err)
+	assert.Equal(t, "web.v1", invalidRecordErr.SecretName)
+}
+
+func TestCheckHistoryMax(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{
+		{Name: "api", Version: 1},
+		{Name: "web", Version: 4},
+		{Name: "web", Version: 5},
+		{Name: "web", Version: 6},
+	}
+
+	assert.NoError(t, CheckHistoryMaxE(releases, "web", 3))
+	assert.NoError(t, CheckHistoryMaxE(releases, "web", 0))
+
+	err := CheckHistoryMaxE(releases, "web", 2)
+	exceededErr, isExceededErr := err.(HistoryMaxExceededError)
+	require.True(t, isExceededErr, "Expected HistoryMaxExceededError, got %T: %s", err, err)
+	assert.Equal(t, []int32{4, 5, 6}, exceededErr.Versions)
+
+	err = CheckHistoryMaxE(releases, "db", 2)
+	_, isNotFoundErr := err.(ReleaseNotFoundError)
+	assert.True(t, isNotFoundErr, "Expected ReleaseNotFoundError, got %T: %s", err, err)
+}
+
+func TestListRecordsDecodesRecordedTillerSecrets(t *testing.T) {
+	t.Parallel()
+
+	data, err := ioutil.ReadFile(recordedReleaseSecretsPath)
+	require.NoError(t, err)
+	secrets := corev1.SecretList{}
+	require.NoError(t, yaml.Unmarshal(data, &secrets))
+	clientset := fake.NewSimpleClientset(&secrets)
+
+	records, err := ListRecordsFromClientE(clientset, "tiller-ns")
+	require.NoError(t, err)
+	require.Equal(t, 4, len(records))
+	assert.Equal(t, "api", records[0].Name)
+	assert.Equal(t, "FAILED", records[0].Status)
+
+	record := records[3]
+	assert.Equal(
+		t,
+		Release{
+			SecretName:   "web.v3",
+			Name:         "web",
+			Version:      3,
+			Status:       StatusDeployed,
+			ChartName:    "deployment",
+			ChartVersion: "0.1.0",
+			Namespace:    "resources",
+			LastDeployed: time.Date(2019, 3, 1, 14, 0, 0, 0, time.UTC),
+			Description:  "Upgrade complete",
+		},
+		record.Release,
+	)
+	assert.Equal(t, time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC), record.FirstDeployed)
+	assert.True(t, record.Deleted.IsZero())
+	assert.Equal(t, "Release web is running.\n", record.Notes)
+	assert.Equal(t, "replicaCount: 3\nimage:\n  tag: \"3.0\"\n", record.Config)
+	assert.Contains(t, record.Manifest, "replicas: 3")
+	assert.Contains(t, record.Manifest, `image: "k8s.gcr.io/pause:3.0"`)
+
+	require.Equal(t, 1, len(record.Hooks))
+	assert.Equal(t, "web-pre-install", record.Hooks[0].Name)
+	assert.Equal(t, "ConfigMap", record.Hooks[0].Kind)
+	assert.Equal(t, []string{"PRE_INSTALL", "PRE_UPGRADE"}, record.Hooks[0].Events)
+	assert.Equal(t, []string{"BEFORE_HOOK_CREATION"}, record.Hooks[0].DeletePolicies)
+	assert.Equal(t, int32(-5), record.Hooks[0].Weight)
+	assert.Equal(t, record.LastDeployed, record.Hooks[0].LastRun)
+
+	assert.Equal(
+		t,
+		ChartMetadata{
+			Name:        "deployment",
+			Version:     "0.1.0",
+			Description: "A Deployment for the migration tests",
+			Keywords:    []string{"test"},
+			Maintainers: []Maintainer{{Name: "Gruntwork", Email: "info@gruntwork.io"}},
+			APIVersion:  "v1",
+			AppVersion:  "3.1",
+			Annotations: map[string]string{"gruntwork.io/test": "true"},
+		},
+		record.Chart.Metadata,
+	)
+	templateNames := []string{}
+	for _, template := range record.Chart.Templates {
+		templateNames = append(templateNames, template.Name)
+	}
+	assert.Equal(t, []string{"templates/deployment.yaml", "templates/hook.yaml"}, templateNames)
+	assert.Equal(t, "replicaCount: 1\nimage:\n  tag: \"3.1\"\n", record.Chart.Values)
+	assert.Equal(t, []File{{Name: "README.md", Data: []byte("# deployment\n")}}, record.Chart.Files)
+}
@@ -5,21 +5,31 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/cluster"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tfvars"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 )
 
-func createExampleK8SNamespaceTerraformOptions(t *testing.T, uniqueID string, templatePath string) *terraform.Options {
-	terraformVars := map[string]interface{}{"name": strings.ToLower(uniqueID)}
-	terratestOptions := terraform.Options{
-		TerraformDir: templatePath,
-		Vars:         terraformVars,
+func createExampleK8SNamespaceTerraformOptions(
+	t *testing.T,
+	uniqueID string,
+	templatePath string,
+	testCluster *cluster.Cluster,
+	createResources bool,
+) *terraform.Options {
+	terraformVars := tfvars.NamespaceWithServiceAccountExampleVars{
+		Name:                     strings.ToLower(uniqueID),
+		KubectlConfigPath:        testCluster.KubeConfigPath,
+		KubectlConfigContextName: testCluster.ContextName,
+		CreateResources:          tfvars.Bool(createResources),
 	}
-	return &terratestOptions
+	return createTerraformOptionsFromVars(t, templatePath, terraformVars.ToVars())
 }
 
 func createExampleK8STillerKubergruntTerraformOptions(
 	t *testing.T,
 	templatePath string,
+	testCluster *cluster.Cluster,
 	helmHome string,
 	uniqueID string,
 	testServiceAccountName string,
@@ -29,56 +39,95 @@ func createExampleK8STillerKubergruntTerraformOptions(
 	resourceNamespaceName := fmt.Sprintf("%s-resources", strings.ToLower(uniqueID))
 	tillerServiceAccountName := fmt.Sprintf("%s-tiller-service-account", strings.ToLower(uniqueID))
 	encodedTestServiceAccount := fmt.Sprintf("%s/%s", testServiceAccountNamespace, testServiceAccountName)
-	terraformVars := map[string]interface{}{
-		"tiller_version":       "v2.12.2",
-		"tiller_namespace":     tillerNamespaceName,
-		"resource_namespace":   resourceNamespaceName,
-		"service_account_name": tillerServiceAccountName,
-		"tls_subject": map[string]string{
-			"common_name": "tiller",
-			"org":         "Gruntwork",
+	terraformVars := tfvars.KubergruntMinikubeExampleVars{
+		TillerVersion:      "v2.12.2",
+		TillerNamespace:    tillerNamespaceName,
+		ResourceNamespace:  resourceNamespaceName,
+		ServiceAccountName: tillerServiceAccountName,
+		TLSSubject: tfvars.KubergruntTLSSubject{
+			CommonName: "tiller",
+			Org:        "Gruntwork",
 		},
-		"client_tls_subject": map[string]string{
-			"common_name": encodedTestServiceAccount,
-			"org":         "Gruntwork",
+		ClientTLSSubject: tfvars.KubergruntTLSSubject{
+			CommonName: encodedTestServiceAccount,
+			Org:        "Gruntwork",
 		},
-		"helm_client_rbac_service_account": encodedTestServiceAccount,
-		"helm_home":                        helmHome,
+		KubectlConfigPath:            testCluster.KubeConfigPath,
+		KubectlConfigContextName:     testCluster.ContextName,
+		HelmClientRBACServiceAccount: encodedTestServiceAccount,
+		HelmHome:                     helmHome,
 	}
-	terratestOptions := terraform.Options{
-		TerraformDir: templatePath,
-		Vars:         terraformVars,
-	}
-	return &terratestOptions
+	return createTerraformOptionsFromVars(t, templatePath, terraformVars.ToVars())
 }
 
+// createExampleK8STillerTerraformOptions returns the terraform options for the root module, with names derived from the
+// unique ID. Each of the modifyVars functions is applied to the vars in order, so that tests can override the defaults
+// before the vars are checked against the module.
 func createExampleK8STillerTerraformOptions(
 	t *testing.T,
 	templatePath string,
+	testCluster *cluster.Cluster,
 	helmHome string,
 	uniqueID string,
+	modifyVars ...func(*tfvars.RootModuleVars),
 ) *terraform.Options {
 	tillerNamespaceName := fmt.Sprintf("%s-tiller", strings.ToLower(uniqueID))
 	resourceNamespaceName := fmt.Sprintf("%s-resources", strings.ToLower(uniqueID))
 	tillerServiceAccountName := fmt.Sprintf("%s-tiller-service-account", strings.ToLower(uniqueID))
-	terraformVars := map[string]interface{}{
-		"tiller_version":       "v2.12.2",
-		"tiller_namespace":     tillerNamespaceName,
-		"resource_namespace":   resourceNamespaceName,
-		"service_account_name": tillerServiceAccountName,
-		"tls_subject": map[string]string{
-			"common_name":  "tiller",
-			"organization": "Gruntwork",
+	terraformVars := tfvars.RootModuleVars{
+		TillerVersion:      "v2.12.2",
+		TillerNamespace:    tillerNamespaceName,
+		ResourceNamespace:  resourceNamespaceName,
+		ServiceAccountName: tillerServiceAccountName,
+		TLSSubject: tfvars.TLSSubject{
+			CommonName:   "tiller",
+			Organization: "Gruntwork",
 		},
-		"client_tls_subject": map[string]string{
-			"common_name":  "minikube",
-			"organization": "Gruntwork",
+		// The helm client authenticates to Kubernetes as the cluster admin, so we issue the client cert to that user.
+		ClientTLSSubject: tfvars.TLSSubject{
+			CommonName:   testCluster.AdminUserName,
+			Organization: "Gruntwork",
 		},
-		"grant_helm_client_rbac_user": "minikube",
+		KubectlConfigPath:        testCluster.KubeConfigPath,
+		KubectlConfigContextName: testCluster.ContextName,
+		GrantHelmClientRBACUser:  testCluster.AdminUserName,
+	}
+	for _, modify := range modifyVars {
+		modify(&terraformVars)
+	}
+	return createTerraformOptionsFromVars(t, templatePath, terraformVars.ToVars())
+}
+
+// createTerraformOptionsFromVars checks the vars against the variables declared in the module before using them, so
+// that drift between the tests and the module fails fast instead of at apply time.
+func createTerraformOptionsFromVars(
+	t *testing.T,
+	templatePath string,
+	terraformVars map[string]interface{},
+) *terraform.Options {
+	tfvars.CheckVarsAgainstModule(t, templatePath, terraformVars)
+	terratestOptions := terraform.Options{
+		TerraformDir: templatePath,
+		Vars:         terraformVars,
 	}
+	return &terratestOptions
+}
+
+func createPlanOnlyTerraformOptions(
+	t *testing.T,
+	templatePath string,
+	kubeConfigPath string,
+	terraformVars map[string]interface{},
+) *terraform.Options {
 	terratestOptions := terraform.Options{
 		TerraformDir: templatePath,
 		Vars:         terraformVars,
+		// The modules don't configure the kubernetes provider, so we point the provider at the dummy kubeconfig through
+		// the environment.
+		EnvVars: map[string]string{
+			"KUBE_CONFIG": kubeConfigPath,
+			"KUBECONFIG":  kubeConfigPath,
+		},
 	}
 	return &terratestOptions
 }
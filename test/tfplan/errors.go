@@ -0,0 +1,22 @@
+package tfplan
+
+import "fmt"
+
+// ResourceNotFoundError is returned when a resource address is not in the plan.
+type ResourceNotFoundError struct {
+	Address string
+}
+
+func (err ResourceNotFoundError) Error() string {
+	return fmt.Sprintf("Resource %s is not in the plan", err.Address)
+}
+
+// AttributeNotFoundError is returned when an attribute path does not exist in the planned value of a resource.
+type AttributeNotFoundError struct {
+	Address string
+	Path    string
+}
+
+func (err AttributeNotFoundError) Error() string {
+	return fmt.Sprintf("Attribute %s is not in the planned value of %s", err.Path, err.Address)
+}
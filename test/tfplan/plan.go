@@ -0,0 +1,194 @@
+package tfplan
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+// PlanFileName is the name of the plan file that is written into the Terraform working directory.
+const PlanFileName = "terratest.tfplan"
+
+// Plan represents the JSON output of `terraform show -json PLANFILE`. Only the parts of the format that the tests need
+// are decoded. See https://www.terraform.io/docs/internals/json-format.html for the full format.
+type Plan struct {
+	FormatVersion    string                  `json:"format_version"`
+	TerraformVersion string                  `json:"terraform_version"`
+	Variables        map[string]PlanVariable `json:"variables"`
+	ResourceChanges  []ResourceChange        `json:"resource_changes"`
+}
+
+// PlanVariable represents the value of an input variable in the plan.
+type PlanVariable struct {
+	Value interface{} `json:"value"`
+}
+
+// ResourceChange represents the planned change of a single resource instance.
+type ResourceChange struct {
+	Address       string      `json:"address"`
+	ModuleAddress string      `json:"module_address"`
+	Mode          string      `json:"mode"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Index         interface{} `json:"index"`
+	Change        Change      `json:"change"`
+}
+
+// Change represents the before and after values of a resource change. Attributes in the after value that are only
+// known after apply are omitted from After and marked as true in AfterUnknown.
+type Change struct {
+	Actions      []string               `json:"actions"`
+	Before       map[string]interface{} `json:"before"`
+	After        map[string]interface{} `json:"after"`
+	AfterUnknown map[string]interface{} `json:"after_unknown"`
+}
+
+// InitAndPlanAndShow runs terraform init, saves the plan to a file with terraform plan -out, and decodes the output of
+// terraform show -json on the plan file. This will fail the test if there is an error.
+func InitAndPlanAndShow(t *testing.T, options *terraform.Options) *Plan {
+	plan, err := InitAndPlanAndShowE(t, options)
+	require.NoError(t, err)
+	return plan
+}
+
+// InitAndPlanAndShowE runs terraform init, saves the plan to a file with terraform plan -out, and decodes the output of
+// terraform show -json on the plan file.
+func InitAndPlanAndShowE(t *testing.T, options *terraform.Options) (*Plan, error) {
+	if _, err := terraform.InitE(t, options); err != nil {
+		return nil, err
+	}
+
+	planFilePath := filepath.Join(options.TerraformDir, PlanFileName)
+	planArgs := terraform.FormatArgs(options, "plan", "-input=false", "-lock=false", "-out="+planFilePath)
+	if _, err := terraform.RunTerraformCommandE(t, options, planArgs...); err != nil {
+		return nil, err
+	}
+
+	jsonPlan, err := terraform.RunTerraformCommandAndGetStdoutE(t, options, "show", "-json", planFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePlanJSON(jsonPlan)
+}
+
+// ParsePlanJSON decodes the output of terraform show -json into a Plan struct.
+func ParsePlanJSON(jsonPlan string) (*Plan, error) {
+	var plan Plan
+	if err := json.Unmarshal([]byte(jsonPlan), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ResourceChange returns the planned change for the resource instance at the given address (e.g
+// `module.namespace_roles.kubernetes_role.rbac_role_access_all[0]`). This will fail the test if the resource is not in
+// the plan.
+func (plan *Plan) ResourceChange(t *testing.T, address string) *ResourceChange {
+	change, err := plan.ResourceChangeE(address)
+	require.NoError(t, err)
+	return change
+}
+
+// ResourceChangeE returns the planned change for the resource instance at the given address.
+func (plan *Plan) ResourceChangeE(address string) (*ResourceChange, error) {
+	for i := range plan.ResourceChanges {
+		if plan.ResourceChanges[i].Address == address {
+			return &plan.ResourceChanges[i], nil
+		}
+	}
+	return nil, ResourceNotFoundError{Address: address}
+}
+
+// ResourceChangesOfType returns all the planned changes for resources of the given type, across all modules.
+func (plan *Plan) ResourceChangesOfType(resourceType string) []ResourceChange {
+	changes := []ResourceChange{}
+	for _, change := range plan.ResourceChanges {
+		if change.Mode == "managed" && change.Type == resourceType {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// AfterAttribute returns the planned value of the attribute at the given path. The path uses the same dot notation as
+// Terraform interpolations for nested blocks, where list elements are referenced by index (e.g
+// `spec.0.template.0.spec.0.container.0.args`). This will fail the test if the attribute is not in the planned value.
+func (change *ResourceChange) AfterAttribute(t *testing.T, path string) interface{} {
+	value, err := change.AfterAttributeE(path)
+	require.NoError(t, err)
+	return value
+}
+
+// AfterAttributeE returns the planned value of the attribute at the given path.
+func (change *ResourceChange) AfterAttributeE(path string) (interface{}, error) {
+	return lookupAttribute(change.Address, change.Change.After, path)
+}
+
+// AfterAttributeStringList returns the planned value of the attribute at the given path as a list of strings. This
+// will fail the test if the attribute is not in the planned value or is not a list of strings.
+func (change *ResourceChange) AfterAttributeStringList(t *testing.T, path string) []string {
+	value := change.AfterAttribute(t, path)
+	rawList, isList := value.([]interface{})
+	require.True(t, isList, "Attribute %s of %s is not a list: %v", path, change.Address, value)
+	list := []string{}
+	for _, item := range rawList {
+		str, isString := item.(string)
+		require.True(t, isString, "Attribute %s of %s contains a non string item: %v", path, change.Address, item)
+		list = append(list, str)
+	}
+	return list
+}
+
+// AfterAttributeStringMap returns the planned value of the attribute at the given path as a map of strings. This will
+// fail the test if the attribute is not in the planned value or is not a map of strings.
+func (change *ResourceChange) AfterAttributeStringMap(t *testing.T, path string) map[string]string {
+	value := change.AfterAttribute(t, path)
+	rawMap, isMap := value.(map[string]interface{})
+	require.True(t, isMap, "Attribute %s of %s is not a map: %v", path, change.Address, value)
+	out := map[string]string{}
+	for key, item := range rawMap {
+		str, isString := item.(string)
+		require.True(t, isString, "Attribute %s of %s contains a non string value: %v", path, change.Address, item)
+		out[key] = str
+	}
+	return out
+}
+
+// IsAfterAttributeUnknown returns true if the attribute at the given path will only be known after apply.
+func (change *ResourceChange) IsAfterAttributeUnknown(path string) bool {
+	value, err := lookupAttribute(change.Address, change.Change.AfterUnknown, path)
+	if err != nil {
+		return false
+	}
+	unknown, isBool := value.(bool)
+	return isBool && unknown
+}
+
+// lookupAttribute walks the decoded JSON value along the dot separated path.
+func lookupAttribute(address string, root map[string]interface{}, path string) (interface{}, error) {
+	var current interface{} = root
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, hasKey := node[key]
+			if !hasKey {
+				return nil, AttributeNotFoundError{Address: address, Path: path}
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, AttributeNotFoundError{Address: address, Path: path}
+			}
+			current = node[index]
+		default:
+			return nil, AttributeNotFoundError{Address: address, Path: path}
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,101 @@
+package tfplan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A trimmed down version of the output of `terraform show -json` on a plan of the k8s-namespace-roles module.
+const testPlanJSON = `{
+  "format_version": "0.1",
+  "terraform_version": "0.12.11",
+  "variables": {
+    "namespace": {"value": "test"}
+  },
+  "resource_changes": [
+    {
+      "address": "kubernetes_role.rbac_role_access_read_only[0]",
+      "mode": "managed",
+      "type": "kubernetes_role",
+      "name": "rbac_role_access_read_only",
+      "index": 0,
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {
+          "metadata": [
+            {
+              "annotations": null,
+              "labels": {"app": "test"},
+              "name": "test-access-read-only",
+              "namespace": "test"
+            }
+          ],
+          "rule": [
+            {
+              "api_groups": ["*"],
+              "resources": ["*"],
+              "verbs": ["get", "list", "watch"]
+            }
+          ]
+        },
+        "after_unknown": {
+          "id": true,
+          "metadata": [{"generation": true, "resource_version": true, "self_link": true, "uid": true}]
+        }
+      }
+    },
+    {
+      "address": "null_resource.dependency_getter",
+      "mode": "managed",
+      "type": "null_resource",
+      "name": "dependency_getter",
+      "change": {
+        "actions": ["create"],
+        "before": null,
+        "after": {"triggers": {"instance": ""}},
+        "after_unknown": {"id": true}
+      }
+    }
+  ]
+}`
+
+func TestParsePlanJSONAndLookupAttributes(t *testing.T) {
+	t.Parallel()
+
+	plan, err := ParsePlanJSON(testPlanJSON)
+	require.NoError(t, err)
+	assert.Equal(t, "0.12.11", plan.TerraformVersion)
+	assert.Equal(t, "test", plan.Variables["namespace"].Value)
+
+	role := plan.ResourceChange(t, "kubernetes_role.rbac_role_access_read_only[0]")
+	assert.Equal(t, []string{"create"}, role.Change.Actions)
+	assert.Equal(t, "test-access-read-only", role.AfterAttribute(t, "metadata.0.name"))
+	assert.Equal(t, map[string]string{"app": "test"}, role.AfterAttributeStringMap(t, "metadata.0.labels"))
+	assert.Equal(t, []string{"get", "list", "watch"}, role.AfterAttributeStringList(t, "rule.0.verbs"))
+	assert.True(t, role.IsAfterAttributeUnknown("metadata.0.uid"))
+	assert.False(t, role.IsAfterAttributeUnknown("metadata.0.name"))
+
+	assert.Equal(t, 1, len(plan.ResourceChangesOfType("kubernetes_role")))
+	assert.Equal(t, 1, len(plan.ResourceChangesOfType("null_resource")))
+}
+
+func TestLookupMissingResourceAndAttribute(t *testing.T) {
+	t.Parallel()
+
+	plan, err := ParsePlanJSON(testPlanJSON)
+	require.NoError(t, err)
+
+	_, err = plan.ResourceChangeE("kubernetes_role.rbac_role_access_all[0]")
+	_, isResourceNotFoundErr := err.(ResourceNotFoundError)
+	assert.True(t, isResourceNotFoundErr)
+
+	role := plan.ResourceChange(t, "kubernetes_role.rbac_role_access_read_only[0]")
+	for _, path := range []string{"metadata.1.name", "metadata.0.foo", "rule.0.verbs.0.bar", "metadata.x"} {
+		_, err = role.AfterAttributeE(path)
+		_, isAttributeNotFoundErr := err.(AttributeNotFoundError)
+		assert.True(t, isAttributeNotFoundErr, path)
+	}
+}
@@ -0,0 +1,108 @@
+package tfvars
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// CheckStructAgainstModule checks that the tagged fields of the given vars struct match the variables declared in the
+// Terraform module at moduleDir. This will fail the test if there is an error.
+func CheckStructAgainstModule(t *testing.T, moduleDir string, vars interface{}) {
+	require.NoError(t, CheckStructAgainstModuleE(moduleDir, vars))
+}
+
+// CheckStructAgainstModuleE checks that the tagged fields of the given vars struct match the variables declared in the
+// Terraform module at moduleDir: every field must map to a declared variable, and every required variable (one without
+// a default) must have a field that is not omitempty. All the mismatches are returned in a single
+// VariableMismatchesError.
+func CheckStructAgainstModuleE(moduleDir string, vars interface{}) error {
+	variables, err := ParseVariablesE(moduleDir)
+	if err != nil {
+		return err
+	}
+	fields, err := FieldsE(vars)
+	if err != nil {
+		return err
+	}
+
+	mismatches := []string{}
+	fieldsByVariable := map[string]Field{}
+	for _, field := range fields {
+		fieldsByVariable[field.VariableName] = field
+		if _, isDeclared := variables[field.VariableName]; !isDeclared {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("field %s maps to variable %s, which is not declared", field.FieldName, field.VariableName),
+			)
+		}
+	}
+	for _, name := range sortedVariableNames(variables) {
+		if variables[name].HasDefault {
+			continue
+		}
+		field, hasField := fieldsByVariable[name]
+		switch {
+		case !hasField:
+			mismatches = append(mismatches, fmt.Sprintf("required variable %s has no field", name))
+		case field.OmitEmpty:
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("field %s maps to required variable %s, but is omitempty", field.FieldName, name),
+			)
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return VariableMismatchesError{ModuleDir: moduleDir, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// CheckVarsAgainstModule checks that the given Vars of terraform.Options match the variables declared in the Terraform
+// module at moduleDir. This will fail the test if there is an error.
+func CheckVarsAgainstModule(t *testing.T, moduleDir string, vars map[string]interface{}) {
+	require.NoError(t, CheckVarsAgainstModuleE(moduleDir, vars))
+}
+
+// CheckVarsAgainstModuleE checks that the given Vars of terraform.Options match the variables declared in the
+// Terraform module at moduleDir: every key must be a declared variable, and every required variable must be set.
+func CheckVarsAgainstModuleE(moduleDir string, vars map[string]interface{}) error {
+	variables, err := ParseVariablesE(moduleDir)
+	if err != nil {
+		return err
+	}
+
+	mismatches := []string{}
+	names := []string{}
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, isDeclared := variables[name]; !isDeclared {
+			mismatches = append(mismatches, fmt.Sprintf("variable %s is set, but not declared", name))
+		}
+	}
+	for _, name := range sortedVariableNames(variables) {
+		if _, isSet := vars[name]; !isSet && !variables[name].HasDefault {
+			mismatches = append(mismatches, fmt.Sprintf("required variable %s is not set", name))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return VariableMismatchesError{ModuleDir: moduleDir, Mismatches: mismatches}
+	}
+	return nil
+}
+
+func sortedVariableNames(variables map[string]Variable) []string {
+	names := []string{}
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,214 @@
+package tfvars
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TagName is the name of the struct tag that maps a field to the name of a Terraform variable, or to the key of a map
+// variable. The tag value is the variable name, optionally followed by ",omitempty" to leave the variable out when the
+// field has the zero value, so that the module default is used.
+const TagName = "tfvar"
+
+// Field is a struct field that maps to a Terraform variable.
+type Field struct {
+	FieldName    string
+	VariableName string
+	OmitEmpty    bool
+}
+
+// FieldsE returns the fields of the given struct, or pointer to struct, that are tagged with a Terraform variable
+// name.
+func FieldsE(vars interface{}) ([]Field, error) {
+	structType := reflect.TypeOf(vars)
+	if structType != nil && structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		return nil, NotAStructError{Value: vars}
+	}
+
+	fields := []Field{}
+	for i := 0; i < structType.NumField(); i++ {
+		field, hasTag := parseField(structType.Field(i))
+		if hasTag {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func parseField(structField reflect.StructField) (Field, bool) {
+	tag, hasTag := structField.Tag.Lookup(TagName)
+	if !hasTag || tag == "-" {
+		return Field{}, false
+	}
+	parts := strings.Split(tag, ",")
+	field := Field{FieldName: structField.Name, VariableName: parts[0]}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			field.OmitEmpty = true
+		}
+	}
+	return field, true
+}
+
+// toVars converts the tagged fields of the struct to a map of variable name to value. Nested structs, which must only
+// have string fields, are converted to a map[string]string, which is the type of the map variables of the modules, and
+// pointers are dereferenced.
+func toVars(vars interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	value := reflect.ValueOf(vars)
+	for i := 0; i < value.NumField(); i++ {
+		field, hasTag := parseField(value.Type().Field(i))
+		if !hasTag {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if field.OmitEmpty && isZero(fieldValue) {
+			continue
+		}
+		out[field.VariableName] = toValue(fieldValue)
+	}
+	return out
+}
+
+func toValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return toValue(value.Elem())
+	case reflect.Struct:
+		out := map[string]string{}
+		for key, nested := range toVars(value.Interface()) {
+			out[key] = nested.(string)
+		}
+		return out
+	default:
+		return value.Interface()
+	}
+}
+
+func isZero(value reflect.Value) bool {
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
+
+// FromVars populates the tagged fields of the struct that out points to from the Vars of terraform.Options. This will
+// fail the test if there is an error.
+func FromVars(t *testing.T, vars map[string]interface{}, out interface{}) {
+	require.NoError(t, FromVarsE(vars, out))
+}
+
+// FromVarsE populates the tagged fields of the struct that out points to from the Vars of terraform.Options. This
+// accepts both the values set by ToVars and the values returned by test_structure.LoadTerraformOptions, which decodes
+// the Vars from JSON (so numbers are float64 and maps are map[string]interface{}). Vars that don't map to a field are
+// ignored.
+func FromVarsE(vars map[string]interface{}, out interface{}) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return NotAStructError{Value: out}
+	}
+	return fromVars(vars, value.Elem())
+}
+
+func fromVars(vars map[string]interface{}, structValue reflect.Value) error {
+	for i := 0; i < structValue.NumField(); i++ {
+		field, hasTag := parseField(structValue.Type().Field(i))
+		if !hasTag {
+			continue
+		}
+		rawValue, isSet := vars[field.VariableName]
+		if !isSet || rawValue == nil {
+			continue
+		}
+		if err := setValue(field, structValue.Field(i), rawValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setValue(field Field, fieldValue reflect.Value, rawValue interface{}) error {
+	switch fieldValue.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(fieldValue.Type().Elem())
+		if err := setValue(field, elem.Elem(), rawValue); err != nil {
+			return err
+		}
+		fieldValue.Set(elem)
+		return nil
+	case reflect.Struct:
+		nested := map[string]interface{}{}
+		switch typedValue := rawValue.(type) {
+		case map[string]string:
+			for key, value := range typedValue {
+				nested[key] = value
+			}
+		case map[string]interface{}:
+			nested = typedValue
+		default:
+			return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+		}
+		return fromVars(nested, fieldValue)
+	case reflect.Map:
+		// The map variables of the modules are all map(string).
+		switch typedValue := rawValue.(type) {
+		case map[string]string:
+			fieldValue.Set(reflect.ValueOf(typedValue))
+		case map[string]interface{}:
+			out := map[string]string{}
+			for key, value := range typedValue {
+				stringValue, isString := value.(string)
+				if !isString {
+					return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+				}
+				out[key] = stringValue
+			}
+			fieldValue.Set(reflect.ValueOf(out))
+		default:
+			return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+		}
+		return nil
+	case reflect.Slice:
+		// The list variables of the modules are all list(string).
+		switch typedValue := rawValue.(type) {
+		case []string:
+			fieldValue.Set(reflect.ValueOf(typedValue))
+		case []interface{}:
+			out := []string{}
+			for _, value := range typedValue {
+				stringValue, isString := value.(string)
+				if !isString {
+					return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+				}
+				out = append(out, stringValue)
+			}
+			fieldValue.Set(reflect.ValueOf(out))
+		default:
+			return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+		}
+		return nil
+	case reflect.Int:
+		switch typedValue := rawValue.(type) {
+		case int:
+			fieldValue.SetInt(int64(typedValue))
+		case float64:
+			fieldValue.SetInt(int64(typedValue))
+		default:
+			return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+		}
+		return nil
+	default:
+		rawReflectValue := reflect.ValueOf(rawValue)
+		if rawReflectValue.Type() != fieldValue.Type() {
+			return VarTypeMismatchError{Field: field.FieldName, Variable: field.VariableName, Value: rawValue}
+		}
+		fieldValue.Set(rawReflectValue)
+		return nil
+	}
+}
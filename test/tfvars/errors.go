@@ -0,0 +1,41 @@
+package tfvars
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NotAStructError is returned when the vars value is not a struct or a pointer to a struct.
+type NotAStructError struct {
+	Value interface{}
+}
+
+func (err NotAStructError) Error() string {
+	return fmt.Sprintf("Expected a struct or pointer to struct for the terraform vars, got %T", err.Value)
+}
+
+// VariableMismatchesError is returned when the terraform vars do not match the variables declared in the module.
+type VariableMismatchesError struct {
+	ModuleDir  string
+	Mismatches []string
+}
+
+func (err VariableMismatchesError) Error() string {
+	return fmt.Sprintf(
+		"Terraform vars do not match the variables of the module in %s:\n%s",
+		err.ModuleDir,
+		strings.Join(err.Mismatches, "\n"),
+	)
+}
+
+// VarTypeMismatchError is returned when the value of a terraform var can not be assigned to the struct field it maps
+// to.
+type VarTypeMismatchError struct {
+	Field    string
+	Variable string
+	Value    interface{}
+}
+
+func (err VarTypeMismatchError) Error() string {
+	return fmt.Sprintf("Can not assign variable %s of type %T to field %s", err.Variable, err.Value, err.Field)
+}
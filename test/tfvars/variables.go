@@ -0,0 +1,59 @@
+package tfvars
+
+import (
+	"path/filepath"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// Variable is an input variable declared in a Terraform module.
+type Variable struct {
+	Name string
+
+	// HasDefault is false for required variables, which must be set by the caller of the module.
+	HasDefault bool
+}
+
+var variablesSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+	},
+}
+
+var defaultSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "default"},
+	},
+}
+
+// ParseVariablesE returns the input variables declared in the .tf files of the given module directory, keyed by name.
+func ParseVariablesE(moduleDir string) (map[string]Variable, error) {
+	paths, err := filepath.Glob(filepath.Join(moduleDir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]Variable{}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		content, _, diags := file.Body.PartialContent(variablesSchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		for _, block := range content.Blocks {
+			blockContent, _, diags := block.Body.PartialContent(defaultSchema)
+			if diags.HasErrors() {
+				return nil, diags
+			}
+			name := block.Labels[0]
+			_, hasDefault := blockContent.Attributes["default"]
+			variables[name] = Variable{Name: name, HasDefault: hasDefault}
+		}
+	}
+	return variables, nil
+}
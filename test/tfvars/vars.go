@@ -0,0 +1,164 @@
+package tfvars
+
+import "reflect"
+
+// TLSSubject is the subject of a TLS certificate, using the keys of the tls_cert_request resource of the tls provider.
+// This is the format of the tls_subject and client_tls_subject variables of the root module.
+type TLSSubject struct {
+	CommonName         string `tfvar:"common_name,omitempty"`
+	Organization       string `tfvar:"organization,omitempty"`
+	OrganizationalUnit string `tfvar:"organizational_unit,omitempty"`
+	StreetAddress      string `tfvar:"street_address,omitempty"`
+	Locality           string `tfvar:"locality,omitempty"`
+	Province           string `tfvar:"province,omitempty"`
+	Country            string `tfvar:"country,omitempty"`
+	PostalCode         string `tfvar:"postal_code,omitempty"`
+	SerialNumber       string `tfvar:"serial_number,omitempty"`
+}
+
+// ToMap returns the subject in the format of the tls_subject variables, leaving out the empty keys.
+func (subject TLSSubject) ToMap() map[string]string {
+	return toValue(reflect.ValueOf(subject)).(map[string]string)
+}
+
+// KubergruntTLSSubject is the subject of a TLS certificate, using the keys expected by kubergrunt. This is the format
+// of the tls_subject and client_tls_subject variables of the k8s-tiller-kubergrunt-minikube example.
+type KubergruntTLSSubject struct {
+	CommonName string `tfvar:"common_name,omitempty"`
+	Org        string `tfvar:"org,omitempty"`
+	OrgUnit    string `tfvar:"org_unit,omitempty"`
+	City       string `tfvar:"city,omitempty"`
+	State      string `tfvar:"state,omitempty"`
+	Country    string `tfvar:"country,omitempty"`
+}
+
+// ToMap returns the subject in the format of the tls_subject variables, leaving out the empty keys.
+func (subject KubergruntTLSSubject) ToMap() map[string]string {
+	return toValue(reflect.ValueOf(subject)).(map[string]string)
+}
+
+// RootModuleVars are the input variables of the root module, which deploys Tiller with TLS certs generated by the tls
+// provider.
+type RootModuleVars struct {
+	TillerNamespace    string `tfvar:"tiller_namespace"`
+	ResourceNamespace  string `tfvar:"resource_namespace"`
+	ServiceAccountName string `tfvar:"service_account_name"`
+
+	TLSSubject       TLSSubject `tfvar:"tls_subject,omitempty"`
+	ClientTLSSubject TLSSubject `tfvar:"client_tls_subject,omitempty"`
+
+	// TillerHistoryMax is a pointer so that it can be explicitly set to 0, which means unlimited history.
+	TillerHistoryMax *int `tfvar:"tiller_history_max,omitempty"`
+
+	TillerVersion            string `tfvar:"tiller_version,omitempty"`
+	PrivateKeyAlgorithm      string `tfvar:"private_key_algorithm,omitempty"`
+	PrivateKeyECDSACurve     string `tfvar:"private_key_ecdsa_curve,omitempty"`
+	PrivateKeyRSABits        int    `tfvar:"private_key_rsa_bits,omitempty"`
+	KubectlConfigContextName string `tfvar:"kubectl_config_context_name,omitempty"`
+	KubectlConfigPath        string `tfvar:"kubectl_config_path,omitempty"`
+
+	GrantHelmClientRBACUser           string `tfvar:"grant_helm_client_rbac_user,omitempty"`
+	GrantHelmClientRBACGroup          string `tfvar:"grant_helm_client_rbac_group,omitempty"`
+	GrantHelmClientRBACServiceAccount string `tfvar:"grant_helm_client_rbac_service_account,omitempty"`
+}
+
+// ToVars returns the variables in the format of the Vars attribute of terraform.Options.
+func (vars RootModuleVars) ToVars() map[string]interface{} {
+	return toVars(vars)
+}
+
+// KubergruntMinikubeExampleVars are the input variables of the k8s-tiller-kubergrunt-minikube example, which deploys
+// Tiller with TLS certs generated by kubergrunt.
+type KubergruntMinikubeExampleVars struct {
+	TillerNamespace    string `tfvar:"tiller_namespace"`
+	ResourceNamespace  string `tfvar:"resource_namespace"`
+	ServiceAccountName string `tfvar:"service_account_name"`
+
+	TLSSubject       KubergruntTLSSubject `tfvar:"tls_subject,omitempty"`
+	ClientTLSSubject KubergruntTLSSubject `tfvar:"client_tls_subject,omitempty"`
+
+	TillerVersion            string `tfvar:"tiller_version,omitempty"`
+	PrivateKeyAlgorithm      string `tfvar:"private_key_algorithm,omitempty"`
+	PrivateKeyECDSACurve     string `tfvar:"private_key_ecdsa_curve,omitempty"`
+	PrivateKeyRSABits        int    `tfvar:"private_key_rsa_bits,omitempty"`
+	KubectlConfigContextName string `tfvar:"kubectl_config_context_name,omitempty"`
+	KubectlConfigPath        string `tfvar:"kubectl_config_path,omitempty"`
+
+	// ConfigureHelm is a pointer so that it can be explicitly set to false. When nil, the module default is used.
+	ConfigureHelm                *bool  `tfvar:"configure_helm,omitempty"`
+	HelmHome                     string `tfvar:"helm_home,omitempty"`
+	HelmClientRBACUser           string `tfvar:"helm_client_rbac_user,omitempty"`
+	HelmClientRBACGroup          string `tfvar:"helm_client_rbac_group,omitempty"`
+	HelmClientRBACServiceAccount string `tfvar:"helm_client_rbac_service_account,omitempty"`
+}
+
+// ToVars returns the variables in the format of the Vars attribute of terraform.Options.
+func (vars KubergruntMinikubeExampleVars) ToVars() map[string]interface{} {
+	return toVars(vars)
+}
+
+// NamespaceWithServiceAccountExampleVars are the input variables of the k8s-namespace-with-service-account example.
+type NamespaceWithServiceAccountExampleVars struct {
+	Name string `tfvar:"name"`
+
+	KubectlConfigContextName string `tfvar:"kubectl_config_context_name,omitempty"`
+	KubectlConfigPath        string `tfvar:"kubectl_config_path,omitempty"`
+
+	// CreateResources is a pointer so that it can be explicitly set to false. When nil, the module default is used.
+	CreateResources *bool `tfvar:"create_resources,omitempty"`
+}
+
+// ToVars returns the variables in the format of the Vars attribute of terraform.Options.
+func (vars NamespaceWithServiceAccountExampleVars) ToVars() map[string]interface{} {
+	return toVars(vars)
+}
+
+// NamespaceModuleVars are the input variables of the k8s-namespace module.
+type NamespaceModuleVars struct {
+	Name string `tfvar:"name"`
+
+	Labels      map[string]string `tfvar:"labels,omitempty"`
+	Annotations map[string]string `tfvar:"annotations,omitempty"`
+
+	// CreateResources is a pointer so that it can be explicitly set to false. When nil, the module default is used.
+	CreateResources *bool `tfvar:"create_resources,omitempty"`
+}
+
+// ToVars returns the variables in the format of the Vars attribute of terraform.Options.
+func (vars NamespaceModuleVars) ToVars() map[string]interface{} {
+	return toVars(vars)
+}
+
+// ServiceAccountModuleVars are the input variables of the k8s-service-account module. The rbac_roles variable is left
+// out, because it is a list of maps, which toVars doesn't convert.
+type ServiceAccountModuleVars struct {
+	Name      string `tfvar:"name"`
+	Namespace string `tfvar:"namespace"`
+
+	Labels      map[string]string `tfvar:"labels,omitempty"`
+	Annotations map[string]string `tfvar:"annotations,omitempty"`
+
+	// AutomountServiceAccountToken is a pointer so that it can be explicitly set to false. When nil, the module default
+	// is used.
+	AutomountServiceAccountToken *bool    `tfvar:"automount_service_account_token,omitempty"`
+	SecretsForPullingImages      []string `tfvar:"secrets_for_pulling_images,omitempty"`
+	SecretsForPods               []string `tfvar:"secrets_for_pods,omitempty"`
+
+	// CreateResources is a pointer so that it can be explicitly set to false. When nil, the module default is used.
+	CreateResources *bool `tfvar:"create_resources,omitempty"`
+}
+
+// ToVars returns the variables in the format of the Vars attribute of terraform.Options.
+func (vars ServiceAccountModuleVars) ToVars() map[string]interface{} {
+	return toVars(vars)
+}
+
+// Bool returns a pointer to the given bool, for setting the optional bool variables.
+func Bool(value bool) *bool {
+	return &value
+}
+
+// Int returns a pointer to the given int, for setting the optional int variables where the zero value is meaningful.
+func Int(value int) *int {
+	return &value
+}
@@ -0,0 +1,229 @@
+package tfvars
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarsStructsMatchModules(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		moduleDir string
+		vars      interface{}
+	}{
+		{"RootModule", filepath.Join("..", ".."), RootModuleVars{}},
+		{
+			"KubergruntMinikubeExample",
+			filepath.Join("..", "..", "examples", "k8s-tiller-kubergrunt-minikube"),
+			KubergruntMinikubeExampleVars{},
+		},
+		{"NamespaceModule", filepath.Join("..", "..", "modules", "k8s-namespace"), NamespaceModuleVars{}},
+		{
+			"ServiceAccountModule",
+			filepath.Join("..", "..", "modules", "k8s-service-account"),
+			ServiceAccountModuleVars{},
+		},
+		{
+			"NamespaceWithServiceAccountExample",
+			filepath.Join("..", "..", "examples", "k8s-namespace-with-service-account"),
+			NamespaceWithServiceAccountExampleVars{},
+		},
+	}
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change across the parallel subtests
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			CheckStructAgainstModule(t, testCase.moduleDir, testCase.vars)
+		})
+	}
+}
+
+func TestToVars(t *testing.T) {
+	t.Parallel()
+
+	vars := RootModuleVars{
+		TillerNamespace:    "tiller",
+		ResourceNamespace:  "resources",
+		ServiceAccountName: "tiller-service-account",
+		TLSSubject:         TLSSubject{CommonName: "tiller", Organization: "Gruntwork"},
+	}
+	assert.Equal(
+		t,
+		map[string]interface{}{
+			"tiller_namespace":     "tiller",
+			"resource_namespace":   "resources",
+			"service_account_name": "tiller-service-account",
+			"tls_subject":          map[string]string{"common_name": "tiller", "organization": "Gruntwork"},
+		},
+		vars.ToVars(),
+	)
+
+	exampleVars := NamespaceWithServiceAccountExampleVars{Name: "test", CreateResources: Bool(false)}
+	assert.Equal(t, map[string]interface{}{"name": "test", "create_resources": false}, exampleVars.ToVars())
+
+	// A history max of 0 means unlimited, so it must be passed to the module instead of being omitted.
+	unlimitedHistoryVars := RootModuleVars{TillerHistoryMax: Int(0)}
+	assert.Equal(t, 0, unlimitedHistoryVars.ToVars()["tiller_history_max"])
+}
+
+func TestFromVarsRoundTripsThroughJSON(t *testing.T) {
+	t.Parallel()
+
+	vars := KubergruntMinikubeExampleVars{
+		TillerNamespace:    "tiller",
+		ResourceNamespace:  "resources",
+		ServiceAccountName: "tiller-service-account",
+		TLSSubject:         KubergruntTLSSubject{CommonName: "tiller", Org: "Gruntwork"},
+		PrivateKeyRSABits:  4096,
+		ConfigureHelm:      Bool(false),
+	}
+
+	// test_structure.SaveTerraformOptions and LoadTerraformOptions round trip the Vars through JSON.
+	data, err := json.Marshal(vars.ToVars())
+	require.NoError(t, err)
+	loadedVars := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &loadedVars))
+
+	decoded := KubergruntMinikubeExampleVars{}
+	FromVars(t, loadedVars, &decoded)
+	assert.Equal(t, vars, decoded)
+
+	err = FromVarsE(map[string]interface{}{"tiller_namespace": 1}, &decoded)
+	_, isTypeMismatchErr := err.(VarTypeMismatchError)
+	assert.True(t, isTypeMismatchErr)
+
+	namespaceVars := NamespaceModuleVars{
+		Name:            "test",
+		Labels:          map[string]string{"team": "platform"},
+		CreateResources: Bool(true),
+	}
+	data, err = json.Marshal(namespaceVars.ToVars())
+	require.NoError(t, err)
+	loadedVars = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &loadedVars))
+	decodedNamespaceVars := NamespaceModuleVars{}
+	FromVars(t, loadedVars, &decodedNamespaceVars)
+	assert.Equal(t, namespaceVars, decodedNamespaceVars)
+
+	err = FromVarsE(map[string]interface{}{"labels": map[string]interface{}{"replicas": 3}}, &decodedNamespaceVars)
+	_, isTypeMismatchErr = err.(VarTypeMismatchError)
+	assert.True(t, isTypeMismatchErr)
+
+	serviceAccountVars := ServiceAccountModuleVars{
+		Name:                         "deployer",
+		Namespace:                    "resources",
+		AutomountServiceAccountToken: Bool(false),
+		SecretsForPullingImages:      []string{"registry-credentials"},
+	}
+	data, err = json.Marshal(serviceAccountVars.ToVars())
+	require.NoError(t, err)
+	loadedVars = map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &loadedVars))
+	decodedServiceAccountVars := ServiceAccountModuleVars{}
+	FromVars(t, loadedVars, &decodedServiceAccountVars)
+	assert.Equal(t, serviceAccountVars, decodedServiceAccountVars)
+}
+
+const testVariablesTF = `
+# A comment with a { brace
+variable "required" {
+  description = "A } brace in a string"
+  type        = string
+}
+
+variable "with_default" {
+  type = map(string)
+  default = {
+    key = "value"
+  }
+}
+
+/*
+variable "commented_out" {
+}
+*/
+
+variable "nested_default" {
+  type = object({
+    default = string
+  })
+  description = <<EOF
+default = "in a heredoc {"
+EOF
+}
+
+variable "null_default" {
+  type    = string
+  default = null
+}
+`
+
+func TestParseVariables(t *testing.T) {
+	t.Parallel()
+
+	moduleDir, err := ioutil.TempDir("", "tfvars")
+	require.NoError(t, err)
+	defer os.RemoveAll(moduleDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(moduleDir, "variables.tf"), []byte(testVariablesTF), 0644))
+
+	variables, err := ParseVariablesE(moduleDir)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		map[string]Variable{
+			"required":       {Name: "required", HasDefault: false},
+			"with_default":   {Name: "with_default", HasDefault: true},
+			"nested_default": {Name: "nested_default", HasDefault: false},
+			"null_default":   {Name: "null_default", HasDefault: true},
+		},
+		variables,
+	)
+}
+
+type mismatchedVars struct {
+	Name    string `tfvar:"name,omitempty"`
+	Unknown string `tfvar:"unknown"`
+	Ignored string
+}
+
+func TestCheckMismatchedVars(t *testing.T) {
+	t.Parallel()
+
+	moduleDir := filepath.Join("..", "..", "examples", "k8s-namespace-with-service-account")
+
+	err := CheckStructAgainstModuleE(moduleDir, mismatchedVars{})
+	require.Error(t, err)
+	mismatchErr, isMismatchErr := err.(VariableMismatchesError)
+	require.True(t, isMismatchErr)
+	assert.Equal(
+		t,
+		[]string{
+			"field Unknown maps to variable unknown, which is not declared",
+			"field Name maps to required variable name, but is omitempty",
+		},
+		mismatchErr.Mismatches,
+	)
+
+	err = CheckVarsAgainstModuleE(moduleDir, map[string]interface{}{"unknown": "", "create_resources": false})
+	require.Error(t, err)
+	mismatchErr, isMismatchErr = err.(VariableMismatchesError)
+	require.True(t, isMismatchErr)
+	assert.Equal(
+		t,
+		[]string{"variable unknown is set, but not declared", "required variable name is not set"},
+		mismatchErr.Mismatches,
+	)
+
+	_, err = FieldsE("not a struct")
+	_, isNotAStructErr := err.(NotAStructError)
+	assert.True(t, isNotAStructErr)
+}
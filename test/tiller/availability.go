@@ -0,0 +1,230 @@
+package tiller
+
+import (
+	"crypto/tls"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AvailabilitySample is a single observation of the number of available Tiller replicas. Error is set when the replicas
+// could not be checked at all, e.g. because the API server could not be reached, in which case AvailableReplicas is
+// meaningless. LastProbeError is the reason the last unavailable replica gave, if any, and does not invalidate the
+// sample.
+type AvailabilitySample struct {
+	Time              time.Time
+	AvailableReplicas int32
+	Error             error
+	LastProbeError    error
+}
+
+// AvailabilityProbe checks how many Tiller replicas are available right now. The AvailabilityMonitor sets the time of
+// the returned sample.
+type AvailabilityProbe func() AvailabilitySample
+
+// AvailabilityMonitor runs an AvailabilityProbe in the background and records how many replicas are available, so that
+// tests can verify that Tiller stays up while the Deployment rolls, e.g. during an upgrade.
+type AvailabilityMonitor struct {
+	probe               AvailabilityProbe
+	sleepBetweenSamples time.Duration
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	samples  []AvailabilitySample
+}
+
+// StartAvailabilityMonitor starts polling the available replicas of the Tiller Deployment in the namespace of the
+// provided KubectlOptions. Each sample calls GetVersion over mTLS with the provided TLS config on every Tiller Pod that
+// is ready, and counts the Pods that answer, so that a replica only counts as available if it actually serves the
+// gRPC API. Call Stop on the returned monitor to stop polling and get the samples. This will fail the test if the
+// Kubernetes client can not be created.
+func StartAvailabilityMonitor(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	deploymentName string,
+	tlsConfig *tls.Config,
+	clientVersion string,
+	sleepBetweenSamples time.Duration,
+) *AvailabilityMonitor {
+	monitor, err := StartAvailabilityMonitorE(t, options, deploymentName, tlsConfig, clientVersion, sleepBetweenSamples)
+	require.NoError(t, err)
+	return monitor
+}
+
+// StartAvailabilityMonitorE starts polling the available replicas of the Tiller Deployment in the namespace of the
+// provided KubectlOptions, by calling GetVersion over mTLS on every Tiller Pod that is ready. Call Stop on the returned
+// monitor to stop polling and get the samples.
+func StartAvailabilityMonitorE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	deploymentName string,
+	tlsConfig *tls.Config,
+	clientVersion string,
+	sleepBetweenSamples time.Duration,
+) (*AvailabilityMonitor, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	getVersion := func(podName string) (string, error) {
+		return GetTillerVersionE(t, options, podName, tlsConfig, clientVersion)
+	}
+	probe := NewGRPCAvailabilityProbe(clientset, options.Namespace, deploymentName, getVersion)
+	return StartAvailabilityMonitorWithProbe(probe, sleepBetweenSamples), nil
+}
+
+// StartAvailabilityMonitorWithProbe starts running the given probe in the background. The first sample is taken
+// immediately.
+func StartAvailabilityMonitorWithProbe(
+	probe AvailabilityProbe,
+	sleepBetweenSamples time.Duration,
+) *AvailabilityMonitor {
+	monitor := &AvailabilityMonitor{
+		probe:               probe,
+		sleepBetweenSamples: sleepBetweenSamples,
+		stopChan:            make(chan struct{}),
+	}
+	monitor.wg.Add(1)
+	go monitor.run()
+	return monitor
+}
+
+// NewDeploymentAvailabilityProbe returns a probe that reads the number of available replicas from the status of the
+// Tiller Deployment. This only reflects the readiness probes of the Pods, so prefer NewGRPCAvailabilityProbe where the
+// Tiller gRPC API can be reached.
+func NewDeploymentAvailabilityProbe(
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+) AvailabilityProbe {
+	return func() AvailabilitySample {
+		deployment, err := clientset.AppsV1().Deployments(namespace).Get(deploymentName, metav1.GetOptions{})
+		if err != nil {
+			return AvailabilitySample{Error: err}
+		}
+		return AvailabilitySample{AvailableReplicas: deployment.Status.AvailableReplicas}
+	}
+}
+
+// NewGRPCAvailabilityProbe returns a probe that calls getVersion on each ready Tiller Pod managed by the Deployment,
+// and counts the Pods where the call succeeds. Pods that are terminating are left out, as they no longer receive new
+// connections from the helm client.
+func NewGRPCAvailabilityProbe(
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	getVersion func(podName string) (string, error),
+) AvailabilityProbe {
+	return func() AvailabilitySample {
+		pods, err := ListTillerPodsFromClientE(clientset, namespace, deploymentName)
+		if err != nil {
+			return AvailabilitySample{Error: err}
+		}
+		sample := AvailabilitySample{}
+		for _, pod := range pods {
+			if !isPodReady(pod) {
+				continue
+			}
+			if _, err := getVersion(pod.Name); err != nil {
+				sample.LastProbeError = err
+				continue
+			}
+			sample.AvailableReplicas++
+		}
+		return sample
+	}
+}
+
+// isPodReady returns true if the Ready condition of the Pod is true.
+func isPodReady(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Stop stops polling and returns all the samples taken so far, in order. A final sample is taken after polling stops,
+// so there is always at least one.
+func (monitor *AvailabilityMonitor) Stop() []AvailabilitySample {
+	close(monitor.stopChan)
+	monitor.wg.Wait()
+	monitor.takeSample()
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	return append([]AvailabilitySample{}, monitor.samples...)
+}
+
+// run takes samples until the monitor is stopped. This runs in its own goroutine, so the probe must return errors in
+// the sample instead of failing the test.
+func (monitor *AvailabilityMonitor) run() {
+	defer monitor.wg.Done()
+	ticker := time.NewTicker(monitor.sleepBetweenSamples)
+	defer ticker.Stop()
+	for {
+		monitor.takeSample()
+		select {
+		case <-monitor.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (monitor *AvailabilityMonitor) takeSample() {
+	start := time.Now()
+	sample := monitor.probe()
+	sample.Time = start
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+	monitor.samples = append(monitor.samples, sample)
+}
+
+// CheckNoDowntime verifies that at least minAvailable replicas of the Tiller Deployment were available in every sample
+// taken by an AvailabilityMonitor. This will fail the test if Tiller had downtime.
+func CheckNoDowntime(t *testing.T, deploymentName string, samples []AvailabilitySample, minAvailable int32) {
+	require.NoError(t, CheckNoDowntimeE(deploymentName, samples, minAvailable))
+}
+
+// CheckNoDowntimeE verifies that at least minAvailable replicas of the Tiller Deployment were available in every sample
+// taken by an AvailabilityMonitor. Samples where the replicas could not be checked say nothing about Tiller, so they
+// are ignored, but at least one sample must have succeeded. Returns a TillerDowntimeError listing the samples where too
+// few replicas were available.
+func CheckNoDowntimeE(deploymentName string, samples []AvailabilitySample, minAvailable int32) error {
+	downtime := []AvailabilitySample{}
+	var lastErr error
+	numSuccessfulSamples := 0
+	for _, sample := range samples {
+		if sample.Error != nil {
+			lastErr = sample.Error
+			continue
+		}
+		numSuccessfulSamples++
+		if sample.AvailableReplicas < minAvailable {
+			downtime = append(downtime, sample)
+		}
+	}
+
+	if numSuccessfulSamples == 0 {
+		return NoAvailabilitySamplesError{DeploymentName: deploymentName, NumSamples: len(samples), LastError: lastErr}
+	}
+	if len(downtime) > 0 {
+		return TillerDowntimeError{
+			DeploymentName: deploymentName,
+			MinAvailable:   minAvailable,
+			NumSamples:     numSuccessfulSamples,
+			Downtime:       downtime,
+		}
+	}
+	return nil
+}
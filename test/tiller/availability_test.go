@@ -0,0 +1,99 @@
+package tiller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAvailabilityMonitorRecordsSamples(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(newTestDeployment(1, 1))
+	probe := NewDeploymentAvailabilityProbe(clientset, testNamespace, DefaultDeploymentName)
+	monitor := StartAvailabilityMonitorWithProbe(probe, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	samples := monitor.Stop()
+
+	require.True(t, len(samples) >= 2, "Expected at least 2 samples, got %d", len(samples))
+	for _, sample := range samples {
+		require.NoError(t, sample.Error)
+		assert.Equal(t, int32(1), sample.AvailableReplicas)
+	}
+	assert.NoError(t, CheckNoDowntimeE(DefaultDeploymentName, samples, 1))
+}
+
+func TestAvailabilityMonitorRecordsMissingDeployment(t *testing.T) {
+	t.Parallel()
+
+	probe := NewDeploymentAvailabilityProbe(fake.NewSimpleClientset(), testNamespace, DefaultDeploymentName)
+	monitor := StartAvailabilityMonitorWithProbe(probe, time.Hour)
+	samples := monitor.Stop()
+
+	require.Equal(t, 2, len(samples))
+	err := CheckNoDowntimeE(DefaultDeploymentName, samples, 1)
+	noSamplesErr, isNoSamplesErr := err.(NoAvailabilitySamplesError)
+	require.True(t, isNoSamplesErr, "Expected NoAvailabilitySamplesError, got %T: %s", err, err)
+	assert.Equal(t, 2, noSamplesErr.NumSamples)
+}
+
+func TestGRPCAvailabilityProbeCountsServingPods(t *testing.T) {
+	t.Parallel()
+
+	servingPod := newTestReadyPod("tiller-deploy-serving")
+	rejectingPod := newTestReadyPod("tiller-deploy-rejecting")
+	terminatingPod := newTestReadyPod("tiller-deploy-terminating")
+	now := metav1.Now()
+	terminatingPod.DeletionTimestamp = &now
+	startingPod := newTestPod("tiller-deploy-starting", "gcr.io/kubernetes-helm/tiller:"+testVersion)
+	clientset := fake.NewSimpleClientset(servingPod, rejectingPod, terminatingPod, startingPod)
+
+	probedPods := []string{}
+	getVersion := func(podName string) (string, error) {
+		probedPods = append(probedPods, podName)
+		if podName == rejectingPod.Name {
+			return "", errors.New("connection refused")
+		}
+		return testVersion, nil
+	}
+	sample := NewGRPCAvailabilityProbe(clientset, testNamespace, DefaultDeploymentName, getVersion)()
+
+	require.NoError(t, sample.Error)
+	assert.Equal(t, int32(1), sample.AvailableReplicas)
+	assert.EqualError(t, sample.LastProbeError, "connection refused")
+	assert.ElementsMatch(t, []string{servingPod.Name, rejectingPod.Name}, probedPods)
+}
+
+func TestCheckNoDowntimeReportsUnavailableSamples(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	samples := []AvailabilitySample{
+		{Time: start, AvailableReplicas: 1},
+		{Time: start.Add(time.Second), Error: errors.New("connection refused")},
+		{Time: start.Add(2 * time.Second), AvailableReplicas: 0},
+		{Time: start.Add(3 * time.Second), AvailableReplicas: 2},
+	}
+
+	err := CheckNoDowntimeE(DefaultDeploymentName, samples, 1)
+	downtimeErr, isDowntimeErr := err.(TillerDowntimeError)
+	require.True(t, isDowntimeErr, "Expected TillerDowntimeError, got %T: %s", err, err)
+	assert.Equal(t, 3, downtimeErr.NumSamples)
+	assert.Equal(t, []AvailabilitySample{samples[2]}, downtimeErr.Downtime)
+
+	// The API error is not downtime, so the check passes once the unavailable sample is gone.
+	withoutDowntime := []AvailabilitySample{samples[0], samples[1], samples[3]}
+	assert.NoError(t, CheckNoDowntimeE(DefaultDeploymentName, withoutDowntime, 1))
+}
+
+func newTestReadyPod(name string) *corev1.Pod {
+	pod := newTestPod(name, "gcr.io/kubernetes-helm/tiller:"+testVersion)
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	return pod
+}
@@ -0,0 +1,211 @@
+package tiller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// TillerTLSCertsMountPath is where the k8s-tiller module mounts the Tiller TLS certs in the Tiller container.
+	TillerTLSCertsMountPath = "/etc/certs"
+
+	// ServiceAccountTokenMountPath is where the k8s-tiller module mounts the ServiceAccount token in the Tiller
+	// container.
+	ServiceAccountTokenMountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// ConformanceExpectations are the inputs of the k8s-tiller module that the Tiller Deployment is checked against in
+// CheckDeploymentConformance.
+type ConformanceExpectations struct {
+	// ServiceAccountName is the value of the tiller_service_account_name input.
+	ServiceAccountName string
+
+	// ListenLocalhost is the value of the tiller_listen_localhost input.
+	ListenLocalhost bool
+
+	// TLSSecretName is the name of the Secret that holds the Tiller TLS certs. Not checked when empty.
+	TLSSecretName string
+}
+
+// CheckDeploymentConformance fetches the Tiller Deployment in the namespace of the provided KubectlOptions and checks
+// it against the security posture of the k8s-tiller module. This will fail the test if the Deployment does not conform.
+func CheckDeploymentConformance(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	deploymentName string,
+	expectations ConformanceExpectations,
+) {
+	require.NoError(t, CheckDeploymentConformanceE(t, options, deploymentName, expectations))
+}
+
+// CheckDeploymentConformanceE fetches the Tiller Deployment in the namespace of the provided KubectlOptions and checks
+// it against the security posture of the k8s-tiller module. Returns a DeploymentNotConformantError listing all the
+// violations if the Deployment does not conform.
+func CheckDeploymentConformanceE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	deploymentName string,
+	expectations ConformanceExpectations,
+) error {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return err
+	}
+	deployment, err := clientset.AppsV1().Deployments(options.Namespace).Get(deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	return CheckDeploymentSpecConformanceE(deployment, expectations)
+}
+
+// CheckDeploymentSpecConformanceE checks the given Tiller Deployment against the security posture of the k8s-tiller
+// module:
+// - The Deployment and the Pods have the app=helm and name=tiller labels that the helm client looks for.
+// - The Pods run as the Tiller ServiceAccount.
+// - Tiller stores releases in Secrets, and only listens on localhost when configured to.
+// - Tiller has TLS enabled and verifies client certificates, using the certs mounted from the TLS Secret.
+// - The TLS certs and the ServiceAccount token are mounted read only from Secrets.
+func CheckDeploymentSpecConformanceE(deployment *appsv1.Deployment, expectations ConformanceExpectations) error {
+	violations := []string{}
+	violate := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	requiredLabels := map[string]string{"app": "helm", "name": "tiller"}
+	for _, key := range []string{"app", "name"} {
+		value := requiredLabels[key]
+		if deployment.Labels[key] != value {
+			violate("Deployment label %s is %q, expected %q", key, deployment.Labels[key], value)
+		}
+		if deployment.Spec.Template.Labels[key] != value {
+			violate("Pod label %s is %q, expected %q", key, deployment.Spec.Template.Labels[key], value)
+		}
+	}
+
+	podSpec := deployment.Spec.Template.Spec
+	if podSpec.ServiceAccountName != expectations.ServiceAccountName {
+		violate("Pods run as ServiceAccount %q, expected %q", podSpec.ServiceAccountName, expectations.ServiceAccountName)
+	}
+
+	container := getTillerContainer(deployment)
+	if container == nil {
+		violate("Pod template does not have a container named %s", TillerContainerName)
+		return newDeploymentNotConformantError(deployment, violations)
+	}
+
+	listenLocalhostArg := fmt.Sprintf("--listen=localhost:%d", TillerGRPCPort)
+	hasListenLocalhostArg := containsString(container.Args, listenLocalhostArg)
+	if !containsString(container.Args, "--storage=secret") {
+		violate("Tiller does not store releases in Secrets: args %q are missing --storage=secret", container.Args)
+	}
+	if expectations.ListenLocalhost && !hasListenLocalhostArg {
+		violate("Tiller does not only listen on localhost: args %q are missing %s", container.Args, listenLocalhostArg)
+	}
+	if !expectations.ListenLocalhost && hasListenLocalhostArg {
+		violate("Tiller only listens on localhost, but tiller_listen_localhost is false")
+	}
+	for _, flag := range []string{"--tls-key", "--tls-cert", "--tls-ca-cert"} {
+		if !hasArgWithPrefix(container.Args, fmt.Sprintf("%s=%s/", flag, TillerTLSCertsMountPath)) {
+			violate("Tiller args %q are missing %s under %s", container.Args, flag, TillerTLSCertsMountPath)
+		}
+	}
+
+	env := map[string]string{}
+	for _, envVar := range container.Env {
+		env[envVar.Name] = envVar.Value
+	}
+	for _, name := range []string{"TILLER_TLS_VERIFY", "TILLER_TLS_ENABLE"} {
+		if env[name] != "1" {
+			violate("Env var %s is %q, expected \"1\"", name, env[name])
+		}
+	}
+
+	tlsSecretName := checkReadOnlySecretMount(podSpec, container, TillerTLSCertsMountPath, violate)
+	if expectations.TLSSecretName != "" && tlsSecretName != "" && tlsSecretName != expectations.TLSSecretName {
+		violate("TLS certs are mounted from Secret %s, expected %s", tlsSecretName, expectations.TLSSecretName)
+	}
+	checkReadOnlySecretMount(podSpec, container, ServiceAccountTokenMountPath, violate)
+
+	return newDeploymentNotConformantError(deployment, violations)
+}
+
+// newDeploymentNotConformantError returns a DeploymentNotConformantError, or nil if there are no violations.
+func newDeploymentNotConformantError(deployment *appsv1.Deployment, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return DeploymentNotConformantError{
+		Namespace:      deployment.Namespace,
+		DeploymentName: deployment.Name,
+		Violations:     violations,
+	}
+}
+
+// checkReadOnlySecretMount checks that the container mounts a Secret volume read only at the given path, and returns
+// the name of the Secret.
+func checkReadOnlySecretMount(
+	podSpec corev1.PodSpec,
+	container *corev1.Container,
+	mountPath string,
+	violate func(string, ...interface{}),
+) string {
+	var mount *corev1.VolumeMount
+	for i := range container.VolumeMounts {
+		if container.VolumeMounts[i].MountPath == mountPath {
+			mount = &container.VolumeMounts[i]
+		}
+	}
+	if mount == nil {
+		violate("Nothing is mounted at %s", mountPath)
+		return ""
+	}
+	if !mount.ReadOnly {
+		violate("Volume %s is mounted at %s read write, expected read only", mount.Name, mountPath)
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.Name != mount.Name {
+			continue
+		}
+		if volume.Secret == nil {
+			violate("Volume %s mounted at %s is not a Secret volume", mount.Name, mountPath)
+			return ""
+		}
+		return volume.Secret.SecretName
+	}
+	violate("Volume %s mounted at %s is not in the Pod spec", mount.Name, mountPath)
+	return ""
+}
+
+func getTillerContainer(deployment *appsv1.Deployment) *corev1.Container {
+	for i := range deployment.Spec.Template.Spec.Containers {
+		if deployment.Spec.Template.Spec.Containers[i].Name == TillerContainerName {
+			return &deployment.Spec.Template.Spec.Containers[i]
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, expected string) bool {
+	for _, value := range values {
+		if value == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func hasArgWithPrefix(args []string, prefix string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package tiller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newConformantDeployment returns a Tiller Deployment with the spec that the k8s-tiller module renders.
+func newConformantDeployment() *appsv1.Deployment {
+	labels := map[string]string{"app": "helm", "name": "tiller", "deployment": DefaultDeploymentName}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultDeploymentName,
+			Namespace: "tiller",
+			Labels:    map[string]string{"app": "helm", "name": "tiller"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "tiller",
+					Containers: []corev1.Container{
+						{
+							Name: TillerContainerName,
+							Args: []string{
+								"--storage=secret",
+								"--tls-key=/etc/certs/tls.pem",
+								"--tls-cert=/etc/certs/tls.crt",
+								"--tls-ca-cert=/etc/certs/ca.crt",
+								"--listen=localhost:44134",
+							},
+							Env: []corev1.EnvVar{
+								{Name: "TILLER_NAMESPACE", Value: "tiller"},
+								{Name: "TILLER_TLS_VERIFY", Value: "1"},
+								{Name: "TILLER_TLS_ENABLE", Value: "1"},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "tiller-token-abcde", MountPath: ServiceAccountTokenMountPath, ReadOnly: true},
+								{Name: "tiller-certs", MountPath: TillerTLSCertsMountPath, ReadOnly: true},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "tiller-token-abcde",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: "tiller-token-abcde"},
+							},
+						},
+						{
+							Name: "tiller-certs",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{SecretName: "tiller-namespace-tiller-certs"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+var testConformanceExpectations = ConformanceExpectations{
+	ServiceAccountName: "tiller",
+	ListenLocalhost:    true,
+	TLSSecretName:      "tiller-namespace-tiller-certs",
+}
+
+func TestCheckDeploymentSpecConformancePasses(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, CheckDeploymentSpecConformanceE(newConformantDeployment(), testConformanceExpectations))
+
+	deployment := newConformantDeployment()
+	container := &deployment.Spec.Template.Spec.Containers[0]
+	container.Args = container.Args[:4]
+	expectations := testConformanceExpectations
+	expectations.ListenLocalhost = false
+	assert.NoError(t, CheckDeploymentSpecConformanceE(deployment, expectations))
+}
+
+func TestCheckDeploymentSpecConformanceViolations(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name               string
+		mutate             func(*appsv1.Deployment)
+		expectedViolations []string
+	}{
+		{
+			"MissingLabels",
+			func(deployment *appsv1.Deployment) {
+				delete(deployment.Labels, "app")
+				deployment.Spec.Template.Labels["name"] = "not-tiller"
+			},
+			[]string{
+				`Deployment label app is "", expected "helm"`,
+				`Pod label name is "not-tiller", expected "tiller"`,
+			},
+		},
+		{
+			"WrongServiceAccount",
+			func(deployment *appsv1.Deployment) {
+				deployment.Spec.Template.Spec.ServiceAccountName = "default"
+			},
+			[]string{`Pods run as ServiceAccount "default", expected "tiller"`},
+		},
+		{
+			"ConfigMapStorageAndListenAll",
+			func(deployment *appsv1.Deployment) {
+				deployment.Spec.Template.Spec.Containers[0].Args = []string{
+					"--storage=configmap",
+					"--tls-key=/etc/certs/tls.pem",
+					"--tls-cert=/etc/certs/tls.crt",
+					"--tls-ca-cert=/etc/certs/ca.crt",
+				}
+			},
+			[]string{
+				`Tiller does not store releases in Secrets: args ["--storage=configmap" "--tls-key=/etc/certs/tls.pem" "--tls-cert=/etc/certs/tls.crt" "--tls-ca-cert=/etc/certs/ca.crt"] are missing --storage=secret`,
+				`Tiller does not only listen on localhost: args ["--storage=configmap" "--tls-key=/etc/certs/tls.pem" "--tls-cert=/etc/certs/tls.crt" "--tls-ca-cert=/etc/certs/ca.crt"] are missing --listen=localhost:44134`,
+			},
+		},
+		{
+			"TLSDisabled",
+			func(deployment *appsv1.Deployment) {
+				container := &deployment.Spec.Template.Spec.Containers[0]
+				container.Args = []string{"--storage=secret", "--listen=localhost:44134"}
+				container.Env = []corev1.EnvVar{{Name: "TILLER_TLS_VERIFY", Value: "0"}}
+			},
+			[]string{
+				`Tiller args ["--storage=secret" "--listen=localhost:44134"] are missing --tls-key under /etc/certs`,
+				`Tiller args ["--storage=secret" "--listen=localhost:44134"] are missing --tls-cert under /etc/certs`,
+				`Tiller args ["--storage=secret" "--listen=localhost:44134"] are missing --tls-ca-cert under /etc/certs`,
+				`Env var TILLER_TLS_VERIFY is "0", expected "1"`,
+				`Env var TILLER_TLS_ENABLE is "", expected "1"`,
+			},
+		},
+		{
+			"WritableMounts",
+			func(deployment *appsv1.Deployment) {
+				for i := range deployment.Spec.Template.Spec.Containers[0].VolumeMounts {
+					deployment.Spec.Template.Spec.Containers[0].VolumeMounts[i].ReadOnly = false
+				}
+			},
+			[]string{
+				"Volume tiller-certs is mounted at /etc/certs read write, expected read only",
+				"Volume tiller-token-abcde is mounted at /var/run/secrets/kubernetes.io/serviceaccount read write, expected read only",
+			},
+		},
+		{
+			"WrongTLSSecretAndMissingToken",
+			func(deployment *appsv1.Deployment) {
+				podSpec := &deployment.Spec.Template.Spec
+				podSpec.Volumes[1].Secret.SecretName = "other-certs"
+				podSpec.Containers[0].VolumeMounts = podSpec.Containers[0].VolumeMounts[1:]
+			},
+			[]string{
+				"TLS certs are mounted from Secret other-certs, expected tiller-namespace-tiller-certs",
+				"Nothing is mounted at /var/run/secrets/kubernetes.io/serviceaccount",
+			},
+		},
+		{
+			"MissingContainer",
+			func(deployment *appsv1.Deployment) {
+				deployment.Spec.Template.Spec.Containers[0].Name = "not-tiller"
+			},
+			[]string{"Pod template does not have a container named tiller"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		// Capture range variable so that it doesn't change when the subtests run in parallel
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			deployment := newConformantDeployment()
+			testCase.mutate(deployment)
+			err := CheckDeploymentSpecConformanceE(deployment, testConformanceExpectations)
+			require.Error(t, err)
+			conformanceErr, isConformanceErr := err.(DeploymentNotConformantError)
+			require.True(t, isConformanceErr)
+			assert.Equal(t, testCase.expectedViolations, conformanceErr.Violations)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package tiller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TillerRolloutTimeoutError is returned when the Tiller Deployment does not finish rolling out within the allotted
+// retries.
+type TillerRolloutTimeoutError struct {
+	Namespace      string
+	DeploymentName string
+	LastError      error
+}
+
+func (err TillerRolloutTimeoutError) Error() string {
+	return fmt.Sprintf(
+		"Timed out waiting for Tiller Deployment %s in namespace %s to roll out: %s",
+		err.DeploymentName,
+		err.Namespace,
+		err.LastError,
+	)
+}
+
+// TillerVersionMismatchError is returned when a Tiller Pod is running an image version that differs from the expected
+// version.
+type TillerVersionMismatchError struct {
+	PodName         string
+	Image           string
+	ExpectedVersion string
+}
+
+func (err TillerVersionMismatchError) Error() string {
+	return fmt.Sprintf(
+		"Tiller Pod %s is running image %s, which does not match expected version %s",
+		err.PodName,
+		err.Image,
+		err.ExpectedVersion,
+	)
+}
+
+// TillerCrashLoopError is returned when a Tiller container is stuck in CrashLoopBackOff.
+type TillerCrashLoopError struct {
+	PodName      string
+	RestartCount int32
+	Message      string
+}
+
+func (err TillerCrashLoopError) Error() string {
+	return fmt.Sprintf(
+		"Tiller Pod %s is in CrashLoopBackOff after %d restarts: %s",
+		err.PodName,
+		err.RestartCount,
+		err.Message,
+	)
+}
+
+// TillerContainerNotFoundError is returned when a Tiller Pod does not have a container named tiller.
+type TillerContainerNotFoundError struct {
+	PodName string
+}
+
+func (err TillerContainerNotFoundError) Error() string {
+	return fmt.Sprintf("Pod %s does not have a container named %s", err.PodName, TillerContainerName)
+}
+
+// TillerDeploymentNotRolledOutError is returned while the Tiller Deployment is still rolling out. This is used as the
+// retryable error when waiting for the rollout.
+type TillerDeploymentNotRolledOutError struct {
+	DeploymentName string
+	Reasons        []string
+}
+
+func (err TillerDeploymentNotRolledOutError) Error() string {
+	return fmt.Sprintf(
+		"Tiller Deployment %s has not finished rolling out: %s",
+		err.DeploymentName,
+		strings.Join(err.Reasons, "; "),
+	)
+}
+
+// TillerNotReadyError is returned when the Tiller readiness endpoint does not report ready.
+type TillerNotReadyError struct {
+	PodName    string
+	StatusCode int
+	Body       string
+}
+
+func (err TillerNotReadyError) Error() string {
+	return fmt.Sprintf(
+		"Tiller Pod %s readiness endpoint returned status code %d: %s",
+		err.PodName,
+		err.StatusCode,
+		err.Body,
+	)
+}
+
+// DeploymentNotConformantError is returned when the Tiller Deployment does not match the security posture of the
+// k8s-tiller module.
+type DeploymentNotConformantError struct {
+	Namespace      string
+	DeploymentName string
+	Violations     []string
+}
+
+func (err DeploymentNotConformantError) Error() string {
+	return fmt.Sprintf(
+		"Tiller Deployment %s in namespace %s does not conform to the k8s-tiller module:\n%s",
+		err.DeploymentName,
+		err.Namespace,
+		strings.Join(err.Violations, "\n"),
+	)
+}
+
+// TillerDowntimeError is returned when fewer than the minimum number of Tiller replicas were available while the
+// Deployment was being monitored.
+type TillerDowntimeError struct {
+	DeploymentName string
+	MinAvailable   int32
+	NumSamples     int
+	Downtime       []AvailabilitySample
+}
+
+func (err TillerDowntimeError) Error() string {
+	downtime := []string{}
+	for _, sample := range err.Downtime {
+		line := fmt.Sprintf("%s: %d available", sample.Time.Format(time.RFC3339Nano), sample.AvailableReplicas)
+		if sample.LastProbeError != nil {
+			line = fmt.Sprintf("%s (%s)", line, sample.LastProbeError)
+		}
+		downtime = append(downtime, line)
+	}
+	return fmt.Sprintf(
+		"Fewer than %d replicas of Tiller Deployment %s were available in %d out of %d samples:\n%s",
+		err.MinAvailable,
+		err.DeploymentName,
+		len(err.Downtime),
+		err.NumSamples,
+		strings.Join(downtime, "\n"),
+	)
+}
+
+// NoAvailabilitySamplesError is returned when none of the samples of an AvailabilityMonitor could check the replicas of
+// the Tiller Deployment, so there is nothing to check downtime against.
+type NoAvailabilitySamplesError struct {
+	DeploymentName string
+	NumSamples     int
+	LastError      error
+}
+
+func (err NoAvailabilitySamplesError) Error() string {
+	return fmt.Sprintf(
+		"Could not check the replicas of Tiller Deployment %s in any of %d samples: %s",
+		err.DeploymentName,
+		err.NumSamples,
+		err.LastError,
+	)
+}
+
+// TillerTLSRejectedError is returned when Tiller rejects the TLS handshake of a client, e.g. because the client did not
+// present a certificate, or presented one that is expired or not signed by the Tiller CA.
+type TillerTLSRejectedError struct {
+	Endpoint string
+	Cause    error
+}
+
+func (err TillerTLSRejectedError) Error() string {
+	return fmt.Sprintf("Tiller at %s rejected the TLS handshake: %s", err.Endpoint, err.Cause)
+}
@@ -0,0 +1,188 @@
+package tiller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// TillerGRPCPort is the port that Tiller serves the gRPC API on.
+	TillerGRPCPort = 44134
+
+	// TillerServerName is the name that the helm client verifies the Tiller server certificate against when connecting
+	// through a port forward. The server certificates generated by the k8s-tiller module include this IP address.
+	TillerServerName = "127.0.0.1"
+
+	// getVersionMethod is the full gRPC method name of the GetVersion call of the Tiller ReleaseService.
+	getVersionMethod = "/hapi.services.tiller.ReleaseService/GetVersion"
+
+	// helmAPIClientMetadataKey is the gRPC metadata key that the helm client uses to send its version. Tiller rejects
+	// calls from clients with an incompatible version.
+	helmAPIClientMetadataKey = "x-helm-api-client"
+
+	defaultGRPCTimeout = 30 * time.Second
+)
+
+// NewClientTLSConfig returns a TLS config that authenticates with the given client certificate key pair and verifies
+// the Tiller server against the given CA certificate, the same way the helm client does with --tls-verify. Pass a nil
+// client key pair to connect without a client certificate.
+func NewClientTLSConfig(clientKeyPair *tlscerts.KeyPair, caCert *x509.Certificate) *tls.Config {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(caCert)
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: TillerServerName,
+		// With TLS 1.3 the client finishes the handshake before the server verifies the client certificate, so a
+		// rejected client certificate only shows up as a closed connection. With TLS 1.2, which is what the helm 2
+		// client negotiates, the server rejects it during the handshake with an alert that we can check for.
+		MaxVersion: tls.VersionTLS12,
+	}
+	if clientKeyPair != nil {
+		tlsConfig.Certificates = []tls.Certificate{clientKeyPair.TLSCertificate()}
+	}
+	return tlsConfig
+}
+
+// GetTillerVersion opens a port forward tunnel to the gRPC port of the given Tiller Pod and returns the Tiller version
+// reported by the GetVersion call, using mTLS with the provided TLS config. The client version is sent to Tiller in the
+// same way as the helm client, and should be compatible with the Tiller version. This will fail the test if there is
+// an error.
+func GetTillerVersion(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	podName string,
+	tlsConfig *tls.Config,
+	clientVersion string,
+) string {
+	version, err := GetTillerVersionE(t, options, podName, tlsConfig, clientVersion)
+	require.NoError(t, err)
+	return version
+}
+
+// GetTillerVersionE opens a port forward tunnel to the gRPC port of the given Tiller Pod and returns the Tiller version
+// reported by the GetVersion call, using mTLS with the provided TLS config.
+func GetTillerVersionE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	podName string,
+	tlsConfig *tls.Config,
+	clientVersion string,
+) (string, error) {
+	tunnel := k8s.NewTunnel(options, k8s.ResourceTypePod, podName, 0, TillerGRPCPort)
+	defer tunnel.Close()
+	if err := tunnel.ForwardPortE(t); err != nil {
+		return "", err
+	}
+	return GetTillerVersionFromEndpointE(tunnel.Endpoint(), tlsConfig, clientVersion)
+}
+
+// GetTillerVersionFromEndpointE calls GetVersion on the Tiller gRPC API at the given endpoint, using mTLS with the
+// provided TLS config, and returns the reported Tiller version. If Tiller rejects the client certificate during the TLS
+// handshake, this returns a TillerTLSRejectedError.
+func GetTillerVersionFromEndpointE(endpoint string, tlsConfig *tls.Config, clientVersion string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultGRPCTimeout)
+	defer cancel()
+
+	transportCredentials := &handshakeErrorRecorder{TransportCredentials: credentials.NewTLS(tlsConfig)}
+	conn, err := grpc.DialContext(ctx, endpoint, grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs(helmAPIClientMetadataKey, clientVersion))
+	response := &getVersionResponse{}
+	if err := conn.Invoke(ctx, getVersionMethod, &getVersionRequest{}, response); err != nil {
+		grpcStatus, _ := status.FromError(err)
+		handshakeErr := transportCredentials.LastError()
+		if grpcStatus.Code() == codes.Unavailable && isRemoteTLSAlert(handshakeErr) {
+			return "", TillerTLSRejectedError{Endpoint: endpoint, Cause: handshakeErr}
+		}
+		return "", err
+	}
+	if response.Version == nil {
+		return "", nil
+	}
+	return response.Version.SemVer, nil
+}
+
+// handshakeErrorRecorder wraps the transport credentials of a gRPC connection to record the last error of the TLS
+// handshake. gRPC dials in the background and retries failed handshakes, so the call itself only fails with the
+// Unavailable status code, which doesn't always say why.
+type handshakeErrorRecorder struct {
+	credentials.TransportCredentials
+
+	mutex   sync.Mutex
+	lastErr error
+}
+
+func (recorder *handshakeErrorRecorder) ClientHandshake(
+	ctx context.Context,
+	authority string,
+	rawConn net.Conn,
+) (net.Conn, credentials.AuthInfo, error) {
+	conn, authInfo, err := recorder.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if err != nil {
+		recorder.mutex.Lock()
+		recorder.lastErr = err
+		recorder.mutex.Unlock()
+	}
+	return conn, authInfo, err
+}
+
+// LastError returns the error of the last failed TLS handshake, or nil if none of the handshakes failed.
+func (recorder *handshakeErrorRecorder) LastError() error {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return recorder.lastErr
+}
+
+// isRemoteTLSAlert returns true if the error is a TLS alert sent by the server, e.g. "remote error: tls: bad
+// certificate". Errors from verifying the server certificate on the client side are not alerts from the server.
+func isRemoteTLSAlert(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "remote error: tls: ")
+}
+
+// The following are the parts of the Tiller API messages (hapi.services.tiller.GetVersionRequest,
+// hapi.services.tiller.GetVersionResponse, and hapi.version.Version) that are needed for the GetVersion call. These
+// are defined here so that we don't need to depend on the whole of helm for a single call.
+
+type getVersionRequest struct{}
+
+func (m *getVersionRequest) Reset()         { *m = getVersionRequest{} }
+func (m *getVersionRequest) String() string { return proto.CompactTextString(m) }
+func (*getVersionRequest) ProtoMessage()    {}
+
+type getVersionResponse struct {
+	Version *version `protobuf:"bytes,1,opt,name=Version" json:"Version,omitempty"`
+}
+
+func (m *getVersionResponse) Reset()         { *m = getVersionResponse{} }
+func (m *getVersionResponse) String() string { return proto.CompactTextString(m) }
+func (*getVersionResponse) ProtoMessage()    {}
+
+type version struct {
+	SemVer       string `protobuf:"bytes,1,opt,name=sem_ver,json=semVer" json:"sem_ver,omitempty"`
+	GitCommit    string `protobuf:"bytes,2,opt,name=git_commit,json=gitCommit" json:"git_commit,omitempty"`
+	GitTreeState string `protobuf:"bytes,3,opt,name=git_tree_state,json=gitTreeState" json:"git_tree_state,omitempty"`
+}
+
+func (m *version) Reset()         { *m = version{} }
+func (m *version) String() string { return proto.CompactTextString(m) }
+func (*version) ProtoMessage()    {}
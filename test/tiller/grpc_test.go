@@ -0,0 +1,162 @@
+package tiller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terraform-kubernetes-helm/test/tlscerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const testTillerVersion = "v2.12.2"
+
+func TestGetTillerVersionWithMTLS(t *testing.T) {
+	t.Parallel()
+
+	ca := generateTestCA(t, "tiller CA")
+	otherCA := generateTestCA(t, "other CA")
+	endpoint, stop := startFakeTiller(t, ca)
+	defer stop()
+
+	now := time.Now()
+	validClient := generateTestClient(t, ca, now.Add(-time.Hour), now.Add(time.Hour))
+	otherCAClient := generateTestClient(t, otherCA, now.Add(-time.Hour), now.Add(time.Hour))
+	expiredClient := generateTestClient(t, ca, now.Add(-2*time.Hour), now.Add(-time.Hour))
+
+	version, err := GetTillerVersionFromEndpointE(endpoint, NewClientTLSConfig(validClient, ca.Certificate), testTillerVersion)
+	require.NoError(t, err)
+	assert.Equal(t, testTillerVersion, version)
+
+	rejectedTestCases := []struct {
+		name          string
+		clientKeyPair *tlscerts.KeyPair
+	}{
+		{"NoClientCert", nil},
+		{"ClientCertFromOtherCA", otherCAClient},
+		{"ExpiredClientCert", expiredClient},
+	}
+	for _, testCase := range rejectedTestCases {
+		_, err := GetTillerVersionFromEndpointE(
+			endpoint,
+			NewClientTLSConfig(testCase.clientKeyPair, ca.Certificate),
+			testTillerVersion,
+		)
+		_, isTLSRejected := err.(TillerTLSRejectedError)
+		assert.True(t, isTLSRejected, "%s: expected TillerTLSRejectedError, got %T: %s", testCase.name, err, err)
+	}
+}
+
+func TestGetTillerVersionRejectsServerFromOtherCA(t *testing.T) {
+	t.Parallel()
+
+	ca := generateTestCA(t, "tiller CA")
+	otherCA := generateTestCA(t, "other CA")
+	endpoint, stop := startFakeTiller(t, ca)
+	defer stop()
+
+	now := time.Now()
+	client := generateTestClient(t, otherCA, now.Add(-time.Hour), now.Add(time.Hour))
+	_, err := GetTillerVersionFromEndpointE(endpoint, NewClientTLSConfig(client, otherCA.Certificate), testTillerVersion)
+	require.Error(t, err)
+	// The client rejects the server here, so this must not be reported as Tiller rejecting the client.
+	_, isTLSRejected := err.(TillerTLSRejectedError)
+	assert.False(t, isTLSRejected, "Expected an error other than TillerTLSRejectedError, got %s", err)
+}
+
+// startFakeTiller starts a gRPC server that serves GetVersion with the same TLS settings as Tiller with
+// TILLER_TLS_VERIFY=1, and returns the endpoint and a function to stop the server.
+func startFakeTiller(t *testing.T, ca *tlscerts.KeyPair) (string, func()) {
+	now := time.Now()
+	serverKeyPair, err := tlscerts.GenerateKeyPairE(
+		"tiller",
+		tlscerts.CertificateOptions{
+			Subject:     pkix.Name{CommonName: "tiller"},
+			NotBefore:   now.Add(-time.Hour),
+			NotAfter:    now.Add(time.Hour),
+			KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			IPAddresses: []net.IP{net.ParseIP(TillerServerName)},
+		},
+		ca,
+	)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Certificate)
+	serverTLSConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverKeyPair.TLSCertificate()},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+
+	serviceDesc := grpc.ServiceDesc{
+		ServiceName: "hapi.services.tiller.ReleaseService",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: "GetVersion",
+				Handler: func(
+					srv interface{},
+					ctx context.Context,
+					decode func(interface{}) error,
+					interceptor grpc.UnaryServerInterceptor,
+				) (interface{}, error) {
+					if err := decode(&getVersionRequest{}); err != nil {
+						return nil, err
+					}
+					md, _ := metadata.FromIncomingContext(ctx)
+					assert.Equal(t, []string{testTillerVersion}, md.Get(helmAPIClientMetadataKey))
+					return &getVersionResponse{Version: &version{SemVer: testTillerVersion}}, nil
+				},
+			},
+		},
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLSConfig)))
+	server.RegisterService(&serviceDesc, struct{}{})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	return listener.Addr().String(), server.Stop
+}
+
+func generateTestCA(t *testing.T, commonName string) *tlscerts.KeyPair {
+	now := time.Now()
+	ca, err := tlscerts.GenerateKeyPairE(
+		commonName,
+		tlscerts.CertificateOptions{
+			Subject:   pkix.Name{CommonName: commonName},
+			NotBefore: now.Add(-24 * time.Hour),
+			NotAfter:  now.Add(24 * time.Hour),
+			IsCA:      true,
+			KeyUsage:  x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		},
+		nil,
+	)
+	require.NoError(t, err)
+	return ca
+}
+
+func generateTestClient(t *testing.T, ca *tlscerts.KeyPair, notBefore time.Time, notAfter time.Time) *tlscerts.KeyPair {
+	client, err := tlscerts.GenerateKeyPairE(
+		"client",
+		tlscerts.CertificateOptions{
+			Subject:     pkix.Name{CommonName: "client"},
+			NotBefore:   notBefore,
+			NotAfter:    notAfter,
+			KeyUsage:    x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		},
+		ca,
+	)
+	require.NoError(t, err)
+	return client
+}
@@ -0,0 +1,347 @@
+package tiller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/http-helper"
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultDeploymentName is the default name of the Deployment resource created by the k8s-tiller module.
+	DefaultDeploymentName = "tiller-deploy"
+
+	// TillerContainerName is the name of the container running Tiller in the Pods managed by the Deployment.
+	TillerContainerName = "tiller"
+
+	// TillerHealthPort is the port that Tiller serves the liveness and readiness endpoints on.
+	TillerHealthPort = 44135
+
+	crashLoopBackOffReason = "CrashLoopBackOff"
+)
+
+// TillerPodLabelSelector returns the label selector that matches the Tiller Pods managed by the Deployment of the given
+// name. These are the labels that the k8s-tiller module sets on the Pod template.
+func TillerPodLabelSelector(deploymentName string) string {
+	return labels.SelectorFromSet(labels.Set{
+		"app":        "helm",
+		"name":       "tiller",
+		"deployment": deploymentName,
+	}).String()
+}
+
+// WaitForTiller waits until the Tiller Deployment in the namespace of the provided KubectlOptions has finished rolling
+// out, all the Pods are running the expected Tiller version, and each Pod reports ready on the readiness endpoint. This
+// will fail the test if Tiller does not come up.
+func WaitForTiller(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	deploymentName string,
+	expectedVersion string,
+	retries int,
+	sleepBetweenRetries time.Duration,
+) {
+	require.NoError(t, WaitForTillerE(t, options, deploymentName, expectedVersion, retries, sleepBetweenRetries))
+}
+
+// WaitForTillerE waits until the Tiller Deployment in the namespace of the provided KubectlOptions has finished rolling
+// out, all the Pods are running the expected Tiller version, and each Pod reports ready on the readiness endpoint.
+func WaitForTillerE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	deploymentName string,
+	expectedVersion string,
+	retries int,
+	sleepBetweenRetries time.Duration,
+) error {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return err
+	}
+	pods, err := WaitForTillerRolloutE(
+		t,
+		clientset,
+		options.Namespace,
+		deploymentName,
+		expectedVersion,
+		retries,
+		sleepBetweenRetries,
+	)
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if err := CheckTillerReadinessE(t, options, pod.Name, retries, sleepBetweenRetries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitForTillerRolloutE waits until the Tiller Deployment has finished rolling out and returns the Tiller Pods that
+// are managed by it. This will return immediately with a TillerVersionMismatchError if the rolled out Pods are not
+// running the expected version, or a TillerCrashLoopError if any of the Pods are crash looping. If the rollout does not
+// complete within the retries, this will return a TillerRolloutTimeoutError.
+func WaitForTillerRolloutE(
+	t *testing.T,
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	expectedVersion string,
+	retries int,
+	sleepBetweenRetries time.Duration,
+) ([]corev1.Pod, error) {
+	var tillerPods []corev1.Pod
+	var lastErr error
+	_, err := retry.DoWithRetryE(
+		t,
+		fmt.Sprintf("Waiting for Tiller Deployment %s in namespace %s", deploymentName, namespace),
+		retries,
+		sleepBetweenRetries,
+		func() (string, error) {
+			pods, err := checkTillerRollout(clientset, namespace, deploymentName, expectedVersion)
+			if err != nil {
+				lastErr = err
+				return "", err
+			}
+			tillerPods = pods
+			return "Tiller rolled out", nil
+		},
+	)
+	if err == nil {
+		return tillerPods, nil
+	}
+	if fatalErr, isFatalErr := err.(retry.FatalError); isFatalErr {
+		return nil, fatalErr.Underlying
+	}
+	return nil, TillerRolloutTimeoutError{Namespace: namespace, DeploymentName: deploymentName, LastError: lastErr}
+}
+
+// checkTillerRollout does a single check of the Tiller Deployment rollout. Errors that will not resolve themselves by
+// waiting are wrapped in a retry.FatalError.
+func checkTillerRollout(
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+	expectedVersion string,
+) ([]corev1.Pod, error) {
+	deployment, err := clientset.AppsV1().Deployments(namespace).Get(deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := ListTillerPodsFromClientE(clientset, namespace, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	// A crash looping Tiller will never become ready, so bail early with the reason instead of waiting for the timeout.
+	for _, pod := range pods {
+		if err := checkTillerPodNotCrashLooping(pod); err != nil {
+			return nil, retry.FatalError{Underlying: err}
+		}
+	}
+
+	if reasons := deploymentRolloutIncompleteReasons(deployment); len(reasons) > 0 {
+		return nil, TillerDeploymentNotRolledOutError{DeploymentName: deploymentName, Reasons: reasons}
+	}
+	if int32(len(pods)) != deploymentReplicas(deployment) {
+		return nil, TillerDeploymentNotRolledOutError{
+			DeploymentName: deploymentName,
+			Reasons: []string{
+				fmt.Sprintf("found %d Tiller Pods, expected %d", len(pods), deploymentReplicas(deployment)),
+			},
+		}
+	}
+
+	for _, pod := range pods {
+		if err := checkTillerPodVersion(pod, expectedVersion); err != nil {
+			return nil, retry.FatalError{Underlying: err}
+		}
+	}
+	return pods, nil
+}
+
+// ListTillerPods returns the Tiller Pods managed by the Deployment of the given name in the namespace of the provided
+// KubectlOptions, leaving out the Pods that are terminating. This will fail the test if there is an error.
+func ListTillerPods(t *testing.T, options *k8s.KubectlOptions, deploymentName string) []corev1.Pod {
+	pods, err := ListTillerPodsE(t, options, deploymentName)
+	require.NoError(t, err)
+	return pods
+}
+
+// ListTillerPodsE returns the Tiller Pods managed by the Deployment of the given name in the namespace of the provided
+// KubectlOptions, leaving out the Pods that are terminating.
+func ListTillerPodsE(t *testing.T, options *k8s.KubectlOptions, deploymentName string) ([]corev1.Pod, error) {
+	clientset, err := k8s.GetKubernetesClientFromOptionsE(t, options)
+	if err != nil {
+		return nil, err
+	}
+	return ListTillerPodsFromClientE(clientset, options.Namespace, deploymentName)
+}
+
+// ListTillerPodsFromClientE returns the Tiller Pods managed by the Deployment of the given name, leaving out the Pods
+// from a previous ReplicaSet that are on their way out. These still match the label selector until they are gone, but
+// no longer serve requests.
+func ListTillerPodsFromClientE(
+	clientset kubernetes.Interface,
+	namespace string,
+	deploymentName string,
+) ([]corev1.Pod, error) {
+	podList, err := clientset.CoreV1().Pods(namespace).List(
+		metav1.ListOptions{LabelSelector: TillerPodLabelSelector(deploymentName)},
+	)
+	if err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp == nil {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// deploymentRolloutIncompleteReasons mirrors the logic of `kubectl rollout status`, returning the list of reasons why
+// the Deployment is not yet rolled out. An empty list means the rollout is complete.
+func deploymentRolloutIncompleteReasons(deployment *appsv1.Deployment) []string {
+	reasons := []string{}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		reasons = append(reasons, "waiting for the Deployment spec update to be observed")
+		// The rest of the status is stale if the latest generation is not observed yet.
+		return reasons
+	}
+	replicas := deploymentReplicas(deployment)
+	if deployment.Status.UpdatedReplicas < replicas {
+		reasons = append(
+			reasons,
+			fmt.Sprintf("%d out of %d new replicas have been updated", deployment.Status.UpdatedReplicas, replicas),
+		)
+	}
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		reasons = append(
+			reasons,
+			fmt.Sprintf(
+				"%d old replicas are pending termination",
+				deployment.Status.Replicas-deployment.Status.UpdatedReplicas,
+			),
+		)
+	}
+	if deployment.Status.AvailableReplicas < deployment.Status.UpdatedReplicas {
+		reasons = append(
+			reasons,
+			fmt.Sprintf(
+				"%d of %d updated replicas are available",
+				deployment.Status.AvailableReplicas,
+				deployment.Status.UpdatedReplicas,
+			),
+		)
+	}
+	return reasons
+}
+
+// deploymentReplicas returns the desired number of replicas of the Deployment, taking into account the API default.
+func deploymentReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// checkTillerPodNotCrashLooping returns a TillerCrashLoopError if the tiller container of the Pod is in
+// CrashLoopBackOff.
+func checkTillerPodNotCrashLooping(pod corev1.Pod) error {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != TillerContainerName || status.State.Waiting == nil {
+			continue
+		}
+		if status.State.Waiting.Reason == crashLoopBackOffReason {
+			return TillerCrashLoopError{
+				PodName:      pod.Name,
+				RestartCount: status.RestartCount,
+				Message:      status.State.Waiting.Message,
+			}
+		}
+	}
+	return nil
+}
+
+// checkTillerPodVersion returns a TillerVersionMismatchError if the image tag of the tiller container in the Pod does
+// not match the expected version.
+func checkTillerPodVersion(pod corev1.Pod, expectedVersion string) error {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != TillerContainerName {
+			continue
+		}
+		if imageTag(container.Image) != expectedVersion {
+			return TillerVersionMismatchError{
+				PodName:         pod.Name,
+				Image:           container.Image,
+				ExpectedVersion: expectedVersion,
+			}
+		}
+		return nil
+	}
+	return TillerContainerNotFoundError{PodName: pod.Name}
+}
+
+// imageTag extracts the tag from a container image reference. Returns empty string if the image has no tag.
+func imageTag(image string) string {
+	// Only look at the last path component so that registry ports (e.g localhost:5000/tiller) are not treated as tags.
+	lastComponent := image[strings.LastIndex(image, "/")+1:]
+	// Drop any digest before looking for the tag.
+	lastComponent = strings.SplitN(lastComponent, "@", 2)[0]
+	tagIndex := strings.LastIndex(lastComponent, ":")
+	if tagIndex == -1 {
+		return ""
+	}
+	return lastComponent[tagIndex+1:]
+}
+
+// CheckTillerReadinessE opens a port forward tunnel to the health port of the given Tiller Pod and verifies that the
+// readiness endpoint reports the Pod as ready. This will retry the check up to the provided number of retries.
+func CheckTillerReadinessE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	podName string,
+	retries int,
+	sleepBetweenRetries time.Duration,
+) error {
+	tunnel := k8s.NewTunnel(options, k8s.ResourceTypePod, podName, 0, TillerHealthPort)
+	defer tunnel.Close()
+	if err := tunnel.ForwardPortE(t); err != nil {
+		return err
+	}
+
+	readinessURL := fmt.Sprintf("http://%s/readiness", tunnel.Endpoint())
+	_, err := retry.DoWithRetryE(
+		t,
+		fmt.Sprintf("Checking readiness of Tiller Pod %s", podName),
+		retries,
+		sleepBetweenRetries,
+		func() (string, error) {
+			statusCode, body, err := http_helper.HttpGetE(t, readinessURL, nil)
+			if err != nil {
+				return "", err
+			}
+			if statusCode != 200 {
+				return "", TillerNotReadyError{PodName: podName, StatusCode: statusCode, Body: body}
+			}
+			logger.Logf(t, "Tiller Pod %s is ready", podName)
+			return body, nil
+		},
+	)
+	return err
+}
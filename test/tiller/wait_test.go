@@ -0,0 +1,188 @@
+package tiller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	testNamespace = "tiller-ns"
+	testVersion   = "v2.12.2"
+)
+
+func TestWaitForTillerRolloutReturnsPodsWhenRolledOut(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(
+		newTestDeployment(1, 1),
+		newTestPod("tiller-deploy-abc", "gcr.io/kubernetes-helm/tiller:"+testVersion),
+	)
+	pods, err := WaitForTillerRolloutE(t, clientset, testNamespace, DefaultDeploymentName, testVersion, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pods))
+	assert.Equal(t, "tiller-deploy-abc", pods[0].Name)
+}
+
+func TestListTillerPodsIgnoresTerminatingPods(t *testing.T) {
+	t.Parallel()
+
+	oldPod := newTestPod("tiller-deploy-old", "gcr.io/kubernetes-helm/tiller:v2.11.0")
+	now := metav1.Now()
+	oldPod.DeletionTimestamp = &now
+	clientset := fake.NewSimpleClientset(
+		oldPod,
+		newTestPod("tiller-deploy-new", "gcr.io/kubernetes-helm/tiller:"+testVersion),
+	)
+	pods, err := ListTillerPodsFromClientE(clientset, testNamespace, DefaultDeploymentName)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pods))
+	assert.Equal(t, "tiller-deploy-new", pods[0].Name)
+}
+
+func TestWaitForTillerRolloutIgnoresTerminatingPods(t *testing.T) {
+	t.Parallel()
+
+	oldPod := newTestPod("tiller-deploy-old", "gcr.io/kubernetes-helm/tiller:v2.11.0")
+	now := metav1.Now()
+	oldPod.DeletionTimestamp = &now
+	clientset := fake.NewSimpleClientset(
+		newTestDeployment(1, 1),
+		oldPod,
+		newTestPod("tiller-deploy-new", "gcr.io/kubernetes-helm/tiller:"+testVersion),
+	)
+	pods, err := WaitForTillerRolloutE(t, clientset, testNamespace, DefaultDeploymentName, testVersion, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pods))
+	assert.Equal(t, "tiller-deploy-new", pods[0].Name)
+}
+
+func TestWaitForTillerRolloutReturnsVersionMismatch(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(
+		newTestDeployment(1, 1),
+		newTestPod("tiller-deploy-abc", "gcr.io/kubernetes-helm/tiller:v2.11.0"),
+	)
+	_, err := WaitForTillerRolloutE(t, clientset, testNamespace, DefaultDeploymentName, testVersion, 3, 0)
+	require.Error(t, err)
+	mismatchErr, isMismatchErr := err.(TillerVersionMismatchError)
+	require.True(t, isMismatchErr, "Expected TillerVersionMismatchError, got %T: %s", err, err)
+	assert.Equal(t, "tiller-deploy-abc", mismatchErr.PodName)
+	assert.Equal(t, testVersion, mismatchErr.ExpectedVersion)
+}
+
+func TestWaitForTillerRolloutReturnsCrashLoop(t *testing.T) {
+	t.Parallel()
+
+	pod := newTestPod("tiller-deploy-abc", "gcr.io/kubernetes-helm/tiller:"+testVersion)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:         TillerContainerName,
+			RestartCount: 5,
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{
+					Reason:  crashLoopBackOffReason,
+					Message: "Back-off restarting failed container",
+				},
+			},
+		},
+	}
+	// The Deployment is intentionally not rolled out, since a crash looping Pod never becomes available.
+	clientset := fake.NewSimpleClientset(newTestDeployment(1, 0), pod)
+	_, err := WaitForTillerRolloutE(t, clientset, testNamespace, DefaultDeploymentName, testVersion, 3, 0)
+	require.Error(t, err)
+	crashLoopErr, isCrashLoopErr := err.(TillerCrashLoopError)
+	require.True(t, isCrashLoopErr, "Expected TillerCrashLoopError, got %T: %s", err, err)
+	assert.Equal(t, int32(5), crashLoopErr.RestartCount)
+}
+
+func TestWaitForTillerRolloutTimesOut(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset(
+		newTestDeployment(1, 0),
+		newTestPod("tiller-deploy-abc", "gcr.io/kubernetes-helm/tiller:"+testVersion),
+	)
+	_, err := WaitForTillerRolloutE(t, clientset, testNamespace, DefaultDeploymentName, testVersion, 2, 0)
+	require.Error(t, err)
+	timeoutErr, isTimeoutErr := err.(TillerRolloutTimeoutError)
+	require.True(t, isTimeoutErr, "Expected TillerRolloutTimeoutError, got %T: %s", err, err)
+	_, isNotRolledOutErr := timeoutErr.LastError.(TillerDeploymentNotRolledOutError)
+	assert.True(t, isNotRolledOutErr)
+}
+
+func TestWaitForTillerRolloutTimesOutWhenDeploymentMissing(t *testing.T) {
+	t.Parallel()
+
+	clientset := fake.NewSimpleClientset()
+	_, err := WaitForTillerRolloutE(t, clientset, testNamespace, DefaultDeploymentName, testVersion, 2, 0)
+	require.Error(t, err)
+	_, isTimeoutErr := err.(TillerRolloutTimeoutError)
+	assert.True(t, isTimeoutErr, "Expected TillerRolloutTimeoutError, got %T: %s", err, err)
+}
+
+func TestImageTag(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{"gcr.io/kubernetes-helm/tiller:v2.12.2", "v2.12.2"},
+		{"localhost:5000/tiller:v2.12.2", "v2.12.2"},
+		{"localhost:5000/tiller", ""},
+		{"tiller@sha256:abcdef", ""},
+		{"tiller:v2.12.2@sha256:abcdef", "v2.12.2"},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, imageTag(testCase.image), testCase.image)
+	}
+}
+
+func newTestDeployment(replicas int32, availableReplicas int32) runtime.Object {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       DefaultDeploymentName,
+			Namespace:  testNamespace,
+			Generation: 1,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 1,
+			Replicas:           replicas,
+			UpdatedReplicas:    replicas,
+			AvailableReplicas:  availableReplicas,
+		},
+	}
+}
+
+func newTestPod(name string, image string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels: map[string]string{
+				"app":        "helm",
+				"name":       "tiller",
+				"deployment": DefaultDeploymentName,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  TillerContainerName,
+					Image: image,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package tlscerts
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// EncodeCertificatePEM encodes the x509 certificate as PEM.
+func EncodeCertificatePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// EncodePrivateKeyPEME encodes the RSA or ECDSA private key as PEM, in the same formats as the terraform tls provider
+// (PKCS1 for RSA and SEC1 for ECDSA), so that it can be parsed back with ParsePrivateKeyPEME.
+func EncodePrivateKeyPEME(privateKey crypto.Signer) ([]byte, error) {
+	switch typedKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(typedKey)}), nil
+	case *ecdsa.PrivateKey:
+		keyBytes, err := x509.MarshalECPrivateKey(typedKey)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), nil
+	}
+	return nil, UnsupportedPrivateKeyError{KeyType: fmt.Sprintf("%T", privateKey)}
+}
+
+// EncodePublicKeyPEME encodes the public key as a PEM encoded PKIX public key.
+func EncodePublicKeyPEME(publicKey crypto.PublicKey) ([]byte, error) {
+	keyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes}), nil
+}
+
+// SecretDataE encodes the key pair into Secret data, using the layout of the TLS modules in this repo. This is the
+// inverse of KeyPairFromSecretE. The CA certificate is only included if caFilenameBase is set, in which case the key
+// pair must have a CA certificate.
+func (keyPair *KeyPair) SecretDataE(filenameBase string, caFilenameBase string) (map[string][]byte, error) {
+	privateKeyPEM, err := EncodePrivateKeyPEME(keyPair.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKeyPEM, err := EncodePublicKeyPEME(keyPair.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string][]byte{
+		filenameBase + ".pem": privateKeyPEM,
+		filenameBase + ".pub": publicKeyPEM,
+		filenameBase + ".crt": EncodeCertificatePEM(keyPair.Certificate),
+	}
+	if caFilenameBase != "" {
+		if keyPair.CACertificate == nil {
+			return nil, MissingCACertificateError{Name: keyPair.Name}
+		}
+		data[caFilenameBase+".crt"] = EncodeCertificatePEM(keyPair.CACertificate)
+	}
+	return data, nil
+}
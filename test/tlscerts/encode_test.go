@@ -0,0 +1,57 @@
+package tlscerts
+
+import (
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecretDataRoundTripsThroughKeyPairFromSecret(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	caKeyPair, err := GenerateKeyPairE("ca", CertificateOptions{
+		Subject:   pkix.Name{CommonName: "tiller CA"},
+		NotBefore: now,
+		NotAfter:  now.Add(time.Hour),
+		IsCA:      true,
+	}, nil)
+	require.NoError(t, err)
+	clientKeyPair, err := GenerateKeyPairE("client", CertificateOptions{
+		Subject:   pkix.Name{CommonName: "admin"},
+		NotBefore: now,
+		NotAfter:  now.Add(time.Hour),
+	}, caKeyPair)
+	require.NoError(t, err)
+
+	data, err := clientKeyPair.SecretDataE(DefaultClientFilenameBase, DefaultCAFilenameBase)
+	require.NoError(t, err)
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "tiller", Name: "client-certs"}, Data: data}
+	decoded, err := KeyPairFromSecretE(secret, DefaultClientFilenameBase, DefaultCAFilenameBase)
+	require.NoError(t, err)
+	assert.Equal(t, clientKeyPair.Certificate.Raw, decoded.Certificate.Raw)
+	assert.Equal(t, caKeyPair.Certificate.Raw, decoded.CACertificate.Raw)
+	assert.Equal(t, clientKeyPair.PrivateKey, decoded.PrivateKey)
+	assert.Equal(t, clientKeyPair.PublicKey, decoded.PublicKey)
+
+	// The CA Secret does not carry a separate CA certificate.
+	_, err = caKeyPair.SecretDataE(DefaultCAFilenameBase, DefaultCAFilenameBase)
+	_, isMissingCAErr := err.(MissingCACertificateError)
+	assert.True(t, isMissingCAErr, "Expected MissingCACertificateError, got %T: %s", err, err)
+}
+
+func TestEncodePrivateKeyPEMSupportsRSA(t *testing.T) {
+	t.Parallel()
+
+	privateKey := generateRSAKey(t, 2048)
+	privateKeyPEM, err := EncodePrivateKeyPEME(privateKey)
+	require.NoError(t, err)
+	decoded, err := ParsePrivateKeyPEME("rsa", privateKeyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, privateKey, decoded)
+}
@@ -0,0 +1,73 @@
+package tlscerts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MissingSecretKeyError is returned when a Secret does not contain one of the expected keys of the certificate key
+// pair layout.
+type MissingSecretKeyError struct {
+	Namespace  string
+	SecretName string
+	Key        string
+}
+
+func (err MissingSecretKeyError) Error() string {
+	return fmt.Sprintf("Secret %s/%s does not contain the key %s", err.Namespace, err.SecretName, err.Key)
+}
+
+// InvalidPEMError is returned when data that is expected to be PEM encoded can not be decoded, or is of the wrong type.
+type InvalidPEMError struct {
+	Name      string
+	BlockType string
+}
+
+func (err InvalidPEMError) Error() string {
+	if err.BlockType == "" {
+		return fmt.Sprintf("%s does not contain a PEM encoded block", err.Name)
+	}
+	return fmt.Sprintf("%s contains an unsupported PEM block of type %s", err.Name, err.BlockType)
+}
+
+// UnknownAllowedUseError is returned when an allowed use keyword is not one supported by the terraform tls provider.
+type UnknownAllowedUseError struct {
+	AllowedUse string
+}
+
+func (err UnknownAllowedUseError) Error() string {
+	return fmt.Sprintf("Unknown allowed use %s", err.AllowedUse)
+}
+
+// CertificateMismatchesError is returned when a certificate key pair does not match one or more of the expectations.
+type CertificateMismatchesError struct {
+	Name       string
+	Mismatches []string
+}
+
+func (err CertificateMismatchesError) Error() string {
+	return fmt.Sprintf(
+		"Certificate key pair %s did not match the expectations:\n\t%s",
+		err.Name,
+		strings.Join(err.Mismatches, "\n\t"),
+	)
+}
+
+// UnsupportedPrivateKeyError is returned when encoding or regenerating a private key that is neither RSA nor ECDSA.
+type UnsupportedPrivateKeyError struct {
+	KeyType string
+}
+
+func (err UnsupportedPrivateKeyError) Error() string {
+	return fmt.Sprintf("Unsupported private key type %s: expected an RSA or ECDSA private key", err.KeyType)
+}
+
+// MissingCACertificateError is returned when encoding a key pair with the CA certificate, but the key pair does not
+// record the CA that signed it.
+type MissingCACertificateError struct {
+	Name string
+}
+
+func (err MissingCACertificateError) Error() string {
+	return fmt.Sprintf("Certificate key pair %s does not have a CA certificate", err.Name)
+}
@@ -0,0 +1,107 @@
+package tlscerts
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// CertificateOptions describes a certificate to generate with GenerateKeyPairE.
+type CertificateOptions struct {
+	Subject     pkix.Name
+	NotBefore   time.Time
+	NotAfter    time.Time
+	IsCA        bool
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+	IPAddresses []net.IP
+	DNSNames    []string
+
+	// The private key to certify. A new ECDSA P256 private key is generated if this is nil.
+	PrivateKey crypto.Signer
+}
+
+// GenerateKeyPairE generates a certificate for the private key of the options, or for a new ECDSA P256 private key if
+// there is none. The certificate is signed by the given CA key pair, or is self signed if the CA is nil. The CA
+// certificate is recorded on the returned key pair.
+func GenerateKeyPairE(name string, options CertificateOptions, ca *KeyPair) (*KeyPair, error) {
+	privateKey := options.PrivateKey
+	if privateKey == nil {
+		ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		privateKey = ecdsaKey
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               options.Subject,
+		NotBefore:             options.NotBefore,
+		NotAfter:              options.NotAfter,
+		IsCA:                  options.IsCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              options.KeyUsage,
+		ExtKeyUsage:           options.ExtKeyUsage,
+		IPAddresses:           options.IPAddresses,
+		DNSNames:              options.DNSNames,
+	}
+	parent, signer := template, privateKey
+	if ca != nil {
+		parent, signer = ca.Certificate, ca.PrivateKey
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, privateKey.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPair := &KeyPair{
+		Name:        name,
+		Certificate: cert,
+		PrivateKey:  privateKey,
+		PublicKey:   privateKey.Public(),
+	}
+	if ca != nil {
+		keyPair.CACertificate = ca.Certificate
+	}
+	return keyPair, nil
+}
+
+// GeneratePrivateKeyLikeE generates a new private key with the same algorithm and size as the given one: an RSA key
+// with the same number of bits, or an ECDSA key on the same curve. This is useful to rotate a key pair without changing
+// the private_key_* settings it was created with.
+func GeneratePrivateKeyLikeE(privateKey crypto.Signer) (crypto.Signer, error) {
+	switch typedKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return rsa.GenerateKey(rand.Reader, typedKey.N.BitLen())
+	case *ecdsa.PrivateKey:
+		return ecdsa.GenerateKey(typedKey.Curve, rand.Reader)
+	}
+	return nil, UnsupportedPrivateKeyError{KeyType: fmt.Sprintf("%T", privateKey)}
+}
+
+// TLSCertificate returns the key pair as a certificate that can be used in a tls.Config.
+func (keyPair *KeyPair) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{keyPair.Certificate.Raw},
+		PrivateKey:  keyPair.PrivateKey,
+		Leaf:        keyPair.Certificate,
+	}
+}
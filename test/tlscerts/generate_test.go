@@ -0,0 +1,48 @@
+package tlscerts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePrivateKeyLikeKeepsAlgorithmAndSize(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := GeneratePrivateKeyLikeE(generateRSAKey(t, 1024))
+	require.NoError(t, err)
+	typedRSAKey, isRSAKey := rsaKey.(*rsa.PrivateKey)
+	require.True(t, isRSAKey, "Expected an RSA private key, got %T", rsaKey)
+	assert.Equal(t, 1024, typedRSAKey.N.BitLen())
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	ecdsaKey, err := GeneratePrivateKeyLikeE(p384Key)
+	require.NoError(t, err)
+	typedECDSAKey, isECDSAKey := ecdsaKey.(*ecdsa.PrivateKey)
+	require.True(t, isECDSAKey, "Expected an ECDSA private key, got %T", ecdsaKey)
+	assert.Equal(t, elliptic.P384(), typedECDSAKey.Curve)
+}
+
+func TestGenerateKeyPairCertifiesGivenPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	privateKey := generateRSAKey(t, 1024)
+	now := time.Now()
+	keyPair, err := GenerateKeyPairE("rsa", CertificateOptions{
+		Subject:    pkix.Name{CommonName: "rsa"},
+		NotBefore:  now,
+		NotAfter:   now.Add(time.Hour),
+		PrivateKey: privateKey,
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, privateKey, keyPair.PrivateKey)
+	assert.Equal(t, privateKey.Public(), keyPair.Certificate.PublicKey)
+}
@@ -0,0 +1,187 @@
+package tlscerts
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/k8s"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DefaultCAFilenameBase is the default filename base of the CA certificate key pair in the Secrets.
+	DefaultCAFilenameBase = "ca"
+
+	// DefaultServerFilenameBase is the default filename base of the Tiller server certificate key pair in the Secret.
+	DefaultServerFilenameBase = "tls"
+
+	// DefaultClientFilenameBase is the default filename base of the helm client certificate key pair in the Secret.
+	DefaultClientFilenameBase = "client"
+)
+
+// KeyPair is a decoded certificate key pair, stored in a Secret using the layout of the TLS modules in this repo:
+// `<filename_base>.pem` for the private key, `<filename_base>.pub` for the public key, and `<filename_base>.crt` for
+// the certificate. Secrets for signed certificates also carry the certificate of the CA that signed them under
+// `<ca_filename_base>.crt`.
+type KeyPair struct {
+	Name          string
+	Certificate   *x509.Certificate
+	PrivateKey    crypto.Signer
+	PublicKey     crypto.PublicKey
+	CACertificate *x509.Certificate
+}
+
+// GetKeyPair loads and decodes the certificate key pair stored in the given Secret. Set caFilenameBase to
+// the empty string for Secrets that do not carry a CA certificate (e.g the CA Secret). This will fail the test if there
+// is an error.
+func GetKeyPair(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	secretName string,
+	filenameBase string,
+	caFilenameBase string,
+) *KeyPair {
+	keyPair, err := GetKeyPairE(t, options, secretName, filenameBase, caFilenameBase)
+	require.NoError(t, err)
+	return keyPair
+}
+
+// GetKeyPairE loads and decodes the certificate key pair stored in the given Secret. Set caFilenameBase to
+// the empty string for Secrets that do not carry a CA certificate (e.g the CA Secret).
+func GetKeyPairE(
+	t *testing.T,
+	options *k8s.KubectlOptions,
+	secretName string,
+	filenameBase string,
+	caFilenameBase string,
+) (*KeyPair, error) {
+	secret, err := k8s.GetSecretE(t, options, secretName)
+	if err != nil {
+		return nil, err
+	}
+	return KeyPairFromSecretE(secret, filenameBase, caFilenameBase)
+}
+
+// KeyPairFromSecretE decodes the certificate key pair stored in the given Secret object.
+func KeyPairFromSecretE(secret *corev1.Secret, filenameBase string, caFilenameBase string) (*KeyPair, error) {
+	getData := func(key string) ([]byte, error) {
+		data, hasKey := secret.Data[key]
+		if !hasKey {
+			return nil, MissingSecretKeyError{Namespace: secret.Namespace, SecretName: secret.Name, Key: key}
+		}
+		return data, nil
+	}
+	dataName := func(key string) string {
+		return fmt.Sprintf("%s/%s[%s]", secret.Namespace, secret.Name, key)
+	}
+
+	keyPair := KeyPair{Name: fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)}
+
+	certKey := filenameBase + ".crt"
+	certPEM, err := getData(certKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPair.Certificate, err = ParseCertificatePEME(dataName(certKey), certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyKey := filenameBase + ".pem"
+	privateKeyPEM, err := getData(privateKeyKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPair.PrivateKey, err = ParsePrivateKeyPEME(dataName(privateKeyKey), privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyKey := filenameBase + ".pub"
+	publicKeyPEM, err := getData(publicKeyKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPair.PublicKey, err = ParsePublicKeyPEME(dataName(publicKeyKey), publicKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if caFilenameBase != "" {
+		caCertKey := caFilenameBase + ".crt"
+		caCertPEM, err := getData(caCertKey)
+		if err != nil {
+			return nil, err
+		}
+		keyPair.CACertificate, err = ParseCertificatePEME(dataName(caCertKey), caCertPEM)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &keyPair, nil
+}
+
+// ParseCertificatePEME decodes a PEM encoded x509 certificate. The name is only used in error messages.
+func ParseCertificatePEME(name string, data []byte) (*x509.Certificate, error) {
+	block, err := decodePEM(name, data, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// ParsePrivateKeyPEME decodes a PEM encoded RSA or ECDSA private key, in the formats that the terraform tls provider
+// outputs (PKCS1 for RSA and SEC1 for ECDSA). PKCS8 encoded keys are also supported. The name is only used in error
+// messages.
+func ParsePrivateKeyPEME(name string, data []byte) (crypto.Signer, error) {
+	block, err := decodePEM(name, data, "RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch typedKey := key.(type) {
+	case *rsa.PrivateKey:
+		return typedKey, nil
+	case *ecdsa.PrivateKey:
+		return typedKey, nil
+	}
+	return nil, InvalidPEMError{Name: name, BlockType: fmt.Sprintf("%s (%T)", block.Type, key)}
+}
+
+// ParsePublicKeyPEME decodes a PEM encoded PKIX public key. The name is only used in error messages.
+func ParsePublicKeyPEME(name string, data []byte) (crypto.PublicKey, error) {
+	block, err := decodePEM(name, data, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// decodePEM decodes the first PEM block in the data, and checks that it is one of the allowed types.
+func decodePEM(name string, data []byte, allowedTypes ...string) (*pem.Block, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, InvalidPEMError{Name: name}
+	}
+	for _, allowedType := range allowedTypes {
+		if block.Type == allowedType {
+			return block, nil
+		}
+	}
+	return nil, InvalidPEMError{Name: name, BlockType: block.Type}
+}
@@ -0,0 +1,309 @@
+package tlscerts
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// validityTolerance is how far the validity period of a certificate can be off from the expected validity period. The
+// terraform tls provider computes the start and end of the validity period from the same timestamp, so this only
+// needs to account for rounding in the encoding.
+const validityTolerance = time.Minute
+
+// keyUsages maps the allowed_uses keywords of the terraform tls provider that are encoded as key usages.
+var keyUsages = map[string]x509.KeyUsage{
+	"digital_signature":  x509.KeyUsageDigitalSignature,
+	"content_commitment": x509.KeyUsageContentCommitment,
+	"key_encipherment":   x509.KeyUsageKeyEncipherment,
+	"data_encipherment":  x509.KeyUsageDataEncipherment,
+	"key_agreement":      x509.KeyUsageKeyAgreement,
+	"cert_signing":       x509.KeyUsageCertSign,
+	"crl_signing":        x509.KeyUsageCRLSign,
+	"encipher_only":      x509.KeyUsageEncipherOnly,
+	"decipher_only":      x509.KeyUsageDecipherOnly,
+}
+
+// extKeyUsages maps the allowed_uses keywords of the terraform tls provider that are encoded as extended key usages.
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"any_extended":                  x509.ExtKeyUsageAny,
+	"server_auth":                   x509.ExtKeyUsageServerAuth,
+	"client_auth":                   x509.ExtKeyUsageClientAuth,
+	"code_signing":                  x509.ExtKeyUsageCodeSigning,
+	"email_protection":              x509.ExtKeyUsageEmailProtection,
+	"ipsec_end_system":              x509.ExtKeyUsageIPSECEndSystem,
+	"ipsec_tunnel":                  x509.ExtKeyUsageIPSECTunnel,
+	"ipsec_user":                    x509.ExtKeyUsageIPSECUser,
+	"timestamping":                  x509.ExtKeyUsageTimeStamping,
+	"ocsp_signing":                  x509.ExtKeyUsageOCSPSigning,
+	"microsoft_server_gated_crypto": x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"netscape_server_gated_crypto":  x509.ExtKeyUsageNetscapeServerGatedCrypto,
+}
+
+// Expectations describes the properties that a certificate key pair should have, using the same terms as the input
+// variables of the TLS modules (e.g `tls_subject`, `allowed_uses`, `validity_period_hours` and the `private_key_*`
+// variables). Zero values are not checked, except for IsCA.
+type Expectations struct {
+	// Subject uses the keys of the tls_subject input variables (e.g common_name and organization).
+	Subject map[string]string
+
+	// AllowedUses uses the keywords of the allowed_uses input variables (e.g key_encipherment and server_auth).
+	AllowedUses []string
+
+	ValidityPeriodHours  int
+	PrivateKeyAlgorithm  string
+	PrivateKeyECDSACurve string
+	PrivateKeyRSABits    int
+	DNSNames             []string
+	IPAddresses          []string
+	IsCA                 bool
+}
+
+// VerifyKeyPair checks the certificate key pair against the expectations. This will fail the test with a single error
+// listing every mismatch.
+func VerifyKeyPair(t *testing.T, keyPair *KeyPair, expectations Expectations) {
+	require.NoError(t, VerifyKeyPairE(keyPair, expectations))
+}
+
+// VerifyKeyPairE checks the certificate key pair against the expectations, and returns a CertificateMismatchesError
+// listing every mismatch. This also checks that the private key, public key, and certificate belong together.
+func VerifyKeyPairE(keyPair *KeyPair, expectations Expectations) error {
+	mismatches := []string{}
+	cert := keyPair.Certificate
+
+	if !publicKeysEqual(keyPair.PrivateKey.Public(), cert.PublicKey) {
+		mismatches = append(mismatches, "private key does not match the public key of the certificate")
+	}
+	if !publicKeysEqual(keyPair.PublicKey, cert.PublicKey) {
+		mismatches = append(mismatches, "public key does not match the public key of the certificate")
+	}
+
+	mismatches = append(mismatches, checkPrivateKey(keyPair.PrivateKey, expectations)...)
+
+	if expectations.Subject != nil {
+		mismatches = append(mismatches, checkSubject(cert.Subject, expectations.Subject)...)
+	}
+
+	if expectations.AllowedUses != nil {
+		usageMismatches, err := checkAllowedUses(cert, expectations.AllowedUses)
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, usageMismatches...)
+	}
+
+	if expectations.ValidityPeriodHours != 0 {
+		expected := time.Duration(expectations.ValidityPeriodHours) * time.Hour
+		actual := cert.NotAfter.Sub(cert.NotBefore)
+		if actual < expected-validityTolerance || actual > expected+validityTolerance {
+			mismatches = append(
+				mismatches,
+				fmt.Sprintf("validity period is %s, expected %d hours", actual, expectations.ValidityPeriodHours),
+			)
+		}
+	}
+
+	if cert.IsCA != expectations.IsCA {
+		mismatches = append(mismatches, fmt.Sprintf("is_ca_certificate is %t, expected %t", cert.IsCA, expectations.IsCA))
+	}
+
+	if expectations.DNSNames != nil && !stringSetsEqual(cert.DNSNames, expectations.DNSNames) {
+		mismatches = append(mismatches, fmt.Sprintf("DNS names are %v, expected %v", cert.DNSNames, expectations.DNSNames))
+	}
+	if expectations.IPAddresses != nil {
+		actualIPs := []string{}
+		for _, ip := range cert.IPAddresses {
+			actualIPs = append(actualIPs, ip.String())
+		}
+		expectedIPs := []string{}
+		for _, ip := range expectations.IPAddresses {
+			expectedIPs = append(expectedIPs, net.ParseIP(ip).String())
+		}
+		if !stringSetsEqual(actualIPs, expectedIPs) {
+			mismatches = append(mismatches, fmt.Sprintf("IP addresses are %v, expected %v", actualIPs, expectedIPs))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return CertificateMismatchesError{Name: keyPair.Name, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// VerifyChain checks that the certificate key pair was signed by the CA key pair. This will fail the test if there is
+// an error.
+func VerifyChain(t *testing.T, caKeyPair *KeyPair, keyPair *KeyPair) {
+	require.NoError(t, VerifyChainE(caKeyPair, keyPair))
+}
+
+// VerifyChainE checks that the certificate key pair was signed by the CA key pair, and that the CA certificate stored
+// alongside the key pair (if any) is the certificate of the CA key pair.
+func VerifyChainE(caKeyPair *KeyPair, keyPair *KeyPair) error {
+	mismatches := []string{}
+	if !caKeyPair.Certificate.IsCA {
+		mismatches = append(mismatches, fmt.Sprintf("certificate of %s is not a CA certificate", caKeyPair.Name))
+	}
+	if keyPair.CACertificate != nil && !keyPair.CACertificate.Equal(caKeyPair.Certificate) {
+		mismatches = append(mismatches, fmt.Sprintf("CA certificate does not match the certificate of %s", caKeyPair.Name))
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caKeyPair.Certificate)
+	verifyOptions := x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := keyPair.Certificate.Verify(verifyOptions); err != nil {
+		mismatches = append(mismatches, fmt.Sprintf("certificate does not chain to %s: %s", caKeyPair.Name, err))
+	}
+
+	if len(mismatches) > 0 {
+		return CertificateMismatchesError{Name: keyPair.Name, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// checkPrivateKey checks the algorithm and size of the private key against the private_key_* expectations.
+func checkPrivateKey(privateKey crypto.Signer, expectations Expectations) []string {
+	mismatches := []string{}
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if expectations.PrivateKeyAlgorithm != "" && expectations.PrivateKeyAlgorithm != "RSA" {
+			mismatches = append(mismatches, fmt.Sprintf("private key algorithm is RSA, expected %s", expectations.PrivateKeyAlgorithm))
+		}
+		bits := key.N.BitLen()
+		if expectations.PrivateKeyRSABits != 0 && bits != expectations.PrivateKeyRSABits {
+			mismatches = append(mismatches, fmt.Sprintf("RSA key size is %d bits, expected %d", bits, expectations.PrivateKeyRSABits))
+		}
+	case *ecdsa.PrivateKey:
+		if expectations.PrivateKeyAlgorithm != "" && expectations.PrivateKeyAlgorithm != "ECDSA" {
+			mismatches = append(mismatches, fmt.Sprintf("private key algorithm is ECDSA, expected %s", expectations.PrivateKeyAlgorithm))
+		}
+		// The curve names used by the tls provider are the Go curve names without the dash (e.g P256 for P-256).
+		curve := strings.Replace(key.Curve.Params().Name, "-", "", -1)
+		if expectations.PrivateKeyECDSACurve != "" && curve != expectations.PrivateKeyECDSACurve {
+			mismatches = append(mismatches, fmt.Sprintf("ECDSA curve is %s, expected %s", curve, expectations.PrivateKeyECDSACurve))
+		}
+	default:
+		mismatches = append(mismatches, fmt.Sprintf("unsupported private key type %T", privateKey))
+	}
+	return mismatches
+}
+
+// checkSubject checks the subject of the certificate against the expected tls_subject map.
+func checkSubject(subject pkix.Name, expected map[string]string) []string {
+	first := func(values []string) string {
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	}
+	actual := map[string]string{
+		"common_name":         subject.CommonName,
+		"organization":        first(subject.Organization),
+		"organizational_unit": first(subject.OrganizationalUnit),
+		"street_address":      strings.Join(subject.StreetAddress, "\n"),
+		"locality":            first(subject.Locality),
+		"province":            first(subject.Province),
+		"country":             first(subject.Country),
+		"postal_code":         first(subject.PostalCode),
+		"serial_number":       subject.SerialNumber,
+	}
+
+	mismatches := []string{}
+	keys := []string{}
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		actualValue, isKnownKey := actual[key]
+		if !isKnownKey {
+			mismatches = append(mismatches, fmt.Sprintf("subject key %s is not supported by the tls provider", key))
+		} else if actualValue != expected[key] {
+			mismatches = append(mismatches, fmt.Sprintf("subject %s is %q, expected %q", key, actualValue, expected[key]))
+		}
+	}
+	return mismatches
+}
+
+// checkAllowedUses checks that the key usages and extended key usages of the certificate are exactly the expected
+// allowed uses.
+func checkAllowedUses(cert *x509.Certificate, allowedUses []string) ([]string, error) {
+	var expectedKeyUsage x509.KeyUsage
+	expectedExtKeyUsages := []x509.ExtKeyUsage{}
+	for _, allowedUse := range allowedUses {
+		if keyUsage, isKeyUsage := keyUsages[allowedUse]; isKeyUsage {
+			expectedKeyUsage |= keyUsage
+		} else if extKeyUsage, isExtKeyUsage := extKeyUsages[allowedUse]; isExtKeyUsage {
+			expectedExtKeyUsages = append(expectedExtKeyUsages, extKeyUsage)
+		} else {
+			return nil, UnknownAllowedUseError{AllowedUse: allowedUse}
+		}
+	}
+
+	actualExtKeyUsages := append([]x509.ExtKeyUsage{}, cert.ExtKeyUsage...)
+	sortExtKeyUsages(expectedExtKeyUsages)
+	sortExtKeyUsages(actualExtKeyUsages)
+	if cert.KeyUsage == expectedKeyUsage && reflect.DeepEqual(actualExtKeyUsages, expectedExtKeyUsages) {
+		return nil, nil
+	}
+	return []string{
+		fmt.Sprintf("allowed uses are %v, expected %v", allowedUsesOf(cert), allowedUses),
+	}, nil
+}
+
+// allowedUsesOf returns the key usages and extended key usages of the certificate as tls provider keywords.
+func allowedUsesOf(cert *x509.Certificate) []string {
+	uses := []string{}
+	for keyword, keyUsage := range keyUsages {
+		if cert.KeyUsage&keyUsage != 0 {
+			uses = append(uses, keyword)
+		}
+	}
+	for keyword, extKeyUsage := range extKeyUsages {
+		for _, certExtKeyUsage := range cert.ExtKeyUsage {
+			if certExtKeyUsage == extKeyUsage {
+				uses = append(uses, keyword)
+			}
+		}
+	}
+	sort.Strings(uses)
+	return uses
+}
+
+func sortExtKeyUsages(usages []x509.ExtKeyUsage) {
+	sort.Slice(usages, func(i, j int) bool { return usages[i] < usages[j] })
+}
+
+func stringSetsEqual(actual []string, expected []string) bool {
+	sortedActual := append([]string{}, actual...)
+	sortedExpected := append([]string{}, expected...)
+	sort.Strings(sortedActual)
+	sort.Strings(sortedExpected)
+	return reflect.DeepEqual(sortedActual, sortedExpected)
+}
+
+func publicKeysEqual(a crypto.PublicKey, b crypto.PublicKey) bool {
+	aBytes, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aBytes, bBytes)
+}
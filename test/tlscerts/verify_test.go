@@ -0,0 +1,214 @@
+package tlscerts
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	testCAAllowedUses     = []string{"cert_signing", "key_encipherment", "digital_signature", "server_auth", "client_auth"}
+	testServerAllowedUses = []string{"key_encipherment", "digital_signature", "server_auth"}
+)
+
+func TestVerifyKeyPairsFromSecrets(t *testing.T) {
+	t.Parallel()
+
+	caKey := generateECDSAKey(t)
+	caTemplate := newTestCertTemplate(t, "tiller CA", testCAAllowedUses)
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caCertPEM := createCertPEM(t, caTemplate, caTemplate, caKey.Public(), caKey)
+
+	serverKey := generateRSAKey(t, 2048)
+	serverTemplate := newTestCertTemplate(t, "tiller", testServerAllowedUses)
+	serverTemplate.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	caCert, err := ParseCertificatePEME("ca", caCertPEM)
+	require.NoError(t, err)
+	serverCertPEM := createCertPEM(t, serverTemplate, caCert, serverKey.Public(), caKey)
+
+	caSecret := newTestSecret(t, "ca", caKey, caCertPEM, "", nil)
+	serverSecret := newTestSecret(t, "tls", serverKey, serverCertPEM, "ca", caCertPEM)
+
+	caKeyPair, err := KeyPairFromSecretE(caSecret, DefaultCAFilenameBase, "")
+	require.NoError(t, err)
+	serverKeyPair, err := KeyPairFromSecretE(serverSecret, DefaultServerFilenameBase, DefaultCAFilenameBase)
+	require.NoError(t, err)
+
+	VerifyKeyPair(t, caKeyPair, Expectations{
+		Subject:              map[string]string{"common_name": "tiller CA", "organization": "Gruntwork"},
+		AllowedUses:          testCAAllowedUses,
+		ValidityPeriodHours:  87660,
+		PrivateKeyAlgorithm:  "ECDSA",
+		PrivateKeyECDSACurve: "P256",
+		IsCA:                 true,
+	})
+	VerifyKeyPair(t, serverKeyPair, Expectations{
+		Subject:             map[string]string{"common_name": "tiller", "organization": "Gruntwork"},
+		AllowedUses:         testServerAllowedUses,
+		ValidityPeriodHours: 87660,
+		PrivateKeyAlgorithm: "RSA",
+		PrivateKeyRSABits:   2048,
+		IPAddresses:         []string{"127.0.0.1"},
+		DNSNames:            []string{},
+	})
+	VerifyChain(t, caKeyPair, serverKeyPair)
+}
+
+func TestVerifyKeyPairReportsEveryMismatch(t *testing.T) {
+	t.Parallel()
+
+	key := generateECDSAKey(t)
+	template := newTestCertTemplate(t, "tiller", testServerAllowedUses)
+	certPEM := createCertPEM(t, template, template, key.Public(), key)
+	keyPair, err := KeyPairFromSecretE(newTestSecret(t, "tls", key, certPEM, "", nil), "tls", "")
+	require.NoError(t, err)
+
+	err = VerifyKeyPairE(keyPair, Expectations{
+		Subject:             map[string]string{"common_name": "not-tiller", "org": "Gruntwork"},
+		AllowedUses:         []string{"key_encipherment", "client_auth"},
+		ValidityPeriodHours: 24,
+		PrivateKeyAlgorithm: "RSA",
+		IsCA:                true,
+	})
+	require.Error(t, err)
+	mismatchesErr, isMismatchesErr := err.(CertificateMismatchesError)
+	require.True(t, isMismatchesErr, "Expected CertificateMismatchesError, got %T: %s", err, err)
+	assert.Equal(t, 6, len(mismatchesErr.Mismatches), err.Error())
+}
+
+func TestVerifyChainRejectsCertFromOtherCA(t *testing.T) {
+	t.Parallel()
+
+	caKey := generateECDSAKey(t)
+	caTemplate := newTestCertTemplate(t, "tiller CA", testCAAllowedUses)
+	caTemplate.IsCA = true
+	caTemplate.BasicConstraintsValid = true
+	caCertPEM := createCertPEM(t, caTemplate, caTemplate, caKey.Public(), caKey)
+	caKeyPair, err := KeyPairFromSecretE(newTestSecret(t, "ca", caKey, caCertPEM, "", nil), "ca", "")
+	require.NoError(t, err)
+
+	// Self sign the client cert, but claim it was signed by the CA by storing the CA cert alongside it.
+	clientKey := generateECDSAKey(t)
+	clientTemplate := newTestCertTemplate(t, "client", []string{"client_auth"})
+	clientCertPEM := createCertPEM(t, clientTemplate, clientTemplate, clientKey.Public(), clientKey)
+	clientKeyPair, err := KeyPairFromSecretE(
+		newTestSecret(t, "client", clientKey, clientCertPEM, "ca", caCertPEM),
+		DefaultClientFilenameBase,
+		DefaultCAFilenameBase,
+	)
+	require.NoError(t, err)
+
+	err = VerifyChainE(caKeyPair, clientKeyPair)
+	require.Error(t, err)
+	_, isMismatchesErr := err.(CertificateMismatchesError)
+	assert.True(t, isMismatchesErr, "Expected CertificateMismatchesError, got %T: %s", err, err)
+}
+
+func TestKeyPairFromSecretRequiresAllKeys(t *testing.T) {
+	t.Parallel()
+
+	key := generateECDSAKey(t)
+	template := newTestCertTemplate(t, "tiller", testServerAllowedUses)
+	secret := newTestSecret(t, "tls", key, createCertPEM(t, template, template, key.Public(), key), "", nil)
+	delete(secret.Data, "tls.pub")
+	_, err := KeyPairFromSecretE(secret, "tls", "")
+	require.Error(t, err)
+	keyErr, isKeyErr := err.(MissingSecretKeyError)
+	require.True(t, isKeyErr, "Expected MissingSecretKeyError, got %T: %s", err, err)
+	assert.Equal(t, "tls.pub", keyErr.Key)
+}
+
+// newTestCertTemplate returns a certificate template that is similar to the ones the terraform tls provider creates.
+func newTestCertTemplate(t *testing.T, commonName string, allowedUses []string) *x509.Certificate {
+	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	notBefore := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Gruntwork"}},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(87660 * time.Hour),
+	}
+	for _, allowedUse := range allowedUses {
+		if keyUsage, isKeyUsage := keyUsages[allowedUse]; isKeyUsage {
+			template.KeyUsage |= keyUsage
+		} else {
+			template.ExtKeyUsage = append(template.ExtKeyUsage, extKeyUsages[allowedUse])
+		}
+	}
+	return template
+}
+
+func createCertPEM(
+	t *testing.T,
+	template *x509.Certificate,
+	parent *x509.Certificate,
+	publicKey crypto.PublicKey,
+	signer crypto.Signer,
+) []byte {
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+}
+
+func generateECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func generateRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return key
+}
+
+// newTestSecret returns a Secret with the key pair stored in the layout of the TLS modules.
+func newTestSecret(
+	t *testing.T,
+	filenameBase string,
+	key crypto.Signer,
+	certPEM []byte,
+	caFilenameBase string,
+	caCertPEM []byte,
+) *corev1.Secret {
+	var privateKeyPEM []byte
+	switch typedKey := key.(type) {
+	case *rsa.PrivateKey:
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(typedKey)})
+	case *ecdsa.PrivateKey:
+		keyDER, err := x509.MarshalECPrivateKey(typedKey)
+		require.NoError(t, err)
+		privateKeyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: filenameBase + "-certs", Namespace: "tiller"},
+		Data: map[string][]byte{
+			filenameBase + ".pem": privateKeyPEM,
+			filenameBase + ".pub": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDER}),
+			filenameBase + ".crt": certPEM,
+		},
+	}
+	if caFilenameBase != "" {
+		secret.Data[caFilenameBase+".crt"] = caCertPEM
+	}
+	return secret
+}
@@ -51,6 +51,12 @@ variable "tiller_version" {
   default     = "v2.11.0"
 }
 
+variable "tiller_history_max" {
+  description = "The maximum number of revisions saved per release. Use 0 for no limit."
+  type        = number
+  default     = 0
+}
+
 # TLS algorithm configuration
 
 variable "private_key_algorithm" {